@@ -0,0 +1,139 @@
+// Package demangle turns mangled symbol names into human readable ones.
+//
+// Supported schemes are the Itanium C++ ABI (used by GCC and Clang everywhere but
+// Windows), Rust legacy and v0 mangling and Swift. Swift support covers named
+// types, functions, variables, initializers and accessors; symbols using other
+// constructs are reported as invalid.
+package demangle
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrNotMangled is returned when the name is not mangled with any supported scheme.
+	ErrNotMangled = errors.New("not a mangled name")
+	// ErrInvalid is returned when the name looks mangled but could not be demangled.
+	ErrInvalid = errors.New("invalid mangled name")
+)
+
+// Scheme represents the mangling scheme of the symbol.
+type Scheme int
+
+const (
+	SchemeNone Scheme = iota
+	SchemeItanium
+	SchemeRustLegacy
+	SchemeRustV0
+	SchemeSwift
+)
+
+func (s Scheme) String() string {
+	return [...]string{"none",
+		"itanium",
+		"rust-legacy",
+		"rust-v0",
+		"swift"}[s]
+}
+
+// Detect returns the mangling scheme of the name based on its prefix.
+// On Apple platforms symbols carry an extra leading underscore, which is accepted.
+func Detect(name string) Scheme {
+	switch {
+	case strings.HasPrefix(name, "_R"), strings.HasPrefix(name, "__R"):
+		return SchemeRustV0
+	case strings.HasPrefix(name, "_Z"), strings.HasPrefix(name, "__Z"):
+		if isRustLegacy(trimApplePrefix(name)) {
+			return SchemeRustLegacy
+		}
+		return SchemeItanium
+	case hasSwiftPrefix(name):
+		return SchemeSwift
+	}
+	return SchemeNone
+}
+
+// Demangle returns the demangled name.
+func Demangle(name string) (string, error) {
+	switch Detect(name) {
+	case SchemeItanium:
+		return demangleItanium(trimApplePrefix(name))
+	case SchemeRustLegacy:
+		return demangleRustLegacy(trimApplePrefix(name))
+	case SchemeRustV0:
+		return demangleRustV0(trimApplePrefix(name))
+	case SchemeSwift:
+		return demangleSwift(name)
+	}
+	return "", ErrNotMangled
+}
+
+// trimApplePrefix removes the extra underscore of the symbols on Apple platforms.
+func trimApplePrefix(name string) string {
+	if strings.HasPrefix(name, "__") {
+		return name[1:]
+	}
+	return name
+}
+
+// Filter returns the demangled name or the name itself if it could not be demangled.
+func Filter(name string) string {
+	if demangled, err := Demangle(name); err == nil {
+		return demangled
+	}
+	return name
+}
+
+// reSymbol matches the candidates for mangled symbols inside the text.
+var reSymbol = regexp.MustCompile(`(?:^|[^A-Za-z0-9_$.])((?:__?Z|__?R|_?\$[sSe]|_T0)[A-Za-z0-9_$.]*[A-Za-z0-9_$])`)
+
+// Text replaces all the mangled symbols found inside the text, such as backtraces
+// or tracing output, with their demangled names.
+func Text(text string) string {
+	matches := reSymbol.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[2], m[3]
+		demangled, err := Demangle(text[start:end])
+		if err != nil {
+			continue
+		}
+		sb.WriteString(text[last:start])
+		sb.WriteString(demangled)
+		last = end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// parseError is used to unwind the recursive descent parsers.
+type parseError struct{}
+
+// recoverParse turns the parseError panic into ErrInvalid.
+func recoverParse(err *error) {
+	if r := recover(); r != nil {
+		if _, ok := r.(parseError); !ok {
+			panic(r)
+		}
+		*err = ErrInvalid
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isLower(c byte) bool {
+	return c >= 'a' && c <= 'z'
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
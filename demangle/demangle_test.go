@@ -0,0 +1,196 @@
+package demangle
+
+import (
+	"errors"
+	"testing"
+)
+
+// The expected names of the Itanium and Rust legacy symbols are the output of
+// c++filt (with ">>" instead of "> >" and without the Rust hash), the Rust v0
+// ones the output of rustfilt.
+
+type demangleTest struct {
+	mangled string
+	want    string
+}
+
+func testDemangle(t *testing.T, scheme Scheme, tests []demangleTest) {
+	t.Helper()
+	for _, tt := range tests {
+		if got := Detect(tt.mangled); got != scheme {
+			t.Errorf("Detect(%q) = %s, want %s", tt.mangled, got, scheme)
+		}
+		got, err := Demangle(tt.mangled)
+		if err != nil {
+			t.Errorf("Demangle(%q): %v", tt.mangled, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Demangle(%q)\n got %s\nwant %s", tt.mangled, got, tt.want)
+		}
+	}
+}
+
+func TestDemangleItanium(t *testing.T) {
+	testDemangle(t, SchemeItanium, []demangleTest{
+		{"_Z3fooi", "foo(int)"},
+		{"__ZN3foo3barEv", "foo::bar()"},
+		{
+			"_Z10FileExistsNSt7__cxx1112basic_stringIcSt11char_traitsIcESaIcEEE",
+			"FileExists(std::__cxx11::basic_string<char, std::char_traits<char>, std::allocator<char>>)",
+		},
+		{
+			"_ZN2v88internal8compiler9Operator1IdNS1_9OpEqualToIdEENS1_6OpHashIdEEED0Ev",
+			"v8::internal::compiler::Operator1<double, v8::internal::compiler::OpEqualTo<double>, v8::internal::compiler::OpHash<double>>::~Operator1()",
+		},
+		{
+			"_ZN4node6loader10ModuleWrap8EvaluateERKN2v820FunctionCallbackInfoINS2_5ValueEEE.cold",
+			"node::loader::ModuleWrap::Evaluate(v8::FunctionCallbackInfo<v8::Value> const&) [clone .cold]",
+		},
+		{
+			"_ZN4llvm2cl5applyINS0_3optINS_5Reloc5ModelELb0ENS0_6parserIS4_EEEEA17_cJNS0_4descENS0_11ValuesClassEEEEvPT_RKT0_DpRKT1_",
+			"void llvm::cl::apply<llvm::cl::opt<llvm::Reloc::Model, false, llvm::cl::parser<llvm::Reloc::Model>>, char [17], llvm::cl::desc, llvm::cl::ValuesClass>(llvm::cl::opt<llvm::Reloc::Model, false, llvm::cl::parser<llvm::Reloc::Model>>*, char const (&) [17], llvm::cl::desc const&, llvm::cl::ValuesClass const&)",
+		},
+		{
+			"_ZN4node10JSONWriter13json_keyvalueIA8_cNSt7__cxx1112basic_stringIcSt11char_traitsIcESaIcEEEEEvRKT_RKT0_",
+			"void node::JSONWriter::json_keyvalue<char [8], std::__cxx11::basic_string<char, std::char_traits<char>, std::allocator<char>>>(char const (&) [8], std::__cxx11::basic_string<char, std::char_traits<char>, std::allocator<char>> const&)",
+		},
+		{
+			"_ZN2v88internal15SearchStringRawIKhKtEElPNS0_7IsolateEPKT_iPKT0_ii",
+			"long v8::internal::SearchStringRaw<unsigned char const, unsigned short const>(v8::internal::Isolate*, unsigned char const*, int, unsigned short const*, int, int)",
+		},
+		{
+			"_ZN6disasm15DisassemblerX6423PrintRightOperandHelperEPhMS0_KFPKciE",
+			"disasm::DisassemblerX64::PrintRightOperandHelper(unsigned char*, char const* (disasm::DisassemblerX64::*)(int) const)",
+		},
+		{
+			"_ZTISt5_BindIFPFNSt7__cxx1112basic_stringIcSt11char_traitsIcESaIcEEEP12pkgCacheFileRKN8pkgCache11PkgIteratorEES7_St12_PlaceholderILi1EEEE",
+			"typeinfo for std::_Bind<std::__cxx11::basic_string<char, std::char_traits<char>, std::allocator<char>> (*(pkgCacheFile*, std::_Placeholder<1>))(pkgCacheFile*, pkgCache::PkgIterator const&)>",
+		},
+		{
+			"_ZN4llvm10checkedAddIiEENSt9enable_ifIXsr3std9is_signedIT_EE5valueENS_8OptionalIS2_EEE4typeES2_S2_",
+			"std::enable_if<std::is_signed<int>::value, llvm::Optional<int>>::type llvm::checkedAdd<int>(int, int)",
+		},
+		{
+			"_Z10multiple_pILj1EljEN10if_nonpolyIT1_bXsr15poly_int_traitsIS1_E7is_polyEE4typeERK12poly_int_podIXT_ET0_ES1_",
+			"if_nonpoly<unsigned int, bool, poly_int_traits<unsigned int>::is_poly>::type multiple_p<1u, long, unsigned int>(poly_int_pod<1u, long> const&, unsigned int)",
+		},
+		{
+			"_ZN2wi3absISt4pairIP7rtx_def12machine_modeEEENS_13binary_traitsIT_S7_XsrNS_10int_traitsIS7_EE14precision_typeEXsrS9_14precision_typeEE11result_typeERKS7_",
+			"wi::binary_traits<std::pair<rtx_def*, machine_mode>, std::pair<rtx_def*, machine_mode>, wi::int_traits<std::pair<rtx_def*, machine_mode>>::precision_type, wi::int_traits<std::pair<rtx_def*, machine_mode>>::precision_type>::result_type wi::abs<std::pair<rtx_def*, machine_mode>>(std::pair<rtx_def*, machine_mode> const&)",
+		},
+		{
+			"_ZN19rootless_splay_treeI40default_splay_tree_accessors_with_parentIPN7rtl_ssa9insn_info10order_nodeEEE16splay_and_searchIiZNS6_21compare_nodes_one_wayES4_S4_EUlS4_jE_EEDTclfp1_fp_Li0EEES4_T_T0_",
+			"decltype ({parm#3}({parm#1}, 0)) rootless_splay_tree<default_splay_tree_accessors_with_parent<rtl_ssa::insn_info::order_node*>>::splay_and_search<int, rootless_splay_tree<default_splay_tree_accessors_with_parent<rtl_ssa::insn_info::order_node*>>::compare_nodes_one_way(rtl_ssa::insn_info::order_node*, rtl_ssa::insn_info::order_node*)::{lambda(rtl_ssa::insn_info::order_node*, unsigned int)#1}>(rtl_ssa::insn_info::order_node*, int, rootless_splay_tree<default_splay_tree_accessors_with_parent<rtl_ssa::insn_info::order_node*>>::compare_nodes_one_way(rtl_ssa::insn_info::order_node*, rtl_ssa::insn_info::order_node*)::{lambda(rtl_ssa::insn_info::order_node*, unsigned int)#1})",
+		},
+		{
+			"_ZN2v88internal8compiler14GraphAssembler10BranchImplIJEEEvNS1_15BranchSemanticsEPNS1_4NodeEPNS1_19GraphAssemblerLabelIXsZT_EEES9_NS0_10BranchHintEDpT_",
+			"void v8::internal::compiler::GraphAssembler::BranchImpl<>(v8::internal::compiler::BranchSemantics, v8::internal::compiler::Node*, v8::internal::compiler::GraphAssemblerLabel<0>*, v8::internal::compiler::GraphAssemblerLabel<0>*, v8::internal::BranchHint)",
+		},
+		{
+			"_ZN12v8_inspector9V8Console4callIXadL_ZNS0_10createTaskERKN2v820FunctionCallbackInfoINS2_5ValueEEEEEEEvS7_",
+			"void v8_inspector::V8Console::call<&v8_inspector::V8Console::createTask>(v8::FunctionCallbackInfo<v8::Value> const&)",
+		},
+		{
+			"_ZN4node10BaseObject16InternalFieldSetILi3EXadL_ZNK2v85Value10IsFunctionEvEEEEvNS2_5LocalINS2_6StringEEENS4_IS3_EERKNS2_20PropertyCallbackInfoIvEE",
+			"void node::BaseObject::InternalFieldSet<3, &(v8::Value::IsFunction() const)>(v8::Local<v8::String>, v8::Local<v8::Value>, v8::PropertyCallbackInfo<void> const&)",
+		},
+		{
+			"_ZN10hash_tableI13cselib_hasherLb0E11xcallocatorE8traverseIP8_IO_FILEXadL_Z15dump_cselib_valPP10cselib_valS5_EEEEvT_",
+			"void hash_table<cselib_hasher, false, xcallocator>::traverse<_IO_FILE*, &(dump_cselib_val(cselib_val**, _IO_FILE*))>(_IO_FILE*)",
+		},
+		{
+			"_ZTCN2v84base18CheckMessageStreamE0_NSt7__cxx1119basic_ostringstreamIcSt11char_traitsIcESaIcEEE",
+			"construction vtable for std::__cxx11::basic_ostringstream<char, std::char_traits<char>, std::allocator<char>>-in-v8::base::CheckMessageStream",
+		},
+		{
+			"_ZNK6icu_7225RelativeDateTimeFormatter8doFormatIMS0_KFv14UDateDirection17UDateAbsoluteUnitRNS_29FormattedRelativeDateTimeDataER10UErrorCodeEJS2_S3_EEERNS_13UnicodeStringET_SB_S7_DpT0_",
+			"icu_72::UnicodeString& icu_72::RelativeDateTimeFormatter::doFormat<void (icu_72::RelativeDateTimeFormatter::*)(UDateDirection, UDateAbsoluteUnit, icu_72::FormattedRelativeDateTimeData&, UErrorCode&) const, UDateDirection, UDateAbsoluteUnit>(void (icu_72::RelativeDateTimeFormatter::*)(UDateDirection, UDateAbsoluteUnit, icu_72::FormattedRelativeDateTimeData&, UErrorCode&) const, icu_72::UnicodeString&, UErrorCode&, UDateDirection, UDateAbsoluteUnit) const",
+		},
+		{
+			"_ZSt7find_ifIPKtZN2v88internal20Utf16CharacterStream12AdvanceUntilIZNS3_7Scanner14SkipWhiteSpaceEvEUljE_EEjT_EUltE_ES8_S8_S8_T0_",
+			"unsigned short const* std::find_if<unsigned short const*, v8::internal::Utf16CharacterStream::AdvanceUntil<v8::internal::Scanner::SkipWhiteSpace()::{lambda(unsigned int)#1}>(v8::internal::Scanner::SkipWhiteSpace()::{lambda(unsigned int)#1})::{lambda(unsigned short)#1}>(unsigned short const*, unsigned short const*, v8::internal::Utf16CharacterStream::AdvanceUntil<v8::internal::Scanner::SkipWhiteSpace()::{lambda(unsigned int)#1}>(v8::internal::Scanner::SkipWhiteSpace()::{lambda(unsigned int)#1})::{lambda(unsigned short)#1})",
+		},
+		{
+			"_ZSt16__introsort_loopIPSt4pairISt17basic_string_viewIcSt11char_traitsIcEES4_ElN9__gnu_cxx5__ops15_Iter_comp_iterIZN4node6reportL22PrintComponentVersionsEPNSA_10JSONWriterEEUlRT_RT0_E_EEEvSE_SE_SG_T1_",
+			"void std::__introsort_loop<std::pair<std::basic_string_view<char, std::char_traits<char>>, std::basic_string_view<char, std::char_traits<char>>>*, long, __gnu_cxx::__ops::_Iter_comp_iter<node::report::PrintComponentVersions(node::JSONWriter*)::{lambda(auto:1&, auto:2&)#1}>>(std::pair<std::basic_string_view<char, std::char_traits<char>>, std::basic_string_view<char, std::char_traits<char>>>*, std::pair<std::basic_string_view<char, std::char_traits<char>>, std::basic_string_view<char, std::char_traits<char>>>*, long, __gnu_cxx::__ops::_Iter_comp_iter<node::report::PrintComponentVersions(node::JSONWriter*)::{lambda(auto:1&, auto:2&)#1}>)",
+		},
+		{
+			"_ZTIZNK5clang15LocationContext9printJsonERN4llvm11raw_ostreamEPKcjbSt8functionIFvPKS0_EEEd_UlS8_E_",
+			"typeinfo for clang::LocationContext::printJson(llvm::raw_ostream&, char const*, unsigned int, bool, std::function<void (clang::LocationContext const*)>) const::{default arg#1}::{lambda(clang::LocationContext const*)#1}",
+		},
+		{
+			"_ZN15FLAGS_nofromenvMUlvE_4_FUNEv",
+			"FLAGS_nofromenv::{lambda()#1}::_FUN()",
+		},
+	})
+}
+
+func TestDemangleRustLegacy(t *testing.T) {
+	testDemangle(t, SchemeRustLegacy, []demangleTest{
+		{"_ZN3foo3bar17h05af221e174051e9E", "foo::bar"},
+		{"_ZN3foo3bar17h05af221e174051e9E.llvm.1234", "foo::bar"},
+		{"_ZN4$RP$17h05af221e174051e9E", ")"},
+		{"_ZN8$RF$test17h05af221e174051e9E", "&test"},
+		{"_ZN35Bar$LT$$u5b$u32$u3b$$u20$4$u5d$$GT$17h05af221e174051e9E", "Bar<[u32; 4]>"},
+		{"_ZN5alloc3vec16Vec$LT$T$C$A$GT$4push17h0123456789abcdefE", "alloc::vec::Vec<T,A>::push"},
+	})
+}
+
+func TestDemangleRustV0(t *testing.T) {
+	testDemangle(t, SchemeRustV0, []demangleTest{
+		{"_RNvCskwGfYPst2Cb_3foo16example_function", "foo::example_function"},
+		{"_RNvC6_123foo3bar", "123foo::bar"},
+		{"_RNvXCs1234_3fooNtB2_3BarNtNtCs5678_4core3fmt7Display3fmt", "<foo::Bar as core::fmt::Display>::fmt"},
+		{"_RNCNCNgCs6DXkGYLi8lr_2cc5spawn00B5_", "cc::spawn::{closure#0}::{closure#0}"},
+		{"_RNqCs4fqI2P2rA04_11utf8_identsu30____7hkackfecea1cbdathfdh9hlq6y", "utf8_idents::საჭმელად_გემრიელი_სადილი"},
+		{
+			"_RNCINkXs25_NgCsbmNqQUJIY6D_4core5sliceINyB9_4IterhENuNgNoBb_4iter8iterator8Iterator9rpositionNCNgNpB9_6memchr7memrchrs_0E0Bb_",
+			"<core::slice::Iter<u8> as core::iter::iterator::Iterator>::rposition::<core::slice::memchr::memrchr::{closure#1}>::{closure#0}",
+		},
+		{
+			"_RINbNbCskIICzLVDPPb_5alloc5alloc8box_freeDINbNiB4_5boxed5FnBoxuEp6OutputuEL_ECs1iopQbuBiw2_3std",
+			"alloc::alloc::box_free::<dyn alloc::boxed::FnBox<(), Output = ()>>",
+		},
+	})
+}
+
+func TestDemangleSwift(t *testing.T) {
+	testDemangle(t, SchemeSwift, []demangleTest{
+		{"$sSi", "Swift.Int"},
+		{"$s4main3fooyyF", "main.foo() -> ()"},
+		{"$s4main3FooV3barSiyF", "main.Foo.bar() -> Swift.Int"},
+		{"$s4main3FooVMn", "nominal type descriptor for main.Foo"},
+	})
+}
+
+func TestDemangleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"main", ErrNotMangled},
+		{"", ErrNotMangled},
+		{"_Z", ErrInvalid},
+		{"_ZN3foo", ErrInvalid},
+		{"_Z3fooS_", ErrInvalid},
+		{"_RNvC", ErrInvalid},
+		// disambiguators overflowing 64 bits
+		{"_RNvCszzzzzzzzzzz_3foo3bar", ErrInvalid},
+		{"_RNvCsHzzzzzzzzzz_3foo3bar", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		if _, err := Demangle(tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("Demangle(%q) error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	text := "#0 0x1234 in _ZN3foo3barEv (libfoo.so)\n#1 0x5678 in main _Zoops"
+	want := "#0 0x1234 in foo::bar() (libfoo.so)\n#1 0x5678 in main _Zoops"
+	if got := Text(text); got != want {
+		t.Errorf("Text = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,1342 @@
+package demangle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cxxKind represents the kind of the C++ type, used to print declarators.
+type cxxKind int
+
+const (
+	cxxName cxxKind = iota
+	cxxPointer
+	cxxLRef
+	cxxRRef
+	cxxQual
+	cxxFunc
+	cxxArray
+	cxxMember
+	cxxPack
+	// cxxParam is the substitution of the template parameter, which refers to
+	// the template argument in the scope it is used in.
+	cxxParam
+)
+
+// cxxType is the demangled type or name. Types other than plain names keep their
+// structure so pointers to functions and arrays are printed as C++ declarators.
+type cxxType struct {
+	kind   cxxKind
+	name   string
+	last   string
+	elem   *cxxType
+	quals  string
+	ret    *cxxType
+	params []*cxxType
+	dim    string
+	class  *cxxType
+	// cur selects the element of the pack printed while expanding a pack
+	// expansion, -1 prints all the elements.
+	cur int
+	// index is the index of the template parameter of cxxParam.
+	index int
+}
+
+// newPack returns the template argument pack.
+func newPack(elems []*cxxType) *cxxType {
+	return &cxxType{kind: cxxPack, params: elems, cur: -1}
+}
+
+// resolve returns the type selected by the pack being expanded.
+func (t *cxxType) resolve() *cxxType {
+	for t.kind == cxxPack && t.cur >= 0 && t.cur < len(t.params) {
+		t = t.params[t.cur]
+	}
+	return t
+}
+
+// collapse applies the reference collapsing rules to references of
+// substituted template parameters.
+func (t *cxxType) collapse() *cxxType {
+	if t.kind != cxxLRef && t.kind != cxxRRef {
+		return t
+	}
+	elem := t.elem.resolve()
+	if elem.kind != cxxLRef && elem.kind != cxxRRef {
+		return t
+	}
+	kind := elem.kind
+	if t.kind == cxxLRef {
+		kind = cxxLRef
+	}
+	return (&cxxType{kind: kind, elem: elem.elem}).collapse()
+}
+
+func (t *cxxType) String() string {
+	return t.left() + t.right()
+}
+
+// declKind returns the kind deciding how the type is printed as the element of
+// the declarator, looking through the qualifiers of functions and arrays.
+func (t *cxxType) declKind() cxxKind {
+	t = t.resolve()
+	if t.kind == cxxQual {
+		if k := t.elem.resolve().kind; k == cxxFunc || k == cxxArray {
+			return k
+		}
+	}
+	return t.kind
+}
+
+func (t *cxxType) left() string {
+	t = t.resolve().collapse()
+	switch t.kind {
+	case cxxPointer, cxxLRef, cxxRRef:
+		sym := map[cxxKind]string{cxxPointer: "*", cxxLRef: "&", cxxRRef: "&&"}[t.kind]
+		switch t.elem.declKind() {
+		case cxxFunc:
+			return t.elem.left() + "(" + sym
+		case cxxArray:
+			return t.elem.left() + " (" + sym
+		}
+		return t.elem.left() + sym
+	case cxxQual:
+		elem := t.elem.resolve()
+		if elem.kind == cxxFunc {
+			return t.elem.left()
+		}
+		// qualifiers of the substituted type are not repeated
+		quals := t.quals
+		if elem.kind == cxxQual {
+			for _, q := range strings.Fields(elem.quals) {
+				quals = strings.Replace(quals, " "+q, "", 1)
+			}
+		}
+		return t.elem.left() + quals
+	case cxxFunc:
+		if t.ret == nil {
+			return ""
+		}
+		return returnLeft(t.ret)
+	case cxxArray:
+		return t.elem.left()
+	case cxxMember:
+		if t.elem.declKind() == cxxFunc {
+			return t.elem.left() + "(" + t.class.String() + "::*"
+		}
+		return t.elem.left() + " " + t.class.String() + "::*"
+	case cxxPack:
+		return cxxList(t.params)
+	}
+	return t.name
+}
+
+func (t *cxxType) right() string {
+	t = t.resolve().collapse()
+	switch t.kind {
+	case cxxPointer, cxxLRef, cxxRRef, cxxMember:
+		if k := t.elem.declKind(); k == cxxFunc || k == cxxArray {
+			return ")" + t.elem.right()
+		}
+		return t.elem.right()
+	case cxxQual:
+		if t.elem.resolve().kind == cxxFunc {
+			return t.elem.right() + t.quals
+		}
+		return t.elem.right()
+	case cxxFunc:
+		ret := ""
+		if t.ret != nil {
+			ret = t.ret.right()
+		}
+		return "(" + cxxParams(t.params) + ")" + t.quals + ret
+	case cxxArray:
+		return " [" + t.dim + "]" + strings.TrimPrefix(t.elem.right(), " ")
+	}
+	return ""
+}
+
+// returnLeft returns the left part of the return type followed by the space,
+// which is omitted after the declarator of the returned function pointer.
+func returnLeft(ret *cxxType) string {
+	left := ret.left()
+	if !strings.HasSuffix(left, "(*") && !strings.HasSuffix(left, "(&") {
+		left += " "
+	}
+	return left
+}
+
+func cxxParams(params []*cxxType) string {
+	if len(params) == 1 && params[0].kind == cxxName && params[0].name == "void" {
+		return ""
+	}
+	return cxxList(params)
+}
+
+// cxxList joins the types, skipping the empty packs.
+func cxxList(types []*cxxType) string {
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		if s := t.String(); s != "" || t.resolve().kind != cxxPack {
+			names = append(names, s)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// packs returns the unexpanded argument packs used by the type.
+func (t *cxxType) packs() []*cxxType {
+	if t == nil {
+		return nil
+	}
+	if t.kind == cxxPack {
+		if t.cur >= 0 {
+			return t.params[t.cur].packs()
+		}
+		return []*cxxType{t}
+	}
+	packs := append(t.elem.packs(), t.ret.packs()...)
+	packs = append(packs, t.class.packs()...)
+	for _, param := range t.params {
+		packs = append(packs, param.packs()...)
+	}
+	return packs
+}
+
+// expand returns the pack expansion of the pattern, printing it once for every
+// element of the packs it uses.
+func expand(pattern *cxxType) *cxxType {
+	packs := pattern.packs()
+	if len(packs) == 0 {
+		return &cxxType{name: pattern.String() + "..."}
+	}
+	n := len(packs[0].params)
+	elems := make([]*cxxType, n)
+	for i := 0; i < n; i++ {
+		for _, pack := range packs {
+			pack.cur = i
+		}
+		elems[i] = &cxxType{name: pattern.String()}
+	}
+	for _, pack := range packs {
+		pack.cur = -1
+	}
+	return newPack(elems)
+}
+
+var cxxBuiltins = map[byte]string{
+	'v': "void",
+	'w': "wchar_t",
+	'b': "bool",
+	'c': "char",
+	'a': "signed char",
+	'h': "unsigned char",
+	's': "short",
+	't': "unsigned short",
+	'i': "int",
+	'j': "unsigned int",
+	'l': "long",
+	'm': "unsigned long",
+	'x': "long long",
+	'y': "unsigned long long",
+	'n': "__int128",
+	'o': "unsigned __int128",
+	'f': "float",
+	'd': "double",
+	'e': "long double",
+	'g': "__float128",
+	'z': "...",
+}
+
+var cxxExtBuiltins = map[byte]string{
+	'd': "decimal64",
+	'e': "decimal128",
+	'f': "decimal32",
+	'h': "half",
+	'i': "char32_t",
+	's': "char16_t",
+	'u': "char8_t",
+	'a': "auto",
+	'c': "decltype(auto)",
+	'n': "decltype(nullptr)",
+}
+
+// cxxStd represents the standard abbreviations. Short form is used for types,
+// full form when the abbreviation is the prefix of a nested name.
+type cxxStd struct {
+	short, full, last string
+}
+
+var cxxStdSubs = map[byte]cxxStd{
+	'a': {"std::allocator", "std::allocator", "allocator"},
+	'b': {"std::basic_string", "std::basic_string", "basic_string"},
+	's': {"std::string", "std::basic_string<char, std::char_traits<char>, std::allocator<char>>", "basic_string"},
+	'i': {"std::istream", "std::basic_istream<char, std::char_traits<char>>", "basic_istream"},
+	'o': {"std::ostream", "std::basic_ostream<char, std::char_traits<char>>", "basic_ostream"},
+	'd': {"std::iostream", "std::basic_iostream<char, std::char_traits<char>>", "basic_iostream"},
+}
+
+type cxxOperator struct {
+	name  string
+	arity int
+}
+
+var cxxOperators = map[string]cxxOperator{
+	"nw": {"new", 1}, "na": {"new[]", 1}, "dl": {"delete", 1}, "da": {"delete[]", 1},
+	"ps": {"+", 1}, "ng": {"-", 1}, "ad": {"&", 1}, "de": {"*", 1}, "co": {"~", 1},
+	"pl": {"+", 2}, "mi": {"-", 2}, "ml": {"*", 2}, "dv": {"/", 2}, "rm": {"%", 2},
+	"an": {"&", 2}, "or": {"|", 2}, "eo": {"^", 2}, "aS": {"=", 2}, "pL": {"+=", 2},
+	"mI": {"-=", 2}, "mL": {"*=", 2}, "dV": {"/=", 2}, "rM": {"%=", 2}, "aN": {"&=", 2},
+	"oR": {"|=", 2}, "eO": {"^=", 2}, "ls": {"<<", 2}, "rs": {">>", 2}, "lS": {"<<=", 2},
+	"rS": {">>=", 2}, "eq": {"==", 2}, "ne": {"!=", 2}, "lt": {"<", 2}, "gt": {">", 2},
+	"le": {"<=", 2}, "ge": {">=", 2}, "ss": {"<=>", 2}, "nt": {"!", 1}, "aa": {"&&", 2},
+	"oo": {"||", 2}, "pp": {"++", 1}, "mm": {"--", 1}, "cm": {",", 2}, "pm": {"->*", 2},
+	"pt": {"->", 2}, "cl": {"()", 2}, "ix": {"[]", 2}, "qu": {"?", 3},
+}
+
+// cxxNameInfo describes the demangled name of the encoding.
+type cxxNameInfo struct {
+	template bool
+	// noReturn is set for constructors, destructors and conversion operators
+	// whose template instances have no return type mangled.
+	noReturn bool
+	quals    string
+}
+
+type itaniumParser struct {
+	s    string
+	pos  int
+	subs []*cxxType
+	tmpl []*cxxType
+	last string
+	// lambda is set while parsing the parameters of the lambda
+	lambda bool
+}
+
+var reCloneSuffix = regexp.MustCompile(`^(\.[A-Za-z_]+(?:\.[0-9]+)*|\.[0-9]+)+$`)
+
+func demangleItanium(s string) (ret string, err error) {
+	defer recoverParse(&err)
+
+	p := &itaniumParser{s: s, pos: 2}
+	ret = p.encoding()
+
+	if p.pos < len(s) {
+		suffix := s[p.pos:]
+		if !reCloneSuffix.MatchString(suffix) {
+			return "", ErrInvalid
+		}
+		for _, clone := range regexp.MustCompile(`\.[A-Za-z_]+(?:\.[0-9]+)*|\.[0-9]+`).FindAllString(suffix, -1) {
+			ret += " [clone " + clone + "]"
+		}
+	}
+	return ret, nil
+}
+
+func (p *itaniumParser) fail() {
+	panic(parseError{})
+}
+
+// try runs fn, restoring the parser state and returning false if it fails.
+func (p *itaniumParser) try(fn func()) (ok bool) {
+	pos, subs, last := p.pos, len(p.subs), p.last
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isParse := r.(parseError); !isParse {
+				panic(r)
+			}
+			p.pos, p.subs, p.last = pos, p.subs[:subs], last
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+func (p *itaniumParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *itaniumParser) peekAt(off int) byte {
+	if p.pos+off < len(p.s) {
+		return p.s[p.pos+off]
+	}
+	return 0
+}
+
+func (p *itaniumParser) consume(prefix string) bool {
+	if strings.HasPrefix(p.s[p.pos:], prefix) {
+		p.pos += len(prefix)
+		return true
+	}
+	return false
+}
+
+func (p *itaniumParser) expect(c byte) {
+	if p.peek() != c {
+		p.fail()
+	}
+	p.pos++
+}
+
+func (p *itaniumParser) atEnd() bool {
+	c := p.peek()
+	return c == 0 || c == 'E' || c == '.'
+}
+
+func (p *itaniumParser) number() int {
+	neg := p.consume("n")
+	start := p.pos
+	n := 0
+	for isDigit(p.peek()) {
+		n = n*10 + int(p.peek()-'0')
+		p.pos++
+		if n > 1<<30 {
+			p.fail()
+		}
+	}
+	if p.pos == start {
+		p.fail()
+	}
+	if neg {
+		return -n
+	}
+	return n
+}
+
+// seqID parses the base 36 index terminated with '_' used by substitutions and
+// template parameters: "_" is 0, "0_" is 1 and so on.
+func (p *itaniumParser) seqID() int {
+	if p.consume("_") {
+		return 0
+	}
+	n := 0
+	for {
+		c := p.peek()
+		switch {
+		case isDigit(c):
+			n = n*36 + int(c-'0')
+		case isUpper(c):
+			n = n*36 + int(c-'A') + 10
+		case c == '_':
+			p.pos++
+			return n + 1
+		default:
+			p.fail()
+		}
+		p.pos++
+		if n > len(p.s) {
+			p.fail()
+		}
+	}
+}
+
+func (p *itaniumParser) push(t *cxxType) {
+	p.subs = append(p.subs, t)
+}
+
+func (p *itaniumParser) encoding() string {
+	return p.encodingWithReturn(true)
+}
+
+// encodingWithReturn parses the encoding; withReturn controls printing of the
+// return type of function templates, which is omitted for local name scopes.
+func (p *itaniumParser) encodingWithReturn(withReturn bool) string {
+	if c := p.peek(); (c == 'T' || c == 'G') && !isDigit(p.peekAt(1)) {
+		return p.specialName()
+	}
+
+	name, info := p.name(true)
+	if p.atEnd() {
+		return name
+	}
+	return p.function(name, info, withReturn)
+}
+
+// function parses the signature of the function with the given name.
+func (p *itaniumParser) function(name string, info cxxNameInfo, withReturn bool) string {
+	var ret *cxxType
+	if info.template && !info.noReturn {
+		ret = p.typ()
+	}
+	params := p.bareFunctionType()
+	name += "(" + params + ")" + info.quals
+
+	if ret != nil && withReturn {
+		name = returnLeft(ret) + name + ret.right()
+	}
+	return name
+}
+
+func (p *itaniumParser) bareFunctionType() string {
+	var params []*cxxType
+	for !p.atEnd() {
+		params = append(params, p.typ())
+	}
+	if len(params) == 0 {
+		p.fail()
+	}
+	return cxxParams(params)
+}
+
+func (p *itaniumParser) specialName() string {
+	switch {
+	case p.consume("TV"):
+		return "vtable for " + p.typ().String()
+	case p.consume("TT"):
+		return "VTT for " + p.typ().String()
+	case p.consume("TC"):
+		derived := p.typ().String()
+		p.number()
+		p.expect('_')
+		return "construction vtable for " + p.typ().String() + "-in-" + derived
+	case p.consume("TI"):
+		return "typeinfo for " + p.typ().String()
+	case p.consume("TS"):
+		return "typeinfo name for " + p.typ().String()
+	case p.consume("Th"):
+		p.number()
+		p.expect('_')
+		return "non-virtual thunk to " + p.encoding()
+	case p.consume("Tv"):
+		p.number()
+		p.expect('_')
+		p.number()
+		p.expect('_')
+		return "virtual thunk to " + p.encoding()
+	case p.consume("Tc"):
+		p.callOffset()
+		p.callOffset()
+		return "covariant return thunk to " + p.encoding()
+	case p.consume("TH"):
+		name, _ := p.name(false)
+		return "TLS init function for " + name
+	case p.consume("TW"):
+		name, _ := p.name(false)
+		return "TLS wrapper function for " + name
+	case p.consume("GV"):
+		name, _ := p.name(false)
+		return "guard variable for " + name
+	case p.consume("GR"):
+		name, _ := p.name(false)
+		idx := 0
+		if !p.consume("_") {
+			idx = p.seqID()
+		}
+		return "reference temporary #" + strconv.Itoa(idx) + " for " + name
+	case p.consume("GTt"):
+		return "transaction clone for " + p.encoding()
+	}
+	p.fail()
+	return ""
+}
+
+func (p *itaniumParser) callOffset() {
+	switch {
+	case p.consume("h"):
+		p.number()
+		p.expect('_')
+	case p.consume("v"):
+		p.number()
+		p.expect('_')
+		p.number()
+		p.expect('_')
+	default:
+		p.fail()
+	}
+}
+
+// name parses the name; top is set for the name of the encoding whose
+// template arguments are referred to by the template parameters.
+func (p *itaniumParser) name(top bool) (string, cxxNameInfo) {
+	switch p.peek() {
+	case 'N':
+		return p.nestedName(top)
+	case 'Z':
+		return p.localName(top)
+	}
+
+	var name string
+	var info cxxNameInfo
+	fromSub := false
+	switch {
+	case p.consume("St"):
+		p.consume("L")
+		n, noReturn := p.unqualifiedName()
+		name = "std::" + n
+		info.noReturn = noReturn
+	case p.peek() == 'S':
+		sub := p.substitution(false)
+		if p.peek() != 'I' {
+			return sub.name, info
+		}
+		name = sub.name
+		fromSub = true
+	default:
+		p.consume("L")
+		n, noReturn := p.unqualifiedName()
+		name = n
+		info.noReturn = noReturn
+	}
+
+	if p.peek() == 'I' {
+		// the unscoped template name is a candidate on its own
+		if !fromSub {
+			p.push(&cxxType{name: name, last: p.last})
+		}
+		args, list := p.templateArgs()
+		if top {
+			p.tmpl = list
+		}
+		name = joinTemplateArgs(name, args)
+		info.template = true
+	}
+	return name, info
+}
+
+func (p *itaniumParser) nestedName(top bool) (string, cxxNameInfo) {
+	p.expect('N')
+
+	var info cxxNameInfo
+	info.quals = p.cvQuals()
+	switch {
+	case p.consume("R"):
+		info.quals += " &"
+	case p.consume("O"):
+		info.quals += " &&"
+	}
+
+	var cur string
+	pushed := false
+	join := func(comp string) {
+		if cur == "" {
+			cur = comp
+		} else {
+			cur += "::" + comp
+		}
+	}
+
+	for !p.consume("E") {
+		if p.pos >= len(p.s) {
+			p.fail()
+		}
+		c := p.peek()
+		info.template = false
+		// template arguments of constructors keep them without return type
+		if c != 'I' {
+			info.noReturn = false
+		}
+
+		switch {
+		case c == 'S' && p.peekAt(1) == 't':
+			p.pos += 2
+			join("std")
+			pushed = false
+			continue
+		case c == 'S':
+			sub := p.substitution(true)
+			join(sub.name)
+			p.last = sub.last
+			pushed = false
+			continue
+		case c == 'T':
+			join(p.templateParam().String())
+		case c == 'M':
+			// the data member initializing the closure is already in the scope
+			p.pos++
+			continue
+		case c == 'I':
+			if cur == "" {
+				p.fail()
+			}
+			args, list := p.templateArgs()
+			if top {
+				p.tmpl = list
+			}
+			cur = joinTemplateArgs(cur, args)
+			info.template = true
+		case c == 'C' && p.peekAt(1) != 'I', c == 'D' && p.peekAt(1) >= '0' && p.peekAt(1) <= '5':
+			if p.last == "" {
+				p.fail()
+			}
+			name := p.last
+			if c == 'D' {
+				name = "~" + name
+			}
+			p.pos += 2
+			join(name + p.abiTags())
+			info.noReturn = true
+		case c == 'D' && (p.peekAt(1) == 't' || p.peekAt(1) == 'T'):
+			join(p.typ().String())
+			p.subs = p.subs[:len(p.subs)-1]
+		default:
+			p.consume("L")
+			name, noReturn := p.unqualifiedName()
+			join(name)
+			info.noReturn = noReturn
+		}
+		p.push(&cxxType{name: cur, last: p.last})
+		pushed = true
+	}
+
+	// the complete name is only a candidate when used as a type
+	if pushed {
+		p.subs = p.subs[:len(p.subs)-1]
+	}
+	if cur == "" {
+		p.fail()
+	}
+	return cur, info
+}
+
+func (p *itaniumParser) localName(top bool) (string, cxxNameInfo) {
+	p.expect('Z')
+	if !top {
+		defer p.keepTemplateArgs()()
+	}
+	enc := p.encodingWithReturn(false)
+	p.expect('E')
+
+	if p.consume("s") {
+		p.discriminator()
+		return enc + "::string literal", cxxNameInfo{}
+	}
+	if p.consume("d") {
+		n := 1
+		if p.peek() != '_' {
+			n = p.number() + 2
+		}
+		p.expect('_')
+		enc += "::{default arg#" + strconv.Itoa(n) + "}"
+	}
+
+	name, info := p.name(true)
+	p.discriminator()
+	return enc + "::" + name, info
+}
+
+// keepTemplateArgs returns the function restoring the template arguments the
+// template parameters refer to, used around the nested encodings.
+func (p *itaniumParser) keepTemplateArgs() func() {
+	tmpl := p.tmpl
+	return func() { p.tmpl = tmpl }
+}
+
+func (p *itaniumParser) discriminator() {
+	switch {
+	case p.consume("__"):
+		p.number()
+		p.expect('_')
+	case p.peek() == '_' && isDigit(p.peekAt(1)):
+		p.pos += 2
+	}
+}
+
+func (p *itaniumParser) cvQuals() string {
+	var restrict, volatile, constant bool
+	restrict = p.consume("r")
+	volatile = p.consume("V")
+	constant = p.consume("K")
+
+	var quals string
+	if constant {
+		quals += " const"
+	}
+	if volatile {
+		quals += " volatile"
+	}
+	if restrict {
+		quals += " restrict"
+	}
+	return quals
+}
+
+// unqualifiedName parses the name without the scope; noReturn is set for
+// conversion operators.
+func (p *itaniumParser) unqualifiedName() (string, bool) {
+	c := p.peek()
+	var name string
+	noReturn := false
+
+	switch {
+	case isDigit(c):
+		name = p.sourceName()
+		p.last = name
+	case c == 'U' && p.peekAt(1) == 't':
+		p.pos += 2
+		n := 1
+		if !p.consume("_") {
+			n = p.number() + 2
+			p.expect('_')
+		}
+		name = "{unnamed type#" + strconv.Itoa(n) + "}"
+	case c == 'U' && p.peekAt(1) == 'l':
+		p.pos += 2
+		var params []*cxxType
+		lambda := p.lambda
+		p.lambda = true
+		for !p.consume("E") {
+			if p.pos >= len(p.s) {
+				p.fail()
+			}
+			params = append(params, p.typ())
+		}
+		p.lambda = lambda
+		n := 1
+		if !p.consume("_") {
+			n = p.number() + 2
+			p.expect('_')
+		}
+		name = "{lambda(" + cxxParams(params) + ")#" + strconv.Itoa(n) + "}"
+	case c == 'D' && p.peekAt(1) == 'C':
+		p.pos += 2
+		var names []string
+		for !p.consume("E") {
+			names = append(names, p.sourceName())
+		}
+		name = "[" + strings.Join(names, ", ") + "]"
+	case isLower(c):
+		name, noReturn = p.operatorName()
+	default:
+		p.fail()
+	}
+
+	return name + p.abiTags(), noReturn
+}
+
+func (p *itaniumParser) abiTags() string {
+	var tags string
+	for p.consume("B") {
+		tags += "[abi:" + p.sourceName() + "]"
+	}
+	return tags
+}
+
+func (p *itaniumParser) sourceName() string {
+	n := p.number()
+	if n <= 0 || p.pos+n > len(p.s) {
+		p.fail()
+	}
+	id := p.s[p.pos : p.pos+n]
+	p.pos += n
+
+	if len(id) > 9 && strings.HasPrefix(id, "_GLOBAL_") && strings.ContainsRune("._$", rune(id[8])) && id[9] == 'N' {
+		return "(anonymous namespace)"
+	}
+	return id
+}
+
+func (p *itaniumParser) operatorName() (string, bool) {
+	switch {
+	case p.consume("cv"):
+		return "operator " + p.typ().String(), true
+	case p.consume("li"):
+		return `operator"" ` + p.sourceName(), false
+	case p.peek() == 'v' && isDigit(p.peekAt(1)):
+		p.pos += 2
+		return "operator " + p.sourceName(), false
+	}
+
+	if p.pos+2 > len(p.s) {
+		p.fail()
+	}
+	op, ok := cxxOperators[p.s[p.pos:p.pos+2]]
+	if !ok {
+		p.fail()
+	}
+	p.pos += 2
+	if isLower(op.name[0]) {
+		return "operator " + op.name, false
+	}
+	return "operator" + op.name, false
+}
+
+// substitution parses the back reference; prefix selects the full form of the
+// standard abbreviations used as the prefix of the nested name.
+func (p *itaniumParser) substitution(prefix bool) *cxxType {
+	p.expect('S')
+	if std, ok := cxxStdSubs[p.peek()]; ok {
+		p.pos++
+		p.last = std.last
+		if prefix {
+			return &cxxType{name: std.full, last: std.last}
+		}
+		return &cxxType{name: std.short, last: std.last}
+	}
+
+	idx := p.seqID()
+	if idx >= len(p.subs) {
+		p.fail()
+	}
+	sub := p.subs[idx]
+	p.last = sub.last
+	if sub.kind == cxxParam {
+		return p.param(sub.index)
+	}
+	return sub
+}
+
+func (p *itaniumParser) templateParam() *cxxType {
+	p.expect('T')
+	return p.param(p.seqID())
+}
+
+// param returns the template argument the template parameter refers to.
+func (p *itaniumParser) param(idx int) *cxxType {
+	// the template parameters of the generic lambda are its auto parameters
+	if p.lambda {
+		return &cxxType{name: "auto:" + strconv.Itoa(idx+1)}
+	}
+	if idx >= len(p.tmpl) {
+		p.fail()
+	}
+	return p.tmpl[idx]
+}
+
+func (p *itaniumParser) templateArgs() (string, []*cxxType) {
+	p.expect('I')
+	last := p.last
+
+	var list []*cxxType
+	var names []string
+	for !p.consume("E") {
+		if p.pos >= len(p.s) {
+			p.fail()
+		}
+		arg := p.templateArg()
+		list = append(list, arg)
+		if s := arg.String(); s != "" {
+			names = append(names, s)
+		}
+	}
+
+	p.last = last
+	return "<" + strings.Join(names, ", ") + ">", list
+}
+
+// joinTemplateArgs appends the template arguments to the name, separating them
+// from the name of operator< and operator<<.
+func joinTemplateArgs(name, args string) string {
+	if strings.HasSuffix(name, "<") {
+		return name + " " + args
+	}
+	return name + args
+}
+
+func (p *itaniumParser) templateArg() *cxxType {
+	switch p.peek() {
+	case 'X':
+		p.pos++
+		e := p.expression()
+		p.expect('E')
+		return &cxxType{name: e}
+	case 'L':
+		return &cxxType{name: p.exprPrimary()}
+	case 'J':
+		p.pos++
+		var elems []*cxxType
+		for !p.consume("E") {
+			if p.pos >= len(p.s) {
+				p.fail()
+			}
+			elems = append(elems, p.templateArg())
+		}
+		return newPack(elems)
+	}
+	return p.typ()
+}
+
+func (p *itaniumParser) exprPrimary() string {
+	p.expect('L')
+	if p.consume("_Z") {
+		defer p.keepTemplateArgs()()
+		enc := p.encoding()
+		p.expect('E')
+		return enc
+	}
+
+	typ := p.typ().String()
+	start := p.pos
+	for p.peek() != 'E' {
+		if p.pos >= len(p.s) {
+			p.fail()
+		}
+		p.pos++
+	}
+	val := p.s[start:p.pos]
+	p.pos++
+
+	if strings.HasPrefix(val, "n") {
+		val = "-" + val[1:]
+	}
+	switch typ {
+	case "bool":
+		switch val {
+		case "0":
+			return "false"
+		case "1":
+			return "true"
+		}
+	case "int":
+		return val
+	case "unsigned int":
+		return val + "u"
+	case "long":
+		return val + "l"
+	case "unsigned long":
+		return val + "ul"
+	case "long long":
+		return val + "ll"
+	case "unsigned long long":
+		return val + "ull"
+	case "decltype(nullptr)":
+		if val == "" || val == "0" {
+			return "nullptr"
+		}
+	}
+	return "(" + typ + ")" + val
+}
+
+func (p *itaniumParser) expression() string {
+	e, _ := p.operand()
+	return e
+}
+
+// operand parses the expression; simple is set for names and literals,
+// which are printed without parentheses when used as operands.
+func (p *itaniumParser) operand() (e string, simple bool) {
+	rest := p.s[p.pos:]
+	switch {
+	case isDigit(p.peek()), strings.HasPrefix(rest, "sr"), strings.HasPrefix(rest, "gssr"),
+		strings.HasPrefix(rest, "on"), strings.HasPrefix(rest, "dn"):
+		return p.unresolvedName()
+	case p.peek() == 'T':
+		return p.templateParam().String(), true
+	case p.peek() == 'L':
+		return p.exprPrimary(), true
+	case p.consume("fp"):
+		p.cvQuals()
+		if p.consume("_") {
+			return "{parm#1}", true
+		}
+		n := p.number()
+		p.expect('_')
+		return "{parm#" + strconv.Itoa(n+2) + "}", true
+	case p.consume("sp"):
+		if p.peek() == 'T' {
+			return p.templateParam().String(), true
+		}
+		return p.paren() + "...", false
+	case p.consume("st"):
+		return "sizeof (" + p.typ().String() + ")", false
+	case p.consume("sz"):
+		return "sizeof (" + p.expression() + ")", false
+	case p.consume("sZ"):
+		if p.peek() != 'T' {
+			return "sizeof...(" + p.expression() + ")", false
+		}
+		t := p.templateParam()
+		if t.kind == cxxPack && t.cur < 0 {
+			return strconv.Itoa(len(t.params)), true
+		}
+		return "sizeof...(" + t.String() + ")", false
+	case p.consume("at"):
+		return "alignof (" + p.typ().String() + ")", false
+	case p.consume("az"):
+		return "alignof (" + p.expression() + ")", false
+	case p.consume("cv"):
+		typ := p.typ().String()
+		return "(" + typ + ")(" + p.expression() + ")", false
+	case p.consume("adL_Z"):
+		defer p.keepTemplateArgs()()
+		// the address of the member is printed without the signature
+		nested := p.peek() == 'N'
+		name, info := p.name(true)
+		if p.consume("E") {
+			return "&" + name, false
+		}
+		member := nested && !info.template && info.quals == ""
+		fn := p.function(name, info, true)
+		p.expect('E')
+		if member {
+			return "&" + name, false
+		}
+		return "&(" + fn + ")", false
+	case p.consume("cl"):
+		fn := p.paren()
+		var args []string
+		for !p.consume("E") {
+			if p.pos >= len(p.s) {
+				p.fail()
+			}
+			args = append(args, p.expression())
+		}
+		return fn + "(" + strings.Join(args, ", ") + ")", false
+	}
+
+	if p.pos+2 > len(p.s) {
+		p.fail()
+	}
+	op, ok := cxxOperators[p.s[p.pos:p.pos+2]]
+	if !ok {
+		p.fail()
+	}
+	p.pos += 2
+
+	switch op.arity {
+	case 1:
+		return op.name + p.paren(), false
+	case 2:
+		a := p.paren()
+		b := p.paren()
+		return a + op.name + b, false
+	default:
+		a := p.paren()
+		b := p.paren()
+		c := p.paren()
+		return a + "?" + b + ":" + c, false
+	}
+}
+
+// unresolvedName parses the name of the dependent entity, qualified with the
+// dependent type or the scopes; simple is unset for template names.
+func (p *itaniumParser) unresolvedName() (name string, simple bool) {
+	prefix := ""
+	if p.consume("gs") {
+		prefix = "::"
+	}
+	if !p.consume("sr") {
+		name, template := p.baseUnresolvedName()
+		return prefix + name, !template
+	}
+
+	// Clang mangles the scopes terminated with 'E', GCC mangles the type
+	var scopes []string
+	if !isDigit(p.peek()) || !p.try(func() { scopes = p.scopes() }) {
+		scopes = []string{p.typ().String()}
+	}
+	name, template := p.baseUnresolvedName()
+	return prefix + strings.Join(scopes, "::") + "::" + name, !template
+}
+
+// scopes parses the scopes of the unresolved name terminated with 'E' and
+// followed by the unqualified name.
+func (p *itaniumParser) scopes() []string {
+	var scopes []string
+	for !p.consume("E") {
+		if p.pos >= len(p.s) {
+			p.fail()
+		}
+		scope, _ := p.simpleID()
+		scopes = append(scopes, scope)
+	}
+	if c := p.peek(); !isDigit(c) && c != 'o' && c != 'd' {
+		p.fail()
+	}
+	return scopes
+}
+
+// simpleID parses the name with the optional template arguments.
+func (p *itaniumParser) simpleID() (name string, template bool) {
+	name = p.sourceName()
+	if p.peek() == 'I' {
+		args, _ := p.templateArgs()
+		return joinTemplateArgs(name, args), true
+	}
+	return name, false
+}
+
+// baseUnresolvedName parses the unqualified name of the dependent entity.
+func (p *itaniumParser) baseUnresolvedName() (name string, template bool) {
+	switch {
+	case isDigit(p.peek()):
+		return p.simpleID()
+	case p.consume("dn"):
+		if isDigit(p.peek()) {
+			name, template = p.simpleID()
+			return "~" + name, template
+		}
+		return "~" + p.typ().String(), false
+	}
+	p.consume("on")
+	name, _ = p.operatorName()
+	if p.peek() == 'I' {
+		args, _ := p.templateArgs()
+		return joinTemplateArgs(name, args), true
+	}
+	return name, false
+}
+
+// paren parses the operand, enclosing it in parentheses unless it is simple.
+func (p *itaniumParser) paren() string {
+	e, simple := p.operand()
+	if simple {
+		return e
+	}
+	return "(" + e + ")"
+}
+
+func (p *itaniumParser) typ() *cxxType {
+	c := p.peek()
+
+	if name, ok := cxxBuiltins[c]; ok {
+		p.pos++
+		return &cxxType{name: name}
+	}
+
+	var t *cxxType
+	switch c {
+	case 'u':
+		p.pos++
+		t = &cxxType{name: p.sourceName()}
+	case 'D':
+		next := p.peekAt(1)
+		if name, ok := cxxExtBuiltins[next]; ok {
+			p.pos += 2
+			return &cxxType{name: name}
+		}
+		switch next {
+		case 'F':
+			p.pos += 2
+			n := p.number()
+			p.expect('_')
+			return &cxxType{name: "_Float" + strconv.Itoa(n)}
+		case 'p':
+			p.pos += 2
+			t = expand(p.typ())
+		case 't', 'T':
+			p.pos += 2
+			e := p.expression()
+			p.expect('E')
+			t = &cxxType{name: "decltype (" + e + ")"}
+		case 'v':
+			p.pos += 2
+			n := p.number()
+			p.expect('_')
+			elem := p.typ()
+			t = &cxxType{name: elem.String() + " vector[" + strconv.Itoa(n) + "]"}
+		case 'o', 'O', 'w':
+			p.pos += 2
+			if next == 'O' {
+				p.expression()
+				p.expect('E')
+			} else if next == 'w' {
+				for !p.consume("E") {
+					if p.pos >= len(p.s) {
+						p.fail()
+					}
+					p.typ()
+				}
+			}
+			fn := p.functionType()
+			fn.quals += " noexcept"
+			t = fn
+		default:
+			p.fail()
+		}
+	case 'r', 'V', 'K':
+		quals := p.cvQuals()
+		elem := p.typ()
+		t = &cxxType{kind: cxxQual, elem: elem, quals: quals}
+		// the qualified function type is a single candidate
+		if elem.kind == cxxFunc {
+			p.subs = p.subs[:len(p.subs)-1]
+		}
+	case 'P', 'R', 'O':
+		p.pos++
+		elem := p.typ()
+		kind := map[byte]cxxKind{'P': cxxPointer, 'R': cxxLRef, 'O': cxxRRef}[c]
+		t = &cxxType{kind: kind, elem: elem}
+	case 'C', 'G':
+		p.pos++
+		elem := p.typ()
+		suffix := map[byte]string{'C': " _Complex", 'G': " _Imaginary"}[c]
+		t = &cxxType{name: elem.String() + suffix}
+	case 'F':
+		t = p.functionType()
+	case 'A':
+		p.pos++
+		var dim string
+		switch {
+		case isDigit(p.peek()):
+			dim = strconv.Itoa(p.number())
+		case p.peek() != '_':
+			dim = p.expression()
+		}
+		p.expect('_')
+		elem := p.typ()
+		t = &cxxType{kind: cxxArray, elem: elem, dim: dim}
+	case 'M':
+		p.pos++
+		class := p.typ()
+		mem := p.typ()
+		t = &cxxType{kind: cxxMember, class: class, elem: mem}
+	case 'T':
+		p.pos++
+		idx := p.seqID()
+		t = p.param(idx)
+		p.push(&cxxType{kind: cxxParam, index: idx})
+		if p.peek() == 'I' {
+			args, _ := p.templateArgs()
+			t = &cxxType{name: t.String() + args}
+			p.push(t)
+		}
+		return t
+	case 'S':
+		if p.peekAt(1) == 't' {
+			name, _ := p.name(false)
+			t = &cxxType{name: name, last: p.last}
+			break
+		}
+		sub := p.substitution(false)
+		if p.peek() != 'I' {
+			return sub
+		}
+		args, _ := p.templateArgs()
+		t = &cxxType{name: sub.name + args, last: sub.last}
+	case 'U':
+		p.pos++
+		qual := p.sourceName()
+		if p.peek() == 'I' {
+			args, _ := p.templateArgs()
+			qual += args
+		}
+		elem := p.typ()
+		t = &cxxType{name: elem.String() + " " + qual}
+	case 'N', 'Z', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		name, _ := p.name(false)
+		t = &cxxType{name: name, last: p.last}
+	default:
+		p.fail()
+	}
+
+	p.push(t)
+	return t
+}
+
+func (p *itaniumParser) functionType() *cxxType {
+	p.expect('F')
+	p.consume("Y")
+
+	ret := p.typ()
+	var params []*cxxType
+	var quals string
+	for !p.consume("E") {
+		switch {
+		case p.consume("RE"):
+			quals = " &"
+			return &cxxType{kind: cxxFunc, ret: ret, params: params, quals: quals}
+		case p.consume("OE"):
+			quals = " &&"
+			return &cxxType{kind: cxxFunc, ret: ret, params: params, quals: quals}
+		case p.pos >= len(p.s):
+			p.fail()
+		}
+		params = append(params, p.typ())
+	}
+	return &cxxType{kind: cxxFunc, ret: ret, params: params, quals: quals}
+}
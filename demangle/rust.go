@@ -0,0 +1,686 @@
+package demangle
+
+import (
+	"math"
+	"math/bits"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// reRustHash matches the hash component ending the legacy Rust symbols.
+var reRustHash = regexp.MustCompile(`^h[0-9a-f]{16}$`)
+
+// rustLegacyComponents returns the components of the legacy Rust symbol
+// (_ZN...17h<hash>E) or false if the name is not one.
+func rustLegacyComponents(s string) ([]string, bool) {
+	if !strings.HasPrefix(s, "_ZN") {
+		return nil, false
+	}
+
+	var comps []string
+	pos := 3
+	for {
+		if pos >= len(s) {
+			return nil, false
+		}
+		if s[pos] == 'E' {
+			pos++
+			break
+		}
+		start := pos
+		for pos < len(s) && isDigit(s[pos]) {
+			pos++
+		}
+		n, err := strconv.Atoi(s[start:pos])
+		if err != nil || n == 0 || pos+n > len(s) {
+			return nil, false
+		}
+		comps = append(comps, s[pos:pos+n])
+		pos += n
+	}
+
+	// rustc appends ".llvm.<hash>" to some symbols during LTO
+	if rest := s[pos:]; rest != "" && !strings.HasPrefix(rest, ".") {
+		return nil, false
+	}
+	if len(comps) < 2 || !reRustHash.MatchString(comps[len(comps)-1]) {
+		return nil, false
+	}
+	return comps, true
+}
+
+func isRustLegacy(s string) bool {
+	_, ok := rustLegacyComponents(s)
+	return ok
+}
+
+var rustLegacyEscapes = map[string]string{
+	"SP": "@",
+	"BP": "*",
+	"RF": "&",
+	"LT": "<",
+	"GT": ">",
+	"LP": "(",
+	"RP": ")",
+	"C":  ",",
+}
+
+func demangleRustLegacy(s string) (string, error) {
+	comps, ok := rustLegacyComponents(s)
+	if !ok {
+		return "", ErrInvalid
+	}
+
+	comps = comps[:len(comps)-1]
+	names := make([]string, len(comps))
+	for i, comp := range comps {
+		name, ok := unescapeRustLegacy(comp)
+		if !ok {
+			return "", ErrInvalid
+		}
+		names[i] = name
+	}
+	return strings.Join(names, "::"), nil
+}
+
+func unescapeRustLegacy(comp string) (string, bool) {
+	if strings.HasPrefix(comp, "_$") {
+		comp = comp[1:]
+	}
+
+	var sb strings.Builder
+	for len(comp) > 0 {
+		switch {
+		case comp[0] == '$':
+			end := strings.IndexByte(comp[1:], '$')
+			if end < 0 {
+				return "", false
+			}
+			code := comp[1 : end+1]
+			comp = comp[end+2:]
+
+			if rep, ok := rustLegacyEscapes[code]; ok {
+				sb.WriteString(rep)
+				continue
+			}
+			if !strings.HasPrefix(code, "u") {
+				return "", false
+			}
+			r, err := strconv.ParseUint(code[1:], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(r)) {
+				return "", false
+			}
+			sb.WriteRune(rune(r))
+		case strings.HasPrefix(comp, ".."):
+			sb.WriteString("::")
+			comp = comp[2:]
+		default:
+			sb.WriteByte(comp[0])
+			comp = comp[1:]
+		}
+	}
+	return sb.String(), true
+}
+
+var rustBasicTypes = map[byte]string{
+	'a': "i8",
+	'b': "bool",
+	'c': "char",
+	'd': "f64",
+	'e': "str",
+	'f': "f32",
+	'h': "u8",
+	'i': "isize",
+	'j': "usize",
+	'l': "i32",
+	'm': "u32",
+	'n': "i128",
+	'o': "u128",
+	's': "i16",
+	't': "u16",
+	'u': "()",
+	'v': "...",
+	'x': "i64",
+	'y': "u64",
+	'z': "!",
+	'p': "_",
+}
+
+// rustMaxDepth limits the recursion, since back references can form cycles in
+// malformed symbols.
+const rustMaxDepth = 256
+
+type rustParser struct {
+	s     string
+	pos   int
+	depth int
+	bound int
+}
+
+func demangleRustV0(s string) (ret string, err error) {
+	defer recoverParse(&err)
+
+	p := &rustParser{s: s, pos: 2}
+	if isDigit(p.peek()) {
+		// encoding version, only 0 is defined
+		if p.decimal() != 0 {
+			p.fail()
+		}
+	}
+
+	ret = p.path(true)
+
+	// instantiating crate
+	if isUpper(p.peek()) {
+		p.path(false)
+	}
+	if p.pos < len(s) && s[p.pos] != '.' {
+		p.fail()
+	}
+	return ret, nil
+}
+
+func (p *rustParser) fail() {
+	panic(parseError{})
+}
+
+func (p *rustParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *rustParser) next() byte {
+	c := p.peek()
+	if c == 0 {
+		p.fail()
+	}
+	p.pos++
+	return c
+}
+
+func (p *rustParser) consume(c byte) bool {
+	if p.peek() == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *rustParser) enter() {
+	p.depth++
+	if p.depth > rustMaxDepth {
+		p.fail()
+	}
+}
+
+func (p *rustParser) leave() {
+	p.depth--
+}
+
+// decimal parses the number without leading zeros, "0" is followed by the
+// next token.
+func (p *rustParser) decimal() int {
+	start := p.pos
+	if p.consume('0') {
+		return 0
+	}
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail()
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.fail()
+	}
+	return n
+}
+
+// base62 parses the number terminated with '_': "_" is 0, "0_" is 1 and so on.
+func (p *rustParser) base62() uint64 {
+	if p.consume('_') {
+		return 0
+	}
+	var n uint64
+	for !p.consume('_') {
+		c := p.next()
+		var d uint64
+		switch {
+		case isDigit(c):
+			d = uint64(c - '0')
+		case isLower(c):
+			d = uint64(c-'a') + 10
+		case isUpper(c):
+			d = uint64(c-'A') + 36
+		default:
+			p.fail()
+		}
+		hi, lo := bits.Mul64(n, 62)
+		lo, carry := bits.Add64(lo, d, 0)
+		if hi != 0 || carry != 0 {
+			p.fail()
+		}
+		n = lo
+	}
+	if n == math.MaxUint64 {
+		p.fail()
+	}
+	return n + 1
+}
+
+func (p *rustParser) disambiguator() uint64 {
+	if p.consume('s') {
+		n := p.base62()
+		if n == math.MaxUint64 {
+			p.fail()
+		}
+		return n + 1
+	}
+	return 0
+}
+
+func (p *rustParser) ident() (uint64, string) {
+	dis := p.disambiguator()
+	puny := p.consume('u')
+	n := p.decimal()
+	p.consume('_')
+	if p.pos+n > len(p.s) {
+		p.fail()
+	}
+	name := p.s[p.pos : p.pos+n]
+	p.pos += n
+
+	if puny {
+		decoded, ok := decodePunycode(name)
+		if !ok {
+			p.fail()
+		}
+		name = decoded
+	}
+	return dis, name
+}
+
+// backref runs fn with the input positioned at the back reference target.
+func (p *rustParser) backref(fn func() string) string {
+	start := p.pos - 1
+	idx := p.base62()
+	if idx >= uint64(start) {
+		p.fail()
+	}
+	target := int(idx) + 2
+
+	saved := p.pos
+	p.pos = target
+	ret := fn()
+	p.pos = saved
+	return ret
+}
+
+func (p *rustParser) path(inValue bool) string {
+	p.enter()
+	defer p.leave()
+
+	switch c := p.next(); c {
+	case 'C':
+		_, name := p.ident()
+		return name
+	case 'M':
+		p.disambiguator()
+		p.path(false)
+		return "<" + p.typ() + ">"
+	case 'X':
+		p.disambiguator()
+		p.path(false)
+		t := p.typ()
+		return "<" + t + " as " + p.path(false) + ">"
+	case 'Y':
+		t := p.typ()
+		return "<" + t + " as " + p.path(false) + ">"
+	case 'N':
+		ns := p.next()
+		prefix := p.path(inValue)
+		dis, name := p.ident()
+		if !isUpper(ns) {
+			if name == "" {
+				p.fail()
+			}
+			return prefix + "::" + name
+		}
+		kind := string(ns)
+		switch ns {
+		case 'C':
+			kind = "closure"
+		case 'S':
+			kind = "shim"
+		}
+		if name != "" {
+			kind += ":" + name
+		}
+		return prefix + "::{" + kind + "#" + strconv.FormatUint(dis, 10) + "}"
+	case 'I':
+		prefix := p.path(inValue)
+		var args []string
+		for !p.consume('E') {
+			args = append(args, p.genericArg())
+		}
+		if inValue {
+			prefix += "::"
+		}
+		return prefix + "<" + strings.Join(args, ", ") + ">"
+	case 'B':
+		return p.backref(func() string { return p.path(inValue) })
+	}
+	p.fail()
+	return ""
+}
+
+func (p *rustParser) genericArg() string {
+	switch {
+	case p.consume('L'):
+		return p.lifetime()
+	case p.consume('K'):
+		return p.constant()
+	}
+	return p.typ()
+}
+
+func (p *rustParser) lifetime() string {
+	idx := p.base62()
+	if idx == 0 {
+		return "'_"
+	}
+	if idx > uint64(p.bound) {
+		p.fail()
+	}
+	depth := p.bound - int(idx)
+	if depth < 26 {
+		return "'" + string(rune('a'+depth))
+	}
+	return "'_" + strconv.Itoa(depth)
+}
+
+// binder parses the higher-ranked lifetimes and returns the "for<...> " prefix.
+func (p *rustParser) binder() string {
+	if !p.consume('G') {
+		return ""
+	}
+	idx := p.base62()
+	if idx >= 64 {
+		p.fail()
+	}
+	n := int(idx) + 1
+	names := make([]string, n)
+	for i := range names {
+		p.bound++
+		depth := p.bound - 1
+		if depth < 26 {
+			names[i] = "'" + string(rune('a'+depth))
+		} else {
+			names[i] = "'_" + strconv.Itoa(depth)
+		}
+	}
+	return "for<" + strings.Join(names, ", ") + "> "
+}
+
+func (p *rustParser) typ() string {
+	p.enter()
+	defer p.leave()
+
+	c := p.peek()
+	if name, ok := rustBasicTypes[c]; ok {
+		p.pos++
+		return name
+	}
+
+	switch c {
+	case 'A':
+		p.pos++
+		t := p.typ()
+		return "[" + t + "; " + p.constant() + "]"
+	case 'S':
+		p.pos++
+		return "[" + p.typ() + "]"
+	case 'T':
+		p.pos++
+		var elems []string
+		for !p.consume('E') {
+			elems = append(elems, p.typ())
+		}
+		if len(elems) == 1 {
+			return "(" + elems[0] + ",)"
+		}
+		return "(" + strings.Join(elems, ", ") + ")"
+	case 'R', 'Q':
+		p.pos++
+		ref := "&"
+		if p.peek() == 'L' {
+			p.pos++
+			if lt := p.lifetime(); lt != "'_" {
+				ref += lt + " "
+			}
+		}
+		if c == 'Q' {
+			ref += "mut "
+		}
+		return ref + p.typ()
+	case 'P':
+		p.pos++
+		return "*const " + p.typ()
+	case 'O':
+		p.pos++
+		return "*mut " + p.typ()
+	case 'F':
+		p.pos++
+		return p.fnSig()
+	case 'D':
+		p.pos++
+		bounds := p.dynBounds()
+		if !p.consume('L') {
+			p.fail()
+		}
+		if lt := p.lifetime(); lt != "'_" {
+			bounds += " + " + lt
+		}
+		return bounds
+	case 'B':
+		p.pos++
+		return p.backref(p.typ)
+	}
+	return p.path(false)
+}
+
+func (p *rustParser) fnSig() string {
+	bound := p.bound
+	defer func() { p.bound = bound }()
+
+	var sb strings.Builder
+	sb.WriteString(p.binder())
+	if p.consume('U') {
+		sb.WriteString("unsafe ")
+	}
+	if p.consume('K') {
+		abi := "C"
+		if !p.consume('C') {
+			_, abi = p.ident()
+			abi = strings.ReplaceAll(abi, "_", "-")
+		}
+		sb.WriteString(`extern "` + abi + `" `)
+	}
+
+	var params []string
+	for !p.consume('E') {
+		params = append(params, p.typ())
+	}
+	sb.WriteString("fn(" + strings.Join(params, ", ") + ")")
+	if ret := p.typ(); ret != "()" {
+		sb.WriteString(" -> " + ret)
+	}
+	return sb.String()
+}
+
+func (p *rustParser) dynBounds() string {
+	bound := p.bound
+	defer func() { p.bound = bound }()
+
+	prefix := p.binder()
+	var traits []string
+	for !p.consume('E') {
+		trait := p.path(false)
+		var bindings []string
+		for p.consume('p') {
+			_, name := p.ident()
+			bindings = append(bindings, name+" = "+p.typ())
+		}
+		if len(bindings) > 0 {
+			if strings.HasSuffix(trait, ">") {
+				trait = trait[:len(trait)-1] + ", " + strings.Join(bindings, ", ") + ">"
+			} else {
+				trait += "<" + strings.Join(bindings, ", ") + ">"
+			}
+		}
+		traits = append(traits, trait)
+	}
+	return "dyn " + prefix + strings.Join(traits, " + ")
+}
+
+func (p *rustParser) constant() string {
+	p.enter()
+	defer p.leave()
+
+	switch c := p.next(); c {
+	case 'p':
+		return "_"
+	case 'B':
+		return p.backref(p.constant)
+	case 'a', 's', 'l', 'x', 'n', 'i', 'h', 't', 'm', 'y', 'o', 'j', 'b', 'c':
+		neg := p.consume('n')
+		start := p.pos
+		for p.peek() != '_' {
+			d := p.next()
+			if !isDigit(d) && (d < 'a' || d > 'f') {
+				p.fail()
+			}
+		}
+		hex := p.s[start:p.pos]
+		p.pos++
+
+		if hex == "" {
+			hex = "0"
+		}
+		v, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return "0x" + hex
+		}
+		switch c {
+		case 'b':
+			switch v {
+			case 0:
+				return "false"
+			case 1:
+				return "true"
+			}
+			p.fail()
+		case 'c':
+			if !utf8.ValidRune(rune(v)) {
+				p.fail()
+			}
+			return strconv.QuoteRune(rune(v))
+		}
+		if neg {
+			return "-" + strconv.FormatUint(v, 10)
+		}
+		return strconv.FormatUint(v, 10)
+	}
+	p.fail()
+	return ""
+}
+
+// decodePunycode decodes RFC 3492 punycode as used by Rust, where '_' is the
+// delimiter between the basic and the encoded code points.
+func decodePunycode(s string) (string, bool) {
+	const (
+		base        = 36
+		tmin        = 1
+		tmax        = 26
+		skew        = 38
+		damp        = 700
+		initialBias = 72
+		initialN    = 128
+	)
+
+	var output []rune
+	if idx := strings.LastIndexByte(s, '_'); idx >= 0 {
+		output = []rune(s[:idx])
+		s = s[idx+1:]
+	}
+
+	adapt := func(delta, numPoints int, first bool) int {
+		if first {
+			delta /= damp
+		} else {
+			delta /= 2
+		}
+		delta += delta / numPoints
+		k := 0
+		for delta > ((base-tmin)*tmax)/2 {
+			delta /= base - tmin
+			k += base
+		}
+		return k + (base-tmin+1)*delta/(delta+skew)
+	}
+
+	n, i, bias := initialN, 0, initialBias
+	for pos := 0; pos < len(s); {
+		oldi, w := i, 1
+		for k := base; ; k += base {
+			if pos >= len(s) {
+				return "", false
+			}
+			c := s[pos]
+			pos++
+
+			var digit int
+			switch {
+			case isLower(c):
+				digit = int(c - 'a')
+			case isDigit(c):
+				digit = int(c-'0') + 26
+			default:
+				return "", false
+			}
+
+			i += digit * w
+			t := k - bias
+			if t < tmin {
+				t = tmin
+			} else if t > tmax {
+				t = tmax
+			}
+			if digit < t {
+				break
+			}
+			w *= base - t
+			if w > utf8.MaxRune*base {
+				return "", false
+			}
+		}
+
+		bias = adapt(i-oldi, len(output)+1, oldi == 0)
+		n += i / (len(output) + 1)
+		i %= len(output) + 1
+		if n > utf8.MaxRune {
+			return "", false
+		}
+
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), true
+}
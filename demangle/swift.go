@@ -0,0 +1,579 @@
+package demangle
+
+import (
+	"strconv"
+	"strings"
+)
+
+var swiftPrefixes = []string{"$s", "_$s", "$S", "_$S", "$e", "_$e", "_T0"}
+
+func hasSwiftPrefix(name string) bool {
+	for _, prefix := range swiftPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// swiftStdTypes holds the standard substitutions of the Swift module.
+var swiftStdTypes = map[byte]string{
+	'A': "AutoreleasingUnsafeMutablePointer",
+	'a': "Array",
+	'B': "BinaryFloatingPoint",
+	'b': "Bool",
+	'D': "Dictionary",
+	'd': "Double",
+	'E': "Encodable",
+	'e': "Decodable",
+	'F': "FloatingPoint",
+	'f': "Float",
+	'G': "RandomNumberGenerator",
+	'H': "Hashable",
+	'h': "Set",
+	'I': "DefaultIndices",
+	'i': "Int",
+	'J': "Character",
+	'j': "Numeric",
+	'K': "BidirectionalCollection",
+	'k': "RandomAccessCollection",
+	'L': "Comparable",
+	'l': "Collection",
+	'M': "MutableCollection",
+	'm': "RangeReplaceableCollection",
+	'N': "ClosedRange",
+	'n': "Range",
+	'O': "ObjectIdentifier",
+	'P': "UnsafePointer",
+	'p': "UnsafeMutablePointer",
+	'Q': "Equatable",
+	'q': "Optional",
+	'R': "UnsafeBufferPointer",
+	'r': "UnsafeMutableBufferPointer",
+	'S': "String",
+	's': "Substring",
+	'T': "Sequence",
+	't': "IteratorProtocol",
+	'U': "UnsignedInteger",
+	'u': "UInt",
+	'V': "UnsafeRawPointer",
+	'v': "UnsafeMutableRawPointer",
+	'W': "UnsafeRawBufferPointer",
+	'w': "UnsafeMutableRawBufferPointer",
+	'X': "RangeExpression",
+	'x': "Strideable",
+	'Y': "RawRepresentable",
+	'y': "StringProtocol",
+	'Z': "SignedInteger",
+	'z': "BinaryInteger",
+}
+
+var swiftAccessors = map[byte]string{
+	'g': "getter",
+	's': "setter",
+	'm': "modify",
+	'r': "read",
+	'w': "willset",
+	'W': "didset",
+	'l': "unsafeAddressor",
+	'a': "unsafeMutableAddressor",
+	'G': "getter",
+}
+
+var swiftMetadata = map[byte]string{
+	'a': "type metadata accessor for ",
+	'n': "nominal type descriptor for ",
+	'f': "full type metadata for ",
+	'm': "metaclass for ",
+	'p': "protocol descriptor for ",
+	'L': "type metadata lazy cache variable for ",
+}
+
+type swiftKind int
+
+const (
+	swiftIdent swiftKind = iota
+	swiftType
+	swiftEmptyList
+	swiftMarker
+	swiftTuple
+	swiftThrows
+	swiftVariable
+	swiftEntity
+)
+
+// swiftNode is the node on the demangler stack. Swift mangling is postfix:
+// operators pop their operands from the stack and push the result.
+type swiftNode struct {
+	kind swiftKind
+	text string
+	// name is set for nominal types, for sugared printing of the bound
+	// generic standard types.
+	name  string
+	elems []*swiftNode
+	fn    *swiftFunc
+}
+
+// swiftFunc is the function type, kept to print the signature of initializers.
+type swiftFunc struct {
+	params []*swiftNode
+	result *swiftNode
+	throws bool
+}
+
+type swiftParser struct {
+	s     string
+	pos   int
+	stack []*swiftNode
+	subs  []*swiftNode
+	words []string
+}
+
+func demangleSwift(s string) (ret string, err error) {
+	defer recoverParse(&err)
+
+	p := &swiftParser{s: s}
+	for _, prefix := range swiftPrefixes {
+		if strings.HasPrefix(s, prefix) {
+			p.pos = len(prefix)
+			break
+		}
+	}
+
+	for p.pos < len(p.s) {
+		p.operator()
+	}
+	if len(p.stack) != 1 {
+		return "", ErrInvalid
+	}
+	node := p.stack[0]
+	if node.kind == swiftIdent {
+		return "", ErrInvalid
+	}
+	return node.text, nil
+}
+
+func (p *swiftParser) fail() {
+	panic(parseError{})
+}
+
+func (p *swiftParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *swiftParser) next() byte {
+	c := p.peek()
+	if c == 0 {
+		p.fail()
+	}
+	p.pos++
+	return c
+}
+
+func (p *swiftParser) push(n *swiftNode) {
+	p.stack = append(p.stack, n)
+}
+
+func (p *swiftParser) top() *swiftNode {
+	if len(p.stack) == 0 {
+		return nil
+	}
+	return p.stack[len(p.stack)-1]
+}
+
+func (p *swiftParser) pop(kinds ...swiftKind) *swiftNode {
+	n := p.top()
+	if n == nil {
+		p.fail()
+	}
+	if len(kinds) > 0 {
+		ok := false
+		for _, k := range kinds {
+			ok = ok || n.kind == k
+		}
+		if !ok {
+			p.fail()
+		}
+	}
+	p.stack = p.stack[:len(p.stack)-1]
+	return n
+}
+
+func (p *swiftParser) popIf(kind swiftKind) *swiftNode {
+	if n := p.top(); n != nil && n.kind == kind {
+		return p.pop()
+	}
+	return nil
+}
+
+func (p *swiftParser) natural() int {
+	start := p.pos
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail()
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil || n > len(p.s) {
+		p.fail()
+	}
+	return n
+}
+
+func (p *swiftParser) operator() {
+	c := p.peek()
+	switch {
+	case isDigit(c):
+		n := &swiftNode{kind: swiftIdent, text: p.identifier()}
+		p.subs = append(p.subs, n)
+		p.push(n)
+		return
+	}
+
+	p.pos++
+	switch c {
+	case 'S':
+		p.standard()
+	case 'A':
+		p.substitution()
+	case 'C', 'V', 'O', 'P', 'a':
+		name := p.pop(swiftIdent)
+		ctx := p.popContext()
+		n := &swiftNode{kind: swiftType, text: ctx + "." + name.text, name: ctx + "." + name.text}
+		p.subs = append(p.subs, n)
+		p.push(n)
+	case 'y':
+		p.push(&swiftNode{kind: swiftEmptyList})
+	case '_':
+		p.push(&swiftNode{kind: swiftMarker})
+	case 't':
+		p.push(p.tuple())
+	case 'G':
+		p.boundGeneric()
+	case 'z':
+		t := p.popType()
+		p.push(&swiftNode{kind: swiftType, text: "inout " + t.text})
+	case 'K':
+		p.push(&swiftNode{kind: swiftThrows})
+	case 'c':
+		fn := p.popSignature()
+		p.push(&swiftNode{kind: swiftType, text: swiftSignature(fn, nil), fn: fn})
+	case 'F':
+		fn := p.popSignature()
+		labels := p.popLabels(len(fn.params))
+		name := p.pop(swiftIdent)
+		ctx := p.popContext()
+		p.push(&swiftNode{kind: swiftEntity, text: ctx + "." + name.text + swiftSignature(fn, labels)})
+	case 'f':
+		p.constructor()
+	case 'v':
+		t := p.popType()
+		name := p.pop(swiftIdent)
+		ctx := p.popContext()
+		p.push(&swiftNode{kind: swiftVariable, text: ctx + "." + name.text, elems: []*swiftNode{t}})
+		if accessor, ok := swiftAccessors[p.peek()]; ok {
+			p.pos++
+			v := p.pop()
+			p.push(&swiftNode{kind: swiftEntity, text: v.text + "." + accessor + " : " + t.text})
+		}
+	case 'N':
+		t := p.popType()
+		p.push(&swiftNode{kind: swiftEntity, text: "type metadata for " + t.text})
+	case 'M':
+		prefix, ok := swiftMetadata[p.next()]
+		if !ok {
+			p.fail()
+		}
+		t := p.popType()
+		p.push(&swiftNode{kind: swiftEntity, text: prefix + t.text})
+	default:
+		p.fail()
+	}
+}
+
+// identifier parses the identifier including the word substitutions of
+// previously seen identifiers.
+func (p *swiftParser) identifier() string {
+	hasWords := false
+	if p.peek() == '0' {
+		p.pos++
+		if p.peek() == '0' {
+			// punycode identifiers are not supported
+			p.fail()
+		}
+		hasWords = true
+	}
+
+	var sb strings.Builder
+	for {
+		for hasWords && (isLower(p.peek()) || isUpper(p.peek())) {
+			c := p.next()
+			idx := 0
+			if isLower(c) {
+				idx = int(c - 'a')
+			} else {
+				idx = int(c - 'A')
+				hasWords = false
+			}
+			if idx >= len(p.words) {
+				p.fail()
+			}
+			sb.WriteString(p.words[idx])
+		}
+		if p.peek() == '0' {
+			p.pos++
+			break
+		}
+
+		n := p.natural()
+		if n <= 0 || p.pos+n > len(p.s) {
+			p.fail()
+		}
+		slice := p.s[p.pos : p.pos+n]
+		p.pos += n
+		sb.WriteString(slice)
+		p.collectWords(slice)
+
+		if !hasWords {
+			break
+		}
+	}
+
+	if sb.Len() == 0 {
+		p.fail()
+	}
+	return sb.String()
+}
+
+// swiftMaxWords is the number of words available for the word substitutions.
+const swiftMaxWords = 26
+
+func (p *swiftParser) collectWords(slice string) {
+	isWordStart := func(c byte) bool {
+		return !isDigit(c) && c != '_' && c != 0
+	}
+	isWordEnd := func(c, prev byte) bool {
+		return c == '_' || c == 0 || (!isUpper(prev) && isUpper(c))
+	}
+
+	start := -1
+	for i := 0; i <= len(slice); i++ {
+		var c byte
+		if i < len(slice) {
+			c = slice[i]
+		}
+		if start >= 0 && isWordEnd(c, slice[i-1]) {
+			if i-start >= 2 && len(p.words) < swiftMaxWords {
+				p.words = append(p.words, slice[start:i])
+			}
+			start = -1
+		}
+		if start < 0 && isWordStart(c) {
+			start = i
+		}
+	}
+}
+
+func (p *swiftParser) standard() {
+	count := 1
+	if isDigit(p.peek()) {
+		count = p.natural()
+	}
+
+	c := p.next()
+	if c == 'g' && count == 1 {
+		t := p.popType()
+		n := &swiftNode{kind: swiftType, text: t.text + "?"}
+		p.subs = append(p.subs, n)
+		p.push(n)
+		return
+	}
+
+	name, ok := swiftStdTypes[c]
+	if !ok {
+		p.fail()
+	}
+	for i := 0; i < count; i++ {
+		p.push(&swiftNode{kind: swiftType, text: "Swift." + name, name: "Swift." + name})
+	}
+}
+
+func (p *swiftParser) substitution() {
+	count := 1
+	for {
+		c := p.next()
+		switch {
+		case isDigit(c):
+			p.pos--
+			count = p.natural()
+			continue
+		case c == '_':
+			p.pushSubst(count + 27)
+			return
+		case isLower(c):
+			p.pushSubst(int(c - 'a'))
+		case isUpper(c):
+			p.pushSubst(int(c - 'A'))
+			return
+		default:
+			p.fail()
+		}
+	}
+}
+
+func (p *swiftParser) pushSubst(idx int) {
+	if idx >= len(p.subs) {
+		p.fail()
+	}
+	p.push(p.subs[idx])
+}
+
+func (p *swiftParser) popContext() string {
+	n := p.pop(swiftIdent, swiftType)
+	return n.text
+}
+
+func (p *swiftParser) popType() *swiftNode {
+	n := p.pop(swiftType, swiftEmptyList, swiftTuple)
+	if n.kind == swiftEmptyList {
+		return &swiftNode{kind: swiftTuple, text: "()"}
+	}
+	return n
+}
+
+// popParams pops the parameters of the function signature.
+func (p *swiftParser) popParams() []*swiftNode {
+	n := p.popType()
+	if n.kind == swiftTuple {
+		return n.elems
+	}
+	return []*swiftNode{n}
+}
+
+// popLabels pops the argument labels of the function with n parameters.
+// Functions whose parameters have no labels are mangled with the empty list.
+func (p *swiftParser) popLabels(n int) []string {
+	if n == 0 || p.popIf(swiftEmptyList) != nil {
+		return nil
+	}
+
+	labels := make([]string, n)
+	for i := n - 1; i >= 0; i-- {
+		l := p.pop(swiftIdent, swiftMarker)
+		if l.kind == swiftIdent {
+			labels[i] = l.text
+		}
+	}
+	return labels
+}
+
+func (p *swiftParser) tuple() *swiftNode {
+	if p.popIf(swiftEmptyList) != nil {
+		return &swiftNode{kind: swiftTuple, text: "()"}
+	}
+
+	// the marker follows the first element
+	var elems []*swiftNode
+	for {
+		first := p.popIf(swiftMarker) != nil
+		elems = append([]*swiftNode{p.popType()}, elems...)
+		if first {
+			break
+		}
+	}
+
+	names := make([]string, len(elems))
+	for i, e := range elems {
+		names[i] = e.text
+	}
+	return &swiftNode{kind: swiftTuple, text: "(" + strings.Join(names, ", ") + ")", elems: elems}
+}
+
+func (p *swiftParser) boundGeneric() {
+	var args []*swiftNode
+	for p.popIf(swiftEmptyList) == nil {
+		if p.popIf(swiftMarker) != nil {
+			continue
+		}
+		args = append([]*swiftNode{p.popType()}, args...)
+	}
+	base := p.pop(swiftType)
+
+	var text string
+	switch {
+	case base.name == "Swift.Array" && len(args) == 1:
+		text = "[" + args[0].text + "]"
+	case base.name == "Swift.Dictionary" && len(args) == 2:
+		text = "[" + args[0].text + " : " + args[1].text + "]"
+	case base.name == "Swift.Optional" && len(args) == 1:
+		text = args[0].text + "?"
+	default:
+		names := make([]string, len(args))
+		for i, a := range args {
+			names[i] = a.text
+		}
+		text = base.text + "<" + strings.Join(names, ", ") + ">"
+	}
+
+	n := &swiftNode{kind: swiftType, text: text}
+	p.subs = append(p.subs, n)
+	p.push(n)
+}
+
+func (p *swiftParser) constructor() {
+	var name string
+	switch p.next() {
+	case 'C':
+		name = "__allocating_init"
+	case 'c':
+		name = "init"
+	case 'D':
+		ctx := p.popContext()
+		p.push(&swiftNode{kind: swiftEntity, text: ctx + ".__deallocating_deinit"})
+		return
+	case 'd':
+		ctx := p.popContext()
+		p.push(&swiftNode{kind: swiftEntity, text: ctx + ".deinit"})
+		return
+	default:
+		p.fail()
+	}
+
+	fn := p.popSignature()
+	labels := p.popLabels(len(fn.params))
+	ctx := p.popContext()
+	p.push(&swiftNode{kind: swiftEntity, text: ctx + "." + name + swiftSignature(fn, labels)})
+}
+
+// popSignature pops the function type or the results and parameters of the
+// function signature.
+func (p *swiftParser) popSignature() *swiftFunc {
+	if top := p.top(); top != nil && top.fn != nil {
+		return p.pop().fn
+	}
+
+	fn := &swiftFunc{throws: p.popIf(swiftThrows) != nil}
+	fn.params = p.popParams()
+	fn.result = p.popType()
+	return fn
+}
+
+func swiftSignature(fn *swiftFunc, labels []string) string {
+	names := make([]string, len(fn.params))
+	for i, param := range fn.params {
+		names[i] = param.text
+		if i < len(labels) && labels[i] != "" {
+			names[i] = labels[i] + ": " + param.text
+		}
+	}
+
+	sig := "(" + strings.Join(names, ", ") + ")"
+	if fn.throws {
+		sig += " throws"
+	}
+	return sig + " -> " + fn.result.text
+}
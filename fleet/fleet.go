@@ -0,0 +1,514 @@
+// Package fleet runs the same agent on many devices and processes at once.
+//
+// Run resolves the devices matching the DeviceSelector, attaches to every process
+// matching the TargetSelector on each of them and loads the script. Messages sent
+// by all the scripts are delivered on a single channel tagged with the device and
+// the process they came from, and rpc exports can be called on all the targets
+// at once.
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/telco/telco-go/telco"
+)
+
+const defaultConcurrency = 8
+
+var (
+	// ErrNoDevices is returned when no device matches the DeviceSelector.
+	ErrNoDevices = errors.New("no devices matched")
+	// ErrNoTargets is returned when no process matches the TargetSelector on any device.
+	ErrNoTargets = errors.New("no targets matched")
+)
+
+// DeviceSelector selects the devices the script is run on.
+type DeviceSelector = telco.DeviceSelector
+
+// TargetSelector selects the processes the script is loaded into on every device.
+type TargetSelector struct {
+	// Query selects the processes; use telco.ParseQuery or telco.Where to build it.
+	Query telco.Query
+}
+
+// TargetName returns the TargetSelector matching processes with the name provided.
+func TargetName(name string) TargetSelector {
+	return TargetSelector{Query: telco.Query{Filter: telco.Where("name", telco.OpEq, name)}}
+}
+
+// ScriptSpec describes the script loaded into every target.
+type ScriptSpec struct {
+	Source string
+	// Name of the script, "telco-go" if empty.
+	Name    string
+	Runtime telco.ScriptRuntime
+}
+
+// Option configures Run.
+type Option func(*options)
+
+type options struct {
+	mgr         *telco.DeviceManager
+	concurrency int
+}
+
+// WithManager sets the manager the devices are resolved from; the default manager
+// is used otherwise.
+func WithManager(mgr *telco.DeviceManager) Option {
+	return func(o *options) {
+		o.mgr = mgr
+	}
+}
+
+// WithConcurrency limits how many targets are attached to and loaded at the same
+// time, as well as how many rpc calls are in flight. The default is 8.
+func WithConcurrency(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// Message is the message sent by the script on one of the targets.
+type Message struct {
+	DeviceID string
+	PID      int
+	// Raw is the message as received.
+	Raw     string
+	Data    []byte
+	Message *telco.Message
+}
+
+// Target is the process matched on the device along with the outcome of loading
+// the script into it. Devices whose processes could not be queried are reported
+// as targets with PID 0 and Err set.
+type Target struct {
+	DeviceID string
+	PID      int
+	Name     string
+	// Err is set if attaching or loading the script failed.
+	Err error
+
+	session *telco.Session
+	script  *telco.Script
+}
+
+// String returns the target as DEVICE/NAME(PID).
+func (t *Target) String() string {
+	return fmt.Sprintf("%s/%s(%d)", t.DeviceID, t.Name, t.PID)
+}
+
+// Loaded returns whether the script is loaded into the target.
+func (t *Target) Loaded() bool {
+	return t.script != nil
+}
+
+// PartialError is returned by Job.Err when the script could not be loaded
+// into some of the targets.
+type PartialError struct {
+	Failed []*Target
+	Total  int
+}
+
+// Error returns string representation of PartialError listing the failed targets.
+func (e *PartialError) Error() string {
+	failed := make([]string, len(e.Failed))
+	for i, t := range e.Failed {
+		failed[i] = fmt.Sprintf("%s: %v", t, t.Err)
+	}
+	return fmt.Sprintf("%d of %d targets failed: %s", len(e.Failed), e.Total, strings.Join(failed, "; "))
+}
+
+// Job is the script running on all the targets.
+type Job struct {
+	// devices are the devices matched by Run, released by Close.
+	devices     []*telco.Device
+	targets     []*Target
+	concurrency int
+
+	mu       sync.Mutex
+	queue    []Message
+	notify   chan struct{}
+	messages chan Message
+	closed   bool
+	stop     chan struct{}
+}
+
+// Run loads the script into every process matching targets on every device matching
+// devices. It returns once the script was loaded into all the targets or failed to;
+// targets which failed are reported by Job.Err. Run returns the error only if no
+// targets could be resolved at all. The job is closed once ctx is done. The devices
+// matched are owned by the job and released by Close; the others are released
+// right away.
+func Run(ctx context.Context, devices DeviceSelector, targets TargetSelector, spec ScriptSpec, opts ...Option) (*Job, error) {
+	o := options{concurrency: defaultConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.mgr == nil {
+		o.mgr = telco.DefaultDeviceManager()
+	}
+
+	devs, err := matchDevices(o.mgr, devices)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolveTargets(ctx, devs, targets.Query, o.concurrency)
+	if err != nil {
+		releaseDevices(devs)
+		return nil, err
+	}
+
+	j := &Job{
+		devices:     devs,
+		targets:     resolved,
+		concurrency: o.concurrency,
+		notify:      make(chan struct{}, 1),
+		messages:    make(chan Message),
+		stop:        make(chan struct{}),
+	}
+	go j.deliver()
+
+	devByID := make(map[string]*telco.Device, len(devs))
+	for _, dev := range devs {
+		devByID[dev.ID()] = dev
+	}
+	j.each(func(t *Target) {
+		if t.Err != nil {
+			return
+		}
+		if ctx.Err() != nil {
+			t.Err = telco.ErrContextCancelled
+			return
+		}
+		t.Err = j.load(devByID[t.DeviceID], t, spec)
+	})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			j.Close()
+		case <-j.stop:
+		}
+	}()
+
+	return j, nil
+}
+
+func matchDevices(mgr *telco.DeviceManager, sel DeviceSelector) ([]*telco.Device, error) {
+	all, err := mgr.EnumerateDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	devs, err := selectDevices(all, sel.Match, (*telco.Device).Clean)
+	if err != nil {
+		return nil, err
+	}
+	if len(devs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoDevices, sel)
+	}
+	return devs, nil
+}
+
+// selectDevices returns the devices matched by match and releases the others.
+// If matching fails all the devices are released.
+func selectDevices(all []*telco.Device, match func(dev *telco.Device) (bool, error), release func(dev *telco.Device)) ([]*telco.Device, error) {
+	var devs []*telco.Device
+	for i, dev := range all {
+		ok, err := match(dev)
+		if err != nil {
+			for _, d := range devs {
+				release(d)
+			}
+			for _, d := range all[i:] {
+				release(d)
+			}
+			return nil, fmt.Errorf("match %s: %w", dev.ID(), err)
+		}
+		if ok {
+			devs = append(devs, dev)
+		} else {
+			release(dev)
+		}
+	}
+	return devs, nil
+}
+
+func releaseDevices(devs []*telco.Device) {
+	for _, dev := range devs {
+		dev.Clean()
+	}
+}
+
+// resolveTargets queries the processes on all the devices concurrently.
+func resolveTargets(ctx context.Context, devs []*telco.Device, q telco.Query, concurrency int) ([]*Target, error) {
+	type result struct {
+		targets []*Target
+		err     error
+	}
+	results := make([]result, len(devs))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i, dev := range devs {
+		wg.Add(1)
+		go func(i int, dev *telco.Device) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			procs, err := dev.QueryProcesses(ctx, q)
+			if err != nil {
+				results[i].err = fmt.Errorf("query processes: %w", err)
+				return
+			}
+			for _, proc := range procs {
+				results[i].targets = append(results[i].targets, &Target{
+					DeviceID: dev.ID(),
+					PID:      proc.PID(),
+					Name:     proc.Name(),
+				})
+			}
+		}(i, dev)
+	}
+	wg.Wait()
+
+	var targets, failed []*Target
+	var errs []string
+	for i, res := range results {
+		targets = append(targets, res.targets...)
+		if res.err != nil {
+			failed = append(failed, &Target{DeviceID: devs[i].ID(), Err: res.err})
+			errs = append(errs, fmt.Sprintf("%s: %v", devs[i].ID(), res.err))
+		}
+	}
+	if len(targets) == 0 {
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrNoTargets, strings.Join(errs, "; "))
+		}
+		return nil, ErrNoTargets
+	}
+	// devices which could not be queried are reported as failed targets
+	targets = append(targets, failed...)
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].DeviceID != targets[j].DeviceID {
+			return targets[i].DeviceID < targets[j].DeviceID
+		}
+		return targets[i].PID < targets[j].PID
+	})
+	return targets, nil
+}
+
+func (j *Job) load(dev *telco.Device, t *Target, spec ScriptSpec) error {
+	session, err := dev.Attach(telco.TargetPID(t.PID), nil)
+	if err != nil {
+		return fmt.Errorf("attach: %w", err)
+	}
+
+	opts := telco.NewScriptOptions(spec.Name)
+	opts.SetRuntime(spec.Runtime)
+	script, err := session.CreateScriptWithOptions(spec.Source, opts)
+	if err != nil {
+		session.Detach()
+		return fmt.Errorf("create script: %w", err)
+	}
+
+	deviceID, pid := t.DeviceID, t.PID
+	script.On("message", func(raw string, data []byte) {
+		msg, _ := telco.ScriptMessageToMessage(raw)
+		j.push(Message{
+			DeviceID: deviceID,
+			PID:      pid,
+			Raw:      raw,
+			Data:     data,
+			Message:  msg,
+		})
+	})
+
+	if err := script.Load(); err != nil {
+		session.Detach()
+		return fmt.Errorf("load script: %w", err)
+	}
+
+	t.session = session
+	t.script = script
+	return nil
+}
+
+// each calls fn for every target, at most concurrency at the same time.
+func (j *Job) each(fn func(t *Target)) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, j.concurrency)
+	for _, t := range j.targets {
+		wg.Add(1)
+		go func(t *Target) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fn(t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+// push queues the message so the signal handler never blocks on the receiver.
+func (j *Job) push(msg Message) {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return
+	}
+	j.queue = append(j.queue, msg)
+	j.mu.Unlock()
+
+	select {
+	case j.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (j *Job) deliver() {
+	defer close(j.messages)
+
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-j.notify:
+		}
+
+		j.mu.Lock()
+		pending := j.queue
+		j.queue = nil
+		j.mu.Unlock()
+
+		for _, msg := range pending {
+			select {
+			case j.messages <- msg:
+			case <-j.stop:
+				return
+			}
+		}
+	}
+}
+
+// Messages returns the channel with the messages of all the targets. It is closed
+// once the job is closed.
+func (j *Job) Messages() <-chan Message {
+	return j.messages
+}
+
+// Targets returns all the targets matched, including the ones that failed.
+func (j *Job) Targets() []*Target {
+	return j.targets
+}
+
+// Err returns *PartialError if the script could not be loaded into some of the
+// targets, nil otherwise.
+func (j *Job) Err() error {
+	var failed []*Target
+	for _, t := range j.targets {
+		if t.Err != nil {
+			failed = append(failed, t)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &PartialError{Failed: failed, Total: len(j.targets)}
+}
+
+// CallResult is the result of the rpc call on one of the targets.
+type CallResult struct {
+	Target *Target
+	Value  any
+	Err    error
+}
+
+// Call calls the rpc export fn with args on all the targets the script is loaded
+// into and returns their results, ordered like Targets. Once the job is closed
+// every result fails with telco.ErrScriptDestroyed.
+func (j *Job) Call(ctx context.Context, fn string, args ...any) []CallResult {
+	var loaded []*Target
+	for _, t := range j.targets {
+		if t.Loaded() {
+			loaded = append(loaded, t)
+		}
+	}
+
+	results := make([]CallResult, len(loaded))
+	index := make(map[*Target]int, len(loaded))
+	for i, t := range loaded {
+		index[t] = i
+		results[i].Target = t
+	}
+
+	j.each(func(t *Target) {
+		i, ok := index[t]
+		if !ok {
+			return
+		}
+		if j.isClosed() || t.script.IsDestroyed() {
+			results[i].Err = telco.ErrScriptDestroyed
+			return
+		}
+		ret := t.script.ExportsCallWithContext(ctx, fn, args...)
+		if err, ok := ret.(error); ok {
+			results[i].Err = err
+			return
+		}
+		results[i].Value = ret
+	})
+	return results
+}
+
+func (j *Job) isClosed() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.closed
+}
+
+// Close unloads the script from all the targets, detaches from them and closes
+// the Messages channel.
+func (j *Job) Close() error {
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return nil
+	}
+	j.closed = true
+	j.mu.Unlock()
+
+	var mu sync.Mutex
+	var errs []string
+	j.each(func(t *Target) {
+		if !t.Loaded() {
+			return
+		}
+		err := t.script.Unload()
+		if derr := t.session.Detach(); err == nil {
+			err = derr
+		}
+		if err != nil {
+			mu.Lock()
+			errs = append(errs, fmt.Sprintf("%s: %v", t, err))
+			mu.Unlock()
+		}
+	})
+
+	close(j.stop)
+	releaseDevices(j.devices)
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package fleet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/telco/telco-go/telco"
+)
+
+func TestSelectDevices(t *testing.T) {
+	a, b, c := &telco.Device{}, &telco.Device{}, &telco.Device{}
+	names := map[*telco.Device]string{a: "a", b: "b", c: "c"}
+	errMatch := errors.New("params unavailable")
+
+	tests := []struct {
+		name     string
+		matched  map[*telco.Device]bool
+		failing  *telco.Device
+		want     []string
+		released []string
+		err      error
+	}{
+		{"all", map[*telco.Device]bool{a: true, b: true, c: true}, nil, []string{"a", "b", "c"}, nil, nil},
+		{"some", map[*telco.Device]bool{b: true}, nil, []string{"b"}, []string{"a", "c"}, nil},
+		{"none", nil, nil, nil, []string{"a", "b", "c"}, nil},
+		{"error", map[*telco.Device]bool{a: true}, b, nil, []string{"a", "b", "c"}, errMatch},
+	}
+
+	for _, tt := range tests {
+		var released []string
+		devs, err := selectDevices([]*telco.Device{a, b, c},
+			func(dev *telco.Device) (bool, error) {
+				if dev == tt.failing {
+					return false, errMatch
+				}
+				return tt.matched[dev], nil
+			},
+			func(dev *telco.Device) {
+				released = append(released, names[dev])
+			})
+
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+		var got []string
+		for _, dev := range devs {
+			got = append(got, names[dev])
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: selected %v, want %v", tt.name, got, tt.want)
+		}
+		if !reflect.DeepEqual(released, tt.released) {
+			t.Errorf("%s: released %v, want %v", tt.name, released, tt.released)
+		}
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	err := &PartialError{
+		Failed: []*Target{{DeviceID: "usb", Name: "app", PID: 42, Err: errors.New("attach: denied")}},
+		Total:  3,
+	}
+	if got, want := err.Error(), "1 of 3 targets failed: usb/app(42): attach: denied"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
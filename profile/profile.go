@@ -0,0 +1,279 @@
+// Package profile runs declarative instrumentation profiles.
+//
+// The profile describes the device, the processes to instrument, the scripts to
+// load into them and where the messages sent by the scripts go:
+//
+//	{
+//		"name": "ssl-keys",
+//		"device": {"types": ["usb"]},
+//		"target": {"gate": "com.example.*"},
+//		"session": {"realm": "native"},
+//		"scripts": [{"file": "agent.js", "runtime": "v8"}],
+//		"sinks": [{"type": "file", "path": "keys.jsonl", "types": ["send"]}]
+//	}
+//
+// Profiles are JSON by default; any decoder accepting the json and yaml struct
+// tags, such as yaml.Unmarshal, can be set with WithUnmarshal.
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/telco/telco-go/telco"
+)
+
+// ErrDetached is returned by Run when the session with the attached or spawned
+// process is detached.
+var ErrDetached = errors.New("session detached")
+
+// Profile describes the instrumentation run by Run.
+type Profile struct {
+	Name    string               `json:"name,omitempty" yaml:"name,omitempty"`
+	Device  telco.DeviceSelector `json:"device" yaml:"device"`
+	Target  Target               `json:"target" yaml:"target"`
+	Session telco.SessionConfig  `json:"session,omitempty" yaml:"session,omitempty"`
+	Scripts []Script             `json:"scripts" yaml:"scripts"`
+	// Sinks receive the messages sent by the scripts; stdout is used if empty.
+	Sinks []Sink `json:"sinks,omitempty" yaml:"sinks,omitempty"`
+
+	// dir is the directory the relative paths are resolved against.
+	dir string
+}
+
+// Target selects the processes the scripts are loaded into; exactly one of the
+// fields needs to be set.
+type Target struct {
+	// Name attaches to the process with the name, see telco.TargetName.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// PID attaches to the process with the pid.
+	PID int `json:"pid,omitempty" yaml:"pid,omitempty"`
+	// Spawn spawns the program and resumes it once the scripts are loaded.
+	Spawn *Spawn `json:"spawn,omitempty" yaml:"spawn,omitempty"`
+	// Gate enables spawn gating and instruments every spawn whose identifier
+	// matches the glob pattern, as accepted by path.Match; the others are resumed.
+	Gate string `json:"gate,omitempty" yaml:"gate,omitempty"`
+}
+
+// String returns the human readable description of the target.
+func (t Target) String() string {
+	switch {
+	case t.Name != "":
+		return fmt.Sprintf("name %q", t.Name)
+	case t.PID != 0:
+		return fmt.Sprintf("pid %d", t.PID)
+	case t.Spawn != nil:
+		return fmt.Sprintf("spawn %q", t.Spawn.Program)
+	case t.Gate != "":
+		return fmt.Sprintf("gate %q", t.Gate)
+	}
+	return "no target"
+}
+
+// Spawn describes the program spawned by the profile.
+type Spawn struct {
+	Program           string `json:"program" yaml:"program"`
+	telco.SpawnConfig `yaml:",inline"`
+}
+
+// Script describes the script loaded into every target; exactly one of File,
+// Entrypoint, Bytecode and Source needs to be set.
+type Script struct {
+	// Name of the script, the base name of the file if empty.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// File is the path to the script source or the bundle already built.
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+	// Entrypoint is the path to the entrypoint built with telco.Compiler.
+	Entrypoint string `json:"entrypoint,omitempty" yaml:"entrypoint,omitempty"`
+	// Build configures the build of the entrypoint; a relative project root is
+	// resolved against the profile directory.
+	Build telco.BuildOptions `json:"build,omitempty" yaml:"build,omitempty"`
+	// Bytecode is the path to the script compiled with Session.CompileScript.
+	Bytecode string `json:"bytecode,omitempty" yaml:"bytecode,omitempty"`
+	// Source is the script source inlined into the profile.
+	Source string `json:"source,omitempty" yaml:"source,omitempty"`
+	// Snapshot is the path to the snapshot created with Session.SnapshotScript.
+	Snapshot          string                      `json:"snapshot,omitempty" yaml:"snapshot,omitempty"`
+	SnapshotTransport telco.SnapshotTransportName `json:"snapshot_transport,omitempty" yaml:"snapshot_transport,omitempty"`
+	Runtime           telco.ScriptRuntimeName     `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+}
+
+func (s Script) name() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.File != "":
+		return filepath.Base(s.File)
+	case s.Entrypoint != "":
+		return filepath.Base(s.Entrypoint)
+	case s.Bytecode != "":
+		return filepath.Base(s.Bytecode)
+	}
+	return "telco-go"
+}
+
+// Sink types supported.
+const (
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkFile   = "file"
+	SinkHTTP   = "http"
+)
+
+// Sink describes where the messages are written to, as JSON lines of Event.
+type Sink struct {
+	// Type is one of "stdout", "stderr", "file" or "http".
+	Type string `json:"type" yaml:"type"`
+	// Path is the file the messages are appended to.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+	// URL is the endpoint every message is POSTed to.
+	URL string `json:"url,omitempty" yaml:"url,omitempty"`
+	// Types limits the sink to the message types, e.g. "send" or "log".
+	Types []telco.MessageType `json:"types,omitempty" yaml:"types,omitempty"`
+}
+
+// Load reads the profile from the file. Relative paths inside the profile are
+// resolved against the directory of the file.
+func Load(file string, opts ...Option) (*Profile, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := Parse(content, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	p.dir = filepath.Dir(file)
+	return p, nil
+}
+
+// Parse decodes and validates the profile. Relative paths inside the profile are
+// resolved against the working directory.
+func Parse(content []byte, opts ...Option) (*Profile, error) {
+	o := newOptions(opts)
+
+	var p Profile
+	if err := o.unmarshal(content, &p); err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Validate returns the error if the profile is not valid.
+func (p *Profile) Validate() error {
+	if _, err := path.Match(p.Device.Name, ""); err != nil {
+		return fmt.Errorf("device: invalid name pattern: %w", err)
+	}
+	if err := p.Target.validate(); err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	if err := p.Session.Validate(); err != nil {
+		return fmt.Errorf("session: %w", err)
+	}
+
+	if len(p.Scripts) == 0 {
+		return errors.New("no scripts")
+	}
+	for i, s := range p.Scripts {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("script %d: %w", i, err)
+		}
+	}
+
+	for i, s := range p.Sinks {
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("sink %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (t Target) validate() error {
+	set := 0
+	for _, ok := range []bool{t.Name != "", t.PID != 0, t.Spawn != nil, t.Gate != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of name, pid, spawn and gate needs to be set")
+	}
+
+	switch {
+	case t.PID < 0:
+		return fmt.Errorf("invalid pid %d", t.PID)
+	case t.Spawn != nil:
+		if t.Spawn.Program == "" {
+			return errors.New("spawn without program")
+		}
+		return t.Spawn.Validate()
+	case t.Gate != "":
+		if _, err := path.Match(t.Gate, ""); err != nil {
+			return fmt.Errorf("invalid gate pattern: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s Script) validate() error {
+	set := 0
+	for _, src := range []string{s.File, s.Entrypoint, s.Bytecode, s.Source} {
+		if src != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of file, entrypoint, bytecode and source needs to be set")
+	}
+	if err := s.Build.Validate(); err != nil {
+		return fmt.Errorf("build: %w", err)
+	}
+	return s.config(nil).Validate()
+}
+
+// config returns the ScriptConfig for the script with the snapshot loaded.
+func (s Script) config(snapshot []byte) telco.ScriptConfig {
+	return telco.ScriptConfig{
+		Name:              s.name(),
+		Runtime:           s.Runtime,
+		Snapshot:          snapshot,
+		SnapshotTransport: s.SnapshotTransport,
+	}
+}
+
+func (s Sink) validate() error {
+	switch s.Type {
+	case SinkStdout, SinkStderr:
+	case SinkFile:
+		if s.Path == "" {
+			return errors.New("file sink without path")
+		}
+	case SinkHTTP:
+		u, err := url.Parse(s.URL)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid url %q", s.URL)
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q", s.Type)
+	}
+	return nil
+}
+
+// resolve returns the path relative to the profile directory.
+func (p *Profile) resolve(file string) string {
+	if file == "" || filepath.IsAbs(file) || p.dir == "" {
+		return file
+	}
+	return filepath.Join(p.dir, file)
+}
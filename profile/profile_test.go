@@ -0,0 +1,148 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/telco/telco-go/telco"
+)
+
+func TestParse(t *testing.T) {
+	content := []byte(`{
+		"name": "ssl-keys",
+		"device": {"types": ["usb"]},
+		"target": {"gate": "com.example.*"},
+		"session": {"realm": "native"},
+		"scripts": [{"file": "agent.js", "runtime": "v8"}],
+		"sinks": [{"type": "file", "path": "keys.jsonl", "types": ["send"]}]
+	}`)
+
+	p, err := Parse(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "ssl-keys" || p.Target.Gate != "com.example.*" {
+		t.Errorf("Parse() = %+v", p)
+	}
+	if len(p.Device.Types) != 1 || p.Device.Types[0] != telco.DeviceTypeName(telco.DeviceTypeUsb) {
+		t.Errorf("device types = %v, want [usb]", p.Device.Types)
+	}
+	if p.Session.Realm != telco.RealmName(telco.RealmNative) {
+		t.Errorf("session realm = %v, want native", p.Session.Realm)
+	}
+	if len(p.Scripts) != 1 || p.Scripts[0].Runtime != telco.ScriptRuntimeName(telco.ScriptRuntimeV8) || p.Scripts[0].name() != "agent.js" {
+		t.Errorf("scripts = %+v", p.Scripts)
+	}
+	if len(p.Sinks) != 1 || len(p.Sinks[0].Types) != 1 || p.Sinks[0].Types[0] != telco.MessageTypeSend {
+		t.Errorf("sinks = %+v", p.Sinks)
+	}
+
+	if _, err := Parse([]byte(`{"target": {"pid": 1}, "scripts": [{"source": "x"}], "sinks": [{"type": "pipe"}]}`)); err == nil || !strings.Contains(err.Error(), `unknown sink type "pipe"`) {
+		t.Errorf("Parse() with invalid sink error %v", err)
+	}
+	if _, err := Parse([]byte(`{`)); err == nil {
+		t.Error("Parse() of truncated profile succeeded")
+	}
+}
+
+func TestParseWithUnmarshal(t *testing.T) {
+	var called bool
+	unmarshal := func(data []byte, v any) error {
+		called = true
+		return json.Unmarshal([]byte(strings.ReplaceAll(string(data), "'", `"`)), v)
+	}
+
+	p, err := Parse([]byte(`{'target': {'name': 'Twitter'}, 'scripts': [{'source': 'x'}]}`), WithUnmarshal(unmarshal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called || p.Target.Name != "Twitter" {
+		t.Errorf("Parse() with custom unmarshal = %+v, called %t", p, called)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	scripts := []Script{{Source: "send(1);"}}
+
+	tests := []struct {
+		name    string
+		profile Profile
+		err     string
+	}{
+		{"pid", Profile{Target: Target{PID: 1}, Scripts: scripts}, ""},
+		{"spawn", Profile{Target: Target{Spawn: &Spawn{Program: "/bin/ls"}}, Scripts: scripts}, ""},
+		{"no target", Profile{Scripts: scripts}, "exactly one of name, pid, spawn and gate"},
+		{"two targets", Profile{Target: Target{Name: "a", PID: 1}, Scripts: scripts}, "exactly one of name, pid, spawn and gate"},
+		{"negative pid", Profile{Target: Target{PID: -1}, Scripts: scripts}, "invalid pid -1"},
+		{"spawn without program", Profile{Target: Target{Spawn: &Spawn{}}, Scripts: scripts}, "spawn without program"},
+		{"invalid gate", Profile{Target: Target{Gate: "["}, Scripts: scripts}, "invalid gate pattern"},
+		{"invalid device name", Profile{Device: telco.DeviceSelector{Name: "["}, Target: Target{PID: 1}, Scripts: scripts}, "device: invalid name pattern"},
+		{"no scripts", Profile{Target: Target{PID: 1}}, "no scripts"},
+		{"script without source", Profile{Target: Target{PID: 1}, Scripts: []Script{{Name: "a"}}}, "script 0: exactly one of file"},
+		{"script with two sources", Profile{Target: Target{PID: 1}, Scripts: []Script{{File: "a.js", Source: "x"}}}, "script 0: exactly one of file"},
+		{"file sink without path", Profile{Target: Target{PID: 1}, Scripts: scripts, Sinks: []Sink{{Type: SinkFile}}}, "sink 0: file sink without path"},
+		{"http sink", Profile{Target: Target{PID: 1}, Scripts: scripts, Sinks: []Sink{{Type: SinkHTTP, URL: "https://example.com/events"}}}, ""},
+		{"ftp sink", Profile{Target: Target{PID: 1}, Scripts: scripts, Sinks: []Sink{{Type: SinkHTTP, URL: "ftp://example.com"}}}, "invalid url"},
+	}
+
+	for _, tt := range tests {
+		err := tt.profile.Validate()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: Validate() error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	p := &Profile{dir: filepath.Join("profiles", "ssl")}
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"", ""},
+		{"agent.js", filepath.Join("profiles", "ssl", "agent.js")},
+		{filepath.Join(string(filepath.Separator), "abs", "agent.js"), filepath.Join(string(filepath.Separator), "abs", "agent.js")},
+	}
+	for _, tt := range tests {
+		if got := p.resolve(tt.file); got != tt.want {
+			t.Errorf("resolve(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	o := newOptions(nil)
+	if o.mgr != nil {
+		t.Error("default manager created before it is needed")
+	}
+	if o.reloadInterval != defaultReloadInterval {
+		t.Errorf("reload interval = %v, want %v", o.reloadInterval, defaultReloadInterval)
+	}
+
+	var reported error
+	o = newOptions([]Option{
+		WithUnmarshal(nil),
+		WithReloadInterval(-time.Second),
+		WithErrorHandler(func(err error) { reported = err }),
+	})
+	if o.unmarshal == nil {
+		t.Error("WithUnmarshal(nil) cleared the decoder")
+	}
+	if o.reloadInterval != defaultReloadInterval {
+		t.Errorf("reload interval after WithReloadInterval(-1s) = %v, want %v", o.reloadInterval, defaultReloadInterval)
+	}
+	errTest := errors.New("test")
+	o.reportError(errTest)
+	if reported != errTest {
+		t.Errorf("reported %v, want %v", reported, errTest)
+	}
+
+	o = newOptions([]Option{WithReloadInterval(time.Minute)})
+	if o.reloadInterval != time.Minute {
+		t.Errorf("reload interval = %v, want 1m", o.reloadInterval)
+	}
+}
@@ -0,0 +1,580 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/telco/telco-go/telco"
+)
+
+const defaultReloadInterval = 2 * time.Second
+
+// Option configures Parse, Load, Run and RunFile.
+type Option func(*options)
+
+type options struct {
+	mgr            *telco.DeviceManager
+	unmarshal      func(data []byte, v any) error
+	reloadInterval time.Duration
+	onError        func(error)
+	onEvent        func(Event)
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		unmarshal:      json.Unmarshal,
+		reloadInterval: defaultReloadInterval,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// manager returns the manager set by WithManager or the default one, which is
+// only created once it is needed.
+func (o *options) manager() *telco.DeviceManager {
+	if o.mgr == nil {
+		o.mgr = telco.DefaultDeviceManager()
+	}
+	return o.mgr
+}
+
+// WithManager sets the manager the device is looked up in; the default manager
+// is used otherwise.
+func WithManager(mgr *telco.DeviceManager) Option {
+	return func(o *options) {
+		o.mgr = mgr
+	}
+}
+
+// WithUnmarshal sets the function decoding the profile, json.Unmarshal by default.
+func WithUnmarshal(fn func(data []byte, v any) error) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.unmarshal = fn
+		}
+	}
+}
+
+// WithReloadInterval sets how often RunFile checks the profile for changes.
+func WithReloadInterval(interval time.Duration) Option {
+	return func(o *options) {
+		if interval > 0 {
+			o.reloadInterval = interval
+		}
+	}
+}
+
+// WithErrorHandler sets the callback receiving the errors which do not stop the
+// run, such as failing to instrument one of the gated spawns or to reload the profile.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *options) {
+		o.onError = fn
+	}
+}
+
+// WithEventHandler sets the callback receiving every message besides the sinks.
+func WithEventHandler(fn func(ev Event)) Option {
+	return func(o *options) {
+		o.onEvent = fn
+	}
+}
+
+func (o *options) reportError(err error) {
+	if o.onError != nil {
+		o.onError(err)
+	}
+}
+
+// Event is the message sent by the script, as written to the sinks.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Profile  string    `json:"profile,omitempty"`
+	DeviceID string    `json:"device"`
+	PID      int       `json:"pid"`
+	Script   string    `json:"script"`
+	// Message is the parsed message, nil if it could not be parsed.
+	Message *telco.Message `json:"message,omitempty"`
+	// Raw is the message as received when it could not be parsed.
+	Raw  string `json:"raw,omitempty"`
+	Data []byte `json:"data,omitempty"`
+}
+
+// Run executes the profile until ctx is done. It waits for the device matching
+// the selector, then attaches to or spawns the target and loads the scripts. For
+// the attached and spawned targets Run returns ErrDetached once the session is
+// detached; with the gate it keeps instrumenting the matching spawns. Scripts are
+// unloaded and sessions detached before Run returns.
+func (p *Profile) Run(ctx context.Context, opts ...Option) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	o := newOptions(opts)
+
+	scripts, err := p.loadScripts()
+	if err != nil {
+		return err
+	}
+
+	sinks, err := p.openSinks()
+	if err != nil {
+		return err
+	}
+
+	dev, err := o.manager().WaitForDevice(ctx, p.Device)
+	if err != nil {
+		closeSinks(sinks)
+		return err
+	}
+	defer dev.Clean()
+
+	r := &runner{
+		profile: p,
+		opts:    &o,
+		dev:     dev,
+		scripts: scripts,
+		sinks:   sinks,
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go r.deliver()
+	defer r.close()
+
+	switch {
+	case p.Target.Gate != "":
+		return r.gate(ctx)
+	case p.Target.Spawn != nil:
+		return r.spawn(ctx)
+	}
+	return r.attach(ctx)
+}
+
+// RunFile loads the profile from the file and runs it, restarting the run with the
+// new profile whenever the file changes. Profiles failing to load are reported to
+// the error handler and the current run is kept. RunFile returns nil once ctx is
+// done, or the error of the run if it stops on its own.
+func RunFile(ctx context.Context, file string, opts ...Option) error {
+	o := newOptions(opts)
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	p, err := Load(file, opts...)
+	if err != nil {
+		return err
+	}
+
+	start := func(p *Profile) (context.CancelFunc, chan error) {
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() {
+			done <- p.Run(runCtx, opts...)
+		}()
+		return cancel, done
+	}
+
+	cancel, done := start(p)
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	ticker := time.NewTicker(o.reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-done:
+			// keep done readable for the deferred wait
+			done <- err
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+		}
+
+		changed, err := os.ReadFile(file)
+		if err != nil {
+			o.reportError(fmt.Errorf("reload %s: %w", file, err))
+			continue
+		}
+		if bytes.Equal(changed, content) {
+			continue
+		}
+		content = changed
+
+		next, err := Load(file, opts...)
+		if err != nil {
+			o.reportError(fmt.Errorf("reload %s: %w", file, err))
+			continue
+		}
+
+		cancel()
+		<-done
+		cancel, done = start(next)
+	}
+}
+
+// loadedScript is the script source read before the run starts.
+type loadedScript struct {
+	spec     Script
+	source   string
+	bytecode []byte
+	snapshot []byte
+}
+
+func (p *Profile) loadScripts() ([]loadedScript, error) {
+	scripts := make([]loadedScript, len(p.Scripts))
+	for i, s := range p.Scripts {
+		ls := loadedScript{spec: s, source: s.Source}
+
+		switch {
+		case s.File != "":
+			content, err := os.ReadFile(p.resolve(s.File))
+			if err != nil {
+				return nil, fmt.Errorf("script %s: %w", s.name(), err)
+			}
+			ls.source = string(content)
+		case s.Entrypoint != "":
+			buildOpts := s.Build
+			buildOpts.ProjectRoot = p.resolve(buildOpts.ProjectRoot)
+			comp := telco.NewCompiler()
+			bundle, err := comp.Build(p.resolve(s.Entrypoint), &buildOpts)
+			comp.Clean()
+			if err != nil {
+				return nil, fmt.Errorf("script %s: build: %w", s.name(), err)
+			}
+			ls.source = bundle.Code
+		case s.Bytecode != "":
+			content, err := os.ReadFile(p.resolve(s.Bytecode))
+			if err != nil {
+				return nil, fmt.Errorf("script %s: %w", s.name(), err)
+			}
+			ls.bytecode = content
+		}
+
+		if s.Snapshot != "" {
+			content, err := os.ReadFile(p.resolve(s.Snapshot))
+			if err != nil {
+				return nil, fmt.Errorf("script %s: snapshot: %w", s.name(), err)
+			}
+			ls.snapshot = content
+		}
+		scripts[i] = ls
+	}
+	return scripts, nil
+}
+
+// instance is the process the scripts are loaded into.
+type instance struct {
+	pid      int
+	session  *telco.Session
+	scripts  []*telco.Script
+	detached chan telco.SessionDetachReason
+}
+
+type runner struct {
+	profile *Profile
+	opts    *options
+	dev     *telco.Device
+	scripts []loadedScript
+	sinks   []sink
+
+	mu        sync.Mutex
+	instances []*instance
+	queue     []Event
+	notify    chan struct{}
+	stop      chan struct{}
+	stopped   chan struct{}
+}
+
+func (r *runner) attach(ctx context.Context) error {
+	target := telco.TargetPID(r.profile.Target.PID)
+	if r.profile.Target.Name != "" {
+		target = telco.TargetName(r.profile.Target.Name)
+	}
+
+	pid, err := r.dev.ResolveTarget(target)
+	if err != nil {
+		return err
+	}
+
+	inst, err := r.instrument(pid)
+	if err != nil {
+		return err
+	}
+	return r.wait(ctx, inst)
+}
+
+func (r *runner) spawn(ctx context.Context) error {
+	spec := r.profile.Target.Spawn
+	spawnOpts, err := spec.Options()
+	if err != nil {
+		return err
+	}
+
+	pid, err := r.dev.Spawn(spec.Program, spawnOpts)
+	if err != nil {
+		return fmt.Errorf("spawn %s: %w", spec.Program, err)
+	}
+
+	inst, err := r.instrument(pid)
+	if err != nil {
+		r.dev.Kill(pid)
+		return err
+	}
+	if err := r.dev.Resume(pid); err != nil {
+		return fmt.Errorf("resume %d: %w", pid, err)
+	}
+	return r.wait(ctx, inst)
+}
+
+func (r *runner) wait(ctx context.Context, inst *instance) error {
+	select {
+	case <-ctx.Done():
+		return nil
+	case reason := <-inst.detached:
+		return fmt.Errorf("%w: %s", ErrDetached, reason)
+	}
+}
+
+func (r *runner) gate(ctx context.Context) error {
+	if err := r.dev.EnableSpawnGating(); err != nil {
+		return err
+	}
+	defer r.dev.DisableSpawnGating()
+
+	// watch before enumerating so the spawn gated in between is not missed
+	spawns, err := r.dev.WatchSpawns(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending, err := r.dev.EnumeratePendingSpawn()
+	if err != nil {
+		return err
+	}
+	seen := make(map[int]bool)
+	for _, spawn := range pending {
+		seen[spawn.PID()] = true
+		r.handleSpawn(spawn)
+	}
+
+	for spawn := range spawns {
+		if seen[spawn.PID()] {
+			delete(seen, spawn.PID())
+			spawn.Clean()
+			continue
+		}
+		r.handleSpawn(spawn)
+	}
+	return nil
+}
+
+// handleSpawn instruments the spawn if its identifier matches the gate and resumes it.
+func (r *runner) handleSpawn(spawn *telco.Spawn) {
+	pid, identifier := spawn.PID(), spawn.Identifier()
+	spawn.Clean()
+
+	if ok, _ := path.Match(r.profile.Target.Gate, identifier); ok {
+		if _, err := r.instrument(pid); err != nil {
+			r.opts.reportError(fmt.Errorf("instrument %s(%d): %w", identifier, pid, err))
+		}
+	}
+	if err := r.dev.Resume(pid); err != nil {
+		r.opts.reportError(fmt.Errorf("resume %s(%d): %w", identifier, pid, err))
+	}
+}
+
+// instrument attaches to the process and loads all the scripts into it.
+func (r *runner) instrument(pid int) (*instance, error) {
+	sessionOpts, err := r.profile.Session.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := r.dev.Attach(telco.TargetPID(pid), sessionOpts)
+	if err != nil {
+		return nil, fmt.Errorf("attach %d: %w", pid, err)
+	}
+
+	inst := &instance{
+		pid:      pid,
+		session:  session,
+		detached: make(chan telco.SessionDetachReason, 1),
+	}
+	session.On("detached", func(reason telco.SessionDetachReason, crash *telco.Crash) {
+		r.forget(inst)
+		select {
+		case inst.detached <- reason:
+		default:
+		}
+	})
+
+	for _, ls := range r.scripts {
+		script, err := r.createScript(session, ls)
+		if err == nil {
+			name := ls.spec.name()
+			script.On("message", func(raw string, data []byte) {
+				r.push(pid, name, raw, data)
+			})
+			err = script.Load()
+		}
+		if err != nil {
+			r.unload(inst)
+			return nil, fmt.Errorf("script %s: %w", ls.spec.name(), err)
+		}
+		inst.scripts = append(inst.scripts, script)
+	}
+
+	r.mu.Lock()
+	r.instances = append(r.instances, inst)
+	r.mu.Unlock()
+	// the process may have exited before the instance was tracked
+	if session.IsDetached() {
+		r.forget(inst)
+	}
+	return inst, nil
+}
+
+// forget drops the detached instance, so gated processes exiting over time
+// are not kept until the profile stops.
+func (r *runner) forget(inst *instance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, other := range r.instances {
+		if other == inst {
+			r.instances = append(r.instances[:i], r.instances[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *runner) createScript(session *telco.Session, ls loadedScript) (*telco.Script, error) {
+	scriptOpts, err := ls.spec.config(ls.snapshot).Options()
+	if err != nil {
+		return nil, err
+	}
+	if ls.bytecode != nil {
+		return session.CreateScriptBytes(ls.bytecode, scriptOpts)
+	}
+	return session.CreateScriptWithOptions(ls.source, scriptOpts)
+}
+
+func (r *runner) unload(inst *instance) {
+	for _, script := range inst.scripts {
+		if err := script.Unload(); err != nil {
+			r.opts.reportError(fmt.Errorf("unload %d: %w", inst.pid, err))
+		}
+	}
+	if !inst.session.IsDetached() {
+		inst.session.Detach()
+	}
+}
+
+// push queues the message so the signal handler never blocks on the sinks.
+func (r *runner) push(pid int, script, raw string, data []byte) {
+	ev := Event{
+		Time:     time.Now(),
+		Profile:  r.profile.Name,
+		DeviceID: r.dev.ID(),
+		PID:      pid,
+		Script:   script,
+		Data:     data,
+	}
+	if msg, err := telco.ScriptMessageToMessage(raw); err == nil {
+		ev.Message = msg
+	} else {
+		ev.Raw = raw
+	}
+
+	r.mu.Lock()
+	r.queue = append(r.queue, ev)
+	r.mu.Unlock()
+
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (r *runner) deliver() {
+	defer close(r.stopped)
+
+	for {
+		var stopping bool
+		select {
+		case <-r.stop:
+			stopping = true
+		case <-r.notify:
+		}
+
+		r.mu.Lock()
+		pending := r.queue
+		r.queue = nil
+		r.mu.Unlock()
+
+		for _, ev := range pending {
+			r.write(ev)
+		}
+		if stopping {
+			return
+		}
+	}
+}
+
+func (r *runner) write(ev Event) {
+	if r.opts.onEvent != nil {
+		r.opts.onEvent(ev)
+	}
+	for _, s := range r.sinks {
+		if !s.accepts(ev) {
+			continue
+		}
+		if err := s.write(ev); err != nil {
+			r.opts.reportError(err)
+		}
+	}
+}
+
+// close unloads the scripts, flushes the pending messages and closes the sinks.
+func (r *runner) close() {
+	r.mu.Lock()
+	instances := r.instances
+	r.instances = nil
+	r.mu.Unlock()
+
+	for _, inst := range instances {
+		r.unload(inst)
+	}
+
+	close(r.stop)
+	<-r.stopped
+
+	if err := closeSinks(r.sinks); err != nil {
+		r.opts.reportError(err)
+	}
+}
+
+// closeSinks closes all the sinks and returns the first error.
+func closeSinks(sinks []sink) error {
+	var first error
+	for _, s := range sinks {
+		if err := s.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/telco/telco-go/telco"
+)
+
+const httpSinkTimeout = 10 * time.Second
+
+type sink interface {
+	accepts(ev Event) bool
+	write(ev Event) error
+	close() error
+}
+
+// typeFilter accepts the events whose message type is in types, all if empty.
+type typeFilter []telco.MessageType
+
+func (f typeFilter) accepts(ev Event) bool {
+	if len(f) == 0 {
+		return true
+	}
+	if ev.Message == nil {
+		return false
+	}
+	for _, typ := range f {
+		if ev.Message.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// writerSink writes the events as JSON lines.
+type writerSink struct {
+	typeFilter
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+func (s *writerSink) write(ev Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(ev)
+}
+
+func (s *writerSink) close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// httpSink POSTs every event as JSON to the url.
+type httpSink struct {
+	typeFilter
+	url    string
+	client *http.Client
+}
+
+func (s *httpSink) write(ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("sink %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+func (s *httpSink) close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func (p *Profile) openSinks() ([]sink, error) {
+	specs := p.Sinks
+	if len(specs) == 0 {
+		specs = []Sink{{Type: SinkStdout}}
+	}
+
+	sinks := make([]sink, 0, len(specs))
+	for _, spec := range specs {
+		s, err := p.openSink(spec)
+		if err != nil {
+			closeSinks(sinks)
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+func (p *Profile) openSink(spec Sink) (sink, error) {
+	filter := typeFilter(spec.Types)
+
+	switch spec.Type {
+	case SinkStderr:
+		return &writerSink{typeFilter: filter, enc: json.NewEncoder(os.Stderr)}, nil
+	case SinkFile:
+		f, err := os.OpenFile(p.resolve(spec.Path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("sink: %w", err)
+		}
+		return &writerSink{typeFilter: filter, enc: json.NewEncoder(f), closer: f}, nil
+	case SinkHTTP:
+		return &httpSink{
+			typeFilter: filter,
+			url:        spec.URL,
+			client:     &http.Client{Timeout: httpSinkTimeout},
+		}, nil
+	}
+	return &writerSink{typeFilter: filter, enc: json.NewEncoder(os.Stdout)}, nil
+}
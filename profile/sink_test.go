@@ -0,0 +1,74 @@
+package profile
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/telco/telco-go/telco"
+)
+
+func TestTypeFilter(t *testing.T) {
+	send := Event{Message: &telco.Message{Type: telco.MessageTypeSend}}
+	log := Event{Message: &telco.Message{Type: telco.MessageTypeLog}}
+	raw := Event{Raw: "garbage"}
+
+	tests := []struct {
+		types []telco.MessageType
+		ev    Event
+		want  bool
+	}{
+		{nil, send, true},
+		{nil, raw, true},
+		{[]telco.MessageType{telco.MessageTypeSend}, send, true},
+		{[]telco.MessageType{telco.MessageTypeSend}, log, false},
+		{[]telco.MessageType{telco.MessageTypeSend}, raw, false},
+		{[]telco.MessageType{telco.MessageTypeError, telco.MessageTypeLog}, log, true},
+	}
+	for _, tt := range tests {
+		if got := typeFilter(tt.types).accepts(tt.ev); got != tt.want {
+			t.Errorf("typeFilter(%v).accepts(%+v) = %t, want %t", tt.types, tt.ev, got, tt.want)
+		}
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	p := &Profile{
+		dir:   t.TempDir(),
+		Sinks: []Sink{{Type: SinkFile, Path: "events.jsonl", Types: []telco.MessageType{telco.MessageTypeSend}}},
+	}
+	sinks, err := p.openSinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &runner{profile: p, opts: &options{}, sinks: sinks}
+	r.write(Event{PID: 1, Script: "a", Message: &telco.Message{Type: telco.MessageTypeSend, Payload: "first"}})
+	r.write(Event{PID: 1, Script: "a", Message: &telco.Message{Type: telco.MessageTypeLog, Payload: "skipped"}})
+	r.write(Event{PID: 2, Script: "b", Raw: "skipped"})
+	r.write(Event{PID: 2, Script: "b", Message: &telco.Message{Type: telco.MessageTypeSend, Payload: "second"}})
+	if err := closeSinks(sinks); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(filepath.Join(p.dir, "events.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var payloads []any
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		payloads = append(payloads, ev.Message.Payload)
+	}
+	if len(payloads) != 2 || payloads[0] != "first" || payloads[1] != "second" {
+		t.Errorf("file sink wrote %v, want [first second]", payloads)
+	}
+}
@@ -0,0 +1,211 @@
+package symbolicate
+
+import (
+	"debug/dwarf"
+	"sort"
+)
+
+// unit is the compile unit with the address ranges it covers.
+type unit struct {
+	offset dwarf.Offset
+	ranges [][2]uint64
+}
+
+func (u *unit) contains(addr uint64) bool {
+	for _, rng := range u.ranges {
+		if addr >= rng[0] && addr < rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func readUnits(d *dwarf.Data) []*unit {
+	var units []*unit
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagCompileUnit || e.Tag == dwarf.TagPartialUnit {
+			if ranges, err := d.Ranges(e); err == nil && len(ranges) > 0 {
+				units = append(units, &unit{offset: e.Offset, ranges: ranges})
+			}
+		}
+		r.SkipChildren()
+	}
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].ranges[0][0] < units[j].ranges[0][0]
+	})
+	return units
+}
+
+// attrMIPSLinkageName is DW_AT_MIPS_linkage_name emitted by compilers before DWARF 4.
+const attrMIPSLinkageName dwarf.Attr = 0x2007
+
+// location is the source position used while unwinding the inline chain.
+type location struct {
+	file   string
+	line   int
+	column int
+}
+
+// dwarfFrames resolves the address into the chain of the function containing it
+// and the functions inlined into it, innermost first.
+func (m *module) dwarfFrames(addr uint64) []Frame {
+	var u *unit
+	for _, cand := range m.units {
+		if cand.contains(addr) {
+			u = cand
+			break
+		}
+	}
+	if u == nil {
+		return nil
+	}
+
+	r := m.dwarf.Reader()
+	r.Seek(u.offset)
+	cu, err := r.Next()
+	if err != nil || cu == nil {
+		return nil
+	}
+
+	var chain []*dwarf.Entry
+	m.walk(r, addr, &chain)
+	if len(chain) == 0 {
+		return nil
+	}
+
+	var loc location
+	var files []*dwarf.LineFile
+	if lr, err := m.dwarf.LineReader(cu); err == nil && lr != nil {
+		files = lr.Files()
+		var le dwarf.LineEntry
+		if err := lr.SeekPC(addr, &le); err == nil && le.File != nil {
+			loc = location{file: le.File.Name, line: le.Line, column: le.Column}
+		}
+	}
+
+	frames := make([]Frame, 0, len(chain))
+	for i := len(chain) - 1; i >= 0; i-- {
+		e := chain[i]
+		frame := Frame{
+			Function:    m.entryName(e, dwarf.AttrName),
+			LinkageName: m.entryName(e, dwarf.AttrLinkageName),
+			File:        loc.file,
+			Line:        loc.line,
+			Column:      loc.column,
+			Inlined:     i > 0,
+		}
+		if frame.Function == "" {
+			frame.Function = frame.LinkageName
+		}
+		if i == 0 {
+			if low, ok := m.lowPC(e); ok && addr >= low {
+				frame.Offset = addr - low
+			}
+		}
+		frames = append(frames, frame)
+
+		// the caller of the inlined function is positioned at the call site
+		loc = location{}
+		if idx, ok := e.Val(dwarf.AttrCallFile).(int64); ok && idx >= 0 && int(idx) < len(files) && files[idx] != nil {
+			loc.file = files[idx].Name
+		}
+		if line, ok := e.Val(dwarf.AttrCallLine).(int64); ok {
+			loc.line = int(line)
+		}
+		if col, ok := e.Val(dwarf.AttrCallColumn).(int64); ok {
+			loc.column = int(col)
+		}
+	}
+	return frames
+}
+
+// walk reads the entries of the current level and appends the functions
+// containing the address to chain, descending into them to find inlined ones.
+func (m *module) walk(r *dwarf.Reader, addr uint64, chain *[]*dwarf.Entry) bool {
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || e.Tag == 0 {
+			return false
+		}
+
+		switch e.Tag {
+		case dwarf.TagSubprogram, dwarf.TagInlinedSubroutine:
+			if m.containsPC(e, addr) {
+				*chain = append(*chain, e)
+				if e.Children {
+					m.walk(r, addr, chain)
+				}
+				return true
+			}
+			if e.Children {
+				r.SkipChildren()
+			}
+		default:
+			// lexical blocks, namespaces and classes may hold functions
+			if e.Children && m.walk(r, addr, chain) {
+				return true
+			}
+		}
+	}
+}
+
+func (m *module) containsPC(e *dwarf.Entry, addr uint64) bool {
+	ranges, err := m.dwarf.Ranges(e)
+	if err != nil {
+		return false
+	}
+	for _, rng := range ranges {
+		if addr >= rng[0] && addr < rng[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *module) lowPC(e *dwarf.Entry) (uint64, bool) {
+	if low, ok := e.Val(dwarf.AttrLowpc).(uint64); ok {
+		return low, true
+	}
+	ranges, err := m.dwarf.Ranges(e)
+	if err != nil || len(ranges) == 0 {
+		return 0, false
+	}
+	return ranges[0][0], true
+}
+
+// entryName returns the attribute of the entry, following abstract origins of
+// inlined and out-of-line instances and the declarations of definitions.
+func (m *module) entryName(e *dwarf.Entry, attr dwarf.Attr) string {
+	for depth := 0; e != nil && depth < 8; depth++ {
+		if name, ok := e.Val(attr).(string); ok {
+			return name
+		}
+		if attr == dwarf.AttrLinkageName {
+			if name, ok := e.Val(attrMIPSLinkageName).(string); ok {
+				return name
+			}
+		}
+
+		off, ok := e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+		if !ok {
+			off, ok = e.Val(dwarf.AttrSpecification).(dwarf.Offset)
+		}
+		if !ok {
+			return ""
+		}
+
+		r := m.dwarf.Reader()
+		r.Seek(off)
+		next, err := r.Next()
+		if err != nil {
+			return ""
+		}
+		e = next
+	}
+	return ""
+}
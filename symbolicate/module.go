@@ -0,0 +1,217 @@
+package symbolicate
+
+import (
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+var errNotELF = errors.New("not an ELF file")
+
+// module is the ELF file which was added to the Symbolicator. Symbols and
+// DWARF are loaded lazily on the first lookup.
+type module struct {
+	path     string
+	buildID  string
+	hasDWARF bool
+	hasSyms  bool
+	hasLoads bool
+	// bias is the virtual address of the first loadable segment; module
+	// offsets are relative to it.
+	bias uint64
+
+	once    sync.Once
+	loadErr error
+	file    *elf.File
+	dwarf   *dwarf.Data
+	symbols []elfSymbol
+	units   []*unit
+}
+
+type elfSymbol struct {
+	name  string
+	value uint64
+	size  uint64
+}
+
+func openModule(p string) (*module, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(f, magic); err != nil || string(magic) != elf.ELFMAG {
+		return nil, fmt.Errorf("%s: %w", p, errNotELF)
+	}
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+
+	m := &module{
+		path:     p,
+		buildID:  readBuildID(ef),
+		hasDWARF: ef.Section(".debug_info") != nil,
+		hasSyms:  ef.Section(".symtab") != nil || ef.Section(".dynsym") != nil,
+	}
+	for _, prog := range ef.Progs {
+		if prog.Type == elf.PT_LOAD {
+			m.bias = prog.Vaddr &^ (prog.Align - 1)
+			if prog.Align == 0 {
+				m.bias = prog.Vaddr
+			}
+			m.hasLoads = true
+			break
+		}
+	}
+	return m, nil
+}
+
+// readBuildID returns the hex encoded GNU build-id note of the file.
+func readBuildID(f *elf.File) string {
+	sec := f.Section(".note.gnu.build-id")
+	if sec == nil {
+		return ""
+	}
+	data, err := sec.Data()
+	if err != nil || len(data) < 16 {
+		return ""
+	}
+
+	namesz := f.ByteOrder.Uint32(data[0:4])
+	descsz := f.ByteOrder.Uint32(data[4:8])
+	typ := f.ByteOrder.Uint32(data[8:12])
+	if typ != 3 /* NT_GNU_BUILD_ID */ {
+		return ""
+	}
+	start := 12 + align4(namesz)
+	if uint64(start)+uint64(descsz) > uint64(len(data)) {
+		return ""
+	}
+	return hex.EncodeToString(data[start : start+descsz])
+}
+
+func align4(n uint32) uint32 {
+	return (n + 3) &^ 3
+}
+
+func (m *module) score() int {
+	score := 0
+	if m.hasDWARF {
+		score += 2
+	}
+	if m.hasSyms {
+		score++
+	}
+	return score
+}
+
+func (m *module) load() error {
+	m.once.Do(func() {
+		m.file, m.loadErr = elf.Open(m.path)
+		if m.loadErr != nil {
+			return
+		}
+
+		m.symbols = readSymbols(m.file)
+		if m.hasDWARF {
+			if d, err := m.file.DWARF(); err == nil {
+				m.dwarf = d
+				m.units = readUnits(d)
+			}
+		}
+	})
+	return m.loadErr
+}
+
+func readSymbols(f *elf.File) []elfSymbol {
+	var syms []elfSymbol
+	add := func(list []elf.Symbol) {
+		for _, sym := range list {
+			if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || sym.Value == 0 {
+				continue
+			}
+			syms = append(syms, elfSymbol{name: sym.Name, value: sym.Value, size: sym.Size})
+		}
+	}
+	if list, err := f.Symbols(); err == nil {
+		add(list)
+	}
+	if list, err := f.DynamicSymbols(); err == nil {
+		add(list)
+	}
+	sort.Slice(syms, func(i, j int) bool {
+		return syms[i].value < syms[j].value
+	})
+	return syms
+}
+
+// symbolFor returns the function symbol containing the address.
+func (m *module) symbolFor(addr uint64) (elfSymbol, bool) {
+	idx := sort.Search(len(m.symbols), func(i int) bool {
+		return m.symbols[i].value > addr
+	}) - 1
+	for ; idx >= 0; idx-- {
+		sym := m.symbols[idx]
+		if sym.size == 0 || addr < sym.value+sym.size {
+			return sym, true
+		}
+		// aliases share the value, look at the previous one of the same value
+		if idx > 0 && m.symbols[idx-1].value != sym.value {
+			break
+		}
+	}
+	return elfSymbol{}, false
+}
+
+func (m *module) lookup(offset uint64) ([]Frame, error) {
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	addr := m.bias + offset
+
+	var frames []Frame
+	if m.dwarf != nil {
+		frames = m.dwarfFrames(addr)
+	}
+
+	sym, hasSym := m.symbolFor(addr)
+	if len(frames) == 0 {
+		if !hasSym {
+			return nil, fmt.Errorf("%w: %s+0x%x", ErrNoSymbols, m.path, offset)
+		}
+		return []Frame{{
+			Function:    sym.name,
+			LinkageName: sym.name,
+			Offset:      addr - sym.value,
+		}}, nil
+	}
+
+	outer := &frames[len(frames)-1]
+	if hasSym {
+		if outer.LinkageName == "" {
+			outer.LinkageName = sym.name
+		}
+		if outer.Offset == 0 {
+			outer.Offset = addr - sym.value
+		}
+	}
+	return frames, nil
+}
+
+func (m *module) close() error {
+	if m.file != nil {
+		return m.file.Close()
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+// Package symbolicate resolves module offsets found inside crash reports and
+// backtraces into functions, files and lines using local ELF binaries and
+// their debug files.
+//
+// Binaries are matched by their GNU build-id, so stripped binaries on the
+// device can be symbolicated with the unstripped or separate debug files kept
+// locally. When no build-id is known the module is matched by its file name.
+package symbolicate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/telco/telco-go/demangle"
+)
+
+var (
+	// ErrModuleNotFound is returned when there is no local file for the module.
+	ErrModuleNotFound = errors.New("module not found")
+	// ErrNoSymbols is returned when the module has no symbol for the offset.
+	ErrNoSymbols = errors.New("no symbols for address")
+)
+
+// Frame represents the resolved location. Single module offset resolves into
+// multiple frames when functions were inlined into each other.
+type Frame struct {
+	// Function is the name of the function.
+	Function string
+	// LinkageName is the mangled name of the function, if known.
+	LinkageName string
+	// Offset is the offset of the address from the start of the function.
+	// It is set only for the outermost (non inlined) frame.
+	Offset uint64
+	File   string
+	Line   int
+	Column int
+	// Inlined is true when the function was inlined into the next frame.
+	Inlined bool
+}
+
+// String returns the frame as "function+0xoffset (file:line)".
+func (f Frame) String() string {
+	var sb strings.Builder
+	sb.WriteString(f.Function)
+	if !f.Inlined && f.Offset != 0 {
+		fmt.Fprintf(&sb, "+0x%x", f.Offset)
+	}
+	if f.File != "" {
+		fmt.Fprintf(&sb, " (%s:%d)", f.File, f.Line)
+	}
+	if f.Inlined {
+		sb.WriteString(" [inlined]")
+	}
+	return sb.String()
+}
+
+// Symbolicator resolves module offsets using the files it was given.
+// It is safe for concurrent use.
+type Symbolicator struct {
+	mu       sync.Mutex
+	byID     map[string][]*module
+	byName   map[string][]*module
+	resolved map[string]*module
+	demangle bool
+}
+
+// New returns the Symbolicator using the files provided. Directories are
+// walked recursively and every ELF file inside them is added.
+func New(paths ...string) (*Symbolicator, error) {
+	s := &Symbolicator{
+		byID:     make(map[string][]*module),
+		byName:   make(map[string][]*module),
+		resolved: make(map[string]*module),
+	}
+	for _, p := range paths {
+		if err := s.Add(p); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add adds the ELF file or all the ELF files found inside the directory.
+// Files that are not ELF are skipped when walking the directory.
+func (s *Symbolicator) Add(p string) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return s.addFile(p)
+	}
+
+	return filepath.WalkDir(p, func(fp string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		if err := s.addFile(fp); err != nil && !errors.Is(err, errNotELF) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (s *Symbolicator) addFile(p string) error {
+	m, err := openModule(p)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m.buildID != "" {
+		s.byID[m.buildID] = append(s.byID[m.buildID], m)
+	}
+	name := moduleName(p)
+	s.byName[name] = append(s.byName[name], m)
+	return nil
+}
+
+// moduleName returns the name used to match the module, without the directory
+// and the ".debug" suffix of separate debug files.
+func moduleName(p string) string {
+	return strings.TrimSuffix(path.Base(filepath.ToSlash(p)), ".debug")
+}
+
+// find returns the best module for the name and build-id: the one with DWARF
+// if there is one, otherwise the one with the symbol table.
+func (s *Symbolicator) find(name, buildID string) (*module, error) {
+	buildID = strings.ToLower(buildID)
+	key := buildID + "\x00" + name
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if m, ok := s.resolved[key]; ok {
+		return m, nil
+	}
+
+	var candidates []*module
+	if buildID != "" {
+		candidates = s.byID[buildID]
+	}
+	if len(candidates) == 0 && name != "" {
+		for _, m := range s.byName[moduleName(name)] {
+			// name only matches when the build-id was not known on either side
+			if buildID == "" || m.buildID == "" {
+				candidates = append(candidates, m)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		if buildID != "" {
+			return nil, fmt.Errorf("%w: %s (build-id %s)", ErrModuleNotFound, name, buildID)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrModuleNotFound, name)
+	}
+
+	best := candidates[0]
+	for _, m := range candidates[1:] {
+		if m.score() > best.score() {
+			best = m
+		}
+	}
+	// debug files produced by some toolchains lack the program headers, so
+	// the load bias comes from the binary itself when both are present.
+	for _, m := range candidates {
+		if m.hasLoads && !best.hasLoads {
+			best.bias = m.bias
+			best.hasLoads = true
+		}
+	}
+
+	s.resolved[key] = best
+	return best, nil
+}
+
+// SetDemangle controls whether the function names are demangled. When enabled the
+// full demangled linkage name is used as the function name, including the scope
+// and the parameters of C++ functions.
+func (s *Symbolicator) SetDemangle(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.demangle = enabled
+}
+
+// Lookup resolves the offset inside the module into frames, innermost first.
+// module is the path or name of the module on the target and buildID its
+// hex encoded GNU build-id, which may be empty. The offset is relative to
+// the address the module was loaded at.
+func (s *Symbolicator) Lookup(module, buildID string, offset uint64) ([]Frame, error) {
+	m, err := s.find(module, buildID)
+	if err != nil {
+		return nil, err
+	}
+	frames, err := m.lookup(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	enabled := s.demangle
+	s.mu.Unlock()
+	if enabled {
+		for i := range frames {
+			name := frames[i].LinkageName
+			if name == "" {
+				name = frames[i].Function
+			}
+			if demangled, err := demangle.Demangle(name); err == nil {
+				frames[i].Function = demangled
+			}
+		}
+	}
+	return frames, nil
+}
+
+// Close closes all the files opened by the Symbolicator.
+func (s *Symbolicator) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for _, mods := range s.byName {
+		for _, m := range mods {
+			if err := m.close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// reTextFrame matches "module!0xoffset" and "module+0xoffset" found in backtraces
+// produced by the agents, e.g. DebugSymbol output of unresolved addresses.
+var reTextFrame = regexp.MustCompile(`([\w.\-]+)[!+](0x[0-9a-fA-F]+)`)
+
+// Text symbolicates every "module!0xoffset" and "module+0xoffset" inside the text,
+// appending the resolved frames to it. Unknown modules are left untouched.
+func (s *Symbolicator) Text(text string) string {
+	return reTextFrame.ReplaceAllStringFunc(text, func(match string) string {
+		sub := reTextFrame.FindStringSubmatch(match)
+		offset, err := strconv.ParseUint(sub[2][2:], 16, 64)
+		if err != nil {
+			return match
+		}
+		frames, err := s.Lookup(sub[1], "", offset)
+		if err != nil {
+			return match
+		}
+		names := make([]string, len(frames))
+		for i, f := range frames {
+			names[i] = f.String()
+		}
+		return match + " " + strings.Join(names, " <- ")
+	})
+}
@@ -0,0 +1,72 @@
+package telco
+
+//#include <telco-core.h>
+import "C"
+import (
+	"fmt"
+	"path/filepath"
+	"unsafe"
+)
+
+// BuildOptions configures Compiler.Build and Compiler.Watch.
+// The zero value builds from the current directory with the source maps included
+// and without compression. These are all the options the core compiler accepts;
+// the bundle format is fixed and parsed by Bundle.
+type BuildOptions struct {
+	// ProjectRoot is the directory the imports are resolved against and the
+	// sources in the source map are relative to.
+	ProjectRoot string        `json:"project_root,omitempty" yaml:"project_root,omitempty"`
+	SourceMaps  SourceMaps    `json:"source_maps,omitempty" yaml:"source_maps,omitempty"`
+	Compression JSCompression `json:"compression,omitempty" yaml:"compression,omitempty"`
+}
+
+// Validate returns the error if the options are not valid.
+func (o BuildOptions) Validate() error {
+	if o.SourceMaps < 0 || o.SourceMaps > SourceMapsOmitted {
+		return fmt.Errorf("invalid source maps %d", int(o.SourceMaps))
+	}
+	if o.Compression < 0 || o.Compression > JSCompressionTerser {
+		return fmt.Errorf("invalid compression %d", int(o.Compression))
+	}
+	return nil
+}
+
+func (o *BuildOptions) buildOptions() (*C.TelcoBuildOptions, error) {
+	opts := C.telco_build_options_new()
+	if err := o.apply((*C.TelcoCompilerOptions)(unsafe.Pointer(opts))); err != nil {
+		clean(unsafe.Pointer(opts), unrefTelco)
+		return nil, err
+	}
+	return opts, nil
+}
+
+func (o *BuildOptions) watchOptions() (*C.TelcoWatchOptions, error) {
+	opts := C.telco_watch_options_new()
+	if err := o.apply((*C.TelcoCompilerOptions)(unsafe.Pointer(opts))); err != nil {
+		clean(unsafe.Pointer(opts), unrefTelco)
+		return nil, err
+	}
+	return opts, nil
+}
+
+func (o *BuildOptions) apply(opts *C.TelcoCompilerOptions) error {
+	if o == nil {
+		return nil
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	if o.ProjectRoot != "" {
+		root, err := filepath.Abs(o.ProjectRoot)
+		if err != nil {
+			return err
+		}
+		rootC := C.CString(root)
+		defer C.free(unsafe.Pointer(rootC))
+		C.telco_compiler_options_set_project_root(opts, rootC)
+	}
+	C.telco_compiler_options_set_source_maps(opts, C.TelcoSourceMaps(o.SourceMaps))
+	C.telco_compiler_options_set_compression(opts, C.TelcoJsCompression(o.Compression))
+	return nil
+}
@@ -0,0 +1,94 @@
+package telco
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+const (
+	bundleMagic     = "📦\n"
+	bundleSeparator = "✄\n"
+	sourceMapPrefix = "//# sourceMappingURL=data:application/json"
+)
+
+// Bundle is the script built by the compiler.
+type Bundle struct {
+	// Code is the bundle as produced by the compiler, ready for Session.CreateScript.
+	Code string
+	// SourceMap is the source map of the bundle, empty if it was built with
+	// SourceMapsOmitted.
+	SourceMap string
+	// Files are the input files listed in the source map, relative to the project root.
+	Files []string
+}
+
+func newBundle(code string) *Bundle {
+	b := &Bundle{Code: code}
+
+	var maps []string
+	if modules, ok := parseBundleModules(code); ok {
+		for _, mod := range modules {
+			if strings.HasSuffix(mod.name, ".map") {
+				maps = append(maps, mod.data)
+			}
+		}
+	} else if i := strings.LastIndex(code, sourceMapPrefix); i != -1 {
+		_, encoded, _ := strings.Cut(code[i:], ";base64,")
+		if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded)); err == nil {
+			maps = append(maps, string(decoded))
+		}
+	}
+	if len(maps) == 0 {
+		return b
+	}
+	b.SourceMap = maps[0]
+
+	seen := make(map[string]bool)
+	for _, m := range maps {
+		var sm struct {
+			Sources []string `json:"sources"`
+		}
+		if err := json.Unmarshal([]byte(m), &sm); err != nil {
+			continue
+		}
+		for _, src := range sm.Sources {
+			if !seen[src] {
+				seen[src] = true
+				b.Files = append(b.Files, src)
+			}
+		}
+	}
+	return b
+}
+
+type bundleModule struct {
+	name string
+	data string
+}
+
+// parseBundleModules splits the bundle in the format emitted by the compiler: the
+// magic, the header listing the size and name of every module, the separator and
+// the modules separated by the separator.
+func parseBundleModules(code string) ([]bundleModule, bool) {
+	if !strings.HasPrefix(code, bundleMagic) {
+		return nil, false
+	}
+	header, body, found := strings.Cut(code[len(bundleMagic):], bundleSeparator)
+	if !found {
+		return nil, false
+	}
+
+	var modules []bundleModule
+	for _, line := range strings.Split(strings.TrimSuffix(header, "\n"), "\n") {
+		size, name, ok := strings.Cut(line, " ")
+		n, err := strconv.Atoi(size)
+		if !ok || err != nil || n < 0 || n > len(body) {
+			return nil, false
+		}
+		modules = append(modules, bundleModule{name: name, data: body[:n]})
+		body = strings.TrimPrefix(body[n:], "\n"+bundleSeparator)
+	}
+	return modules, true
+}
@@ -0,0 +1,80 @@
+package telco
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// makeBundle builds the bundle in the format emitted by the compiler from the
+// pairs of module names and contents.
+func makeBundle(modules ...string) string {
+	var header, body []string
+	for i := 0; i < len(modules); i += 2 {
+		header = append(header, fmt.Sprintf("%d %s", len(modules[i+1]), modules[i]))
+		body = append(body, modules[i+1])
+	}
+	return bundleMagic + strings.Join(header, "\n") + "\n" + bundleSeparator + strings.Join(body, "\n"+bundleSeparator)
+}
+
+func TestParseBundleModules(t *testing.T) {
+	code := makeBundle(
+		"/agent.js", "import { log } from './log.js';\nlog('hi');\n",
+		"/agent.js.map", `{"version":3,"sources":["agent.ts"]}`,
+		"/log.js", "export function log() {}\n",
+	)
+
+	modules, ok := parseBundleModules(code)
+	if !ok {
+		t.Fatal("bundle not parsed")
+	}
+	want := []bundleModule{
+		{"/agent.js", "import { log } from './log.js';\nlog('hi');\n"},
+		{"/agent.js.map", `{"version":3,"sources":["agent.ts"]}`},
+		{"/log.js", "export function log() {}\n"},
+	}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules = %q, want %q", modules, want)
+	}
+
+	for _, bad := range []string{
+		"console.log('not a bundle');",
+		bundleMagic + "12 /agent.js\n",
+		bundleMagic + "99 /agent.js\n" + bundleSeparator + "short",
+		bundleMagic + "x /agent.js\n" + bundleSeparator + "code",
+	} {
+		if _, ok := parseBundleModules(bad); ok {
+			t.Errorf("parseBundleModules(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestNewBundle(t *testing.T) {
+	code := makeBundle(
+		"/agent.js", "log('hi');\n",
+		"/agent.js.map", `{"version":3,"sources":["agent.ts","log.ts"]}`,
+		"/log.js", "export function log() {}\n",
+		"/log.js.map", `{"version":3,"sources":["log.ts","util.ts"]}`,
+	)
+	b := newBundle(code)
+	if b.Code != code || b.SourceMap != `{"version":3,"sources":["agent.ts","log.ts"]}` {
+		t.Errorf("bundle = %+v", b)
+	}
+	if want := []string{"agent.ts", "log.ts", "util.ts"}; !reflect.DeepEqual(b.Files, want) {
+		t.Errorf("files = %q, want %q", b.Files, want)
+	}
+
+	sm := `{"version":3,"sources":["agent.ts"]}`
+	inline := "log('hi');\n" + sourceMapPrefix + ";charset=utf-8;base64," + base64.StdEncoding.EncodeToString([]byte(sm)) + "\n"
+	b = newBundle(inline)
+	if b.SourceMap != sm || !reflect.DeepEqual(b.Files, []string{"agent.ts"}) {
+		t.Errorf("bundle with the inline source map = %+v", b)
+	}
+
+	b = newBundle("log('hi');\n")
+	if b.SourceMap != "" || b.Files != nil {
+		t.Errorf("bundle without source map = %+v", b)
+	}
+}
@@ -1,8 +1,23 @@
 package telco
 
-//#include <telco-core.h>
+/*
+#include <telco-core.h>
+
+static gchar * get_certificate_pem(GTlsCertificate * cert) {
+	gchar * pem = NULL;
+	g_object_get(cert, "certificate-pem", &pem, NULL);
+	return pem;
+}
+
+static gchar * get_private_key_pem(GTlsCertificate * cert) {
+	gchar * pem = NULL;
+	g_object_get(cert, "private-key-pem", &pem, NULL);
+	return pem;
+}
+*/
 import "C"
 import (
+	"strings"
 	"time"
 	"unsafe"
 )
@@ -17,6 +32,40 @@ type Certificate struct {
 	cert *C.GTlsCertificate
 }
 
+// PEM returns the PEM encoded certificate.
+func (c *Certificate) PEM() string {
+	if c.cert == nil {
+		return ""
+	}
+	pem := C.get_certificate_pem(c.cert)
+	defer C.g_free(C.gpointer(pem))
+	return C.GoString(pem)
+}
+
+// PrivateKeyPEM returns the PEM encoded private key of the certificate or an
+// empty string if it has none.
+func (c *Certificate) PrivateKeyPEM() string {
+	if c.cert == nil {
+		return ""
+	}
+	pem := C.get_private_key_pem(c.cert)
+	defer C.g_free(C.gpointer(pem))
+	return C.GoString(pem)
+}
+
+// configPEM returns the certificate followed by its private key, as accepted by
+// the Certificate fields of the configs.
+func (c *Certificate) configPEM() string {
+	pem := c.PEM()
+	if key := c.PrivateKeyPEM(); key != "" {
+		if !strings.HasSuffix(pem, "\n") {
+			pem += "\n"
+		}
+		pem += key
+	}
+	return pem
+}
+
 // IssuerName returns the issuer name for the certificate.
 func (c *Certificate) IssuerName() string {
 	iss := C.g_tls_certificate_get_issuer_name(c.cert)
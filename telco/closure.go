@@ -87,7 +87,7 @@ type funcstack struct {
 	Frames []uintptr
 }
 
-func connectClosure(obj unsafe.Pointer, sigName string, fn any) {
+func connectClosure(obj unsafe.Pointer, sigName string, fn any) C.gulong {
 	v := reflect.ValueOf(fn)
 
 	if v.Type().Kind() != reflect.Func {
@@ -110,7 +110,29 @@ func connectClosure(obj unsafe.Pointer, sigName string, fn any) {
 
 	// Do nothing if signal is 0 meaning not found
 	if int(sigID) != 0 {
-		C.g_signal_connect_closure_by_id((C.gpointer)(obj), sigID, 0, gclosure, C.gboolean(1))
+		return C.g_signal_connect_closure_by_id((C.gpointer)(obj), sigID, 0, gclosure, C.gboolean(1))
+	}
+	return 0
+}
+
+// signalHandler identifies the handler connected with connectSignal.
+type signalHandler struct {
+	obj unsafe.Pointer
+	id  C.gulong
+}
+
+// connectSignal is connectClosure returning the handler which can be disconnected later.
+func connectSignal(obj unsafe.Pointer, sigName string, fn any) signalHandler {
+	return signalHandler{
+		obj: obj,
+		id:  connectClosure(obj, sigName, fn),
+	}
+}
+
+// disconnect disconnects the handler from the signal.
+func (h signalHandler) disconnect() {
+	if h.id != 0 {
+		C.g_signal_handler_disconnect((C.gpointer)(h.obj), h.id)
 	}
 }
 
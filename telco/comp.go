@@ -4,14 +4,43 @@ package telco
  */
 import "C"
 import (
-	"reflect"
+	"context"
+	"sync"
 	"unsafe"
 )
 
 // Compiler type is used to compile scripts.
 type Compiler struct {
 	cc *C.TelcoCompiler
-	fn reflect.Value
+
+	// fsDirs are the directories BuildFS materialized, keyed by the content hash;
+	// fsOrder holds the hashes from the least to the most recently used.
+	fsMu    sync.Mutex
+	fsDirs  map[string]*fsDir
+	fsOrder []string
+}
+
+// BuildEventType is the kind of the BuildEvent.
+type BuildEventType int
+
+const (
+	BuildStarting BuildEventType = iota + 1
+	BuildFinished
+	BuildOutput
+	BuildDiagnostics
+)
+
+func (t BuildEventType) String() string {
+	return [...]string{"", "starting", "finished", "output", "diagnostics"}[t]
+}
+
+// BuildEvent is reported by Watch during every build.
+type BuildEvent struct {
+	Type BuildEventType
+	// Bundle is set for BuildOutput.
+	Bundle string
+	// Diagnostics is set for BuildDiagnostics.
+	Diagnostics []Diagnostic
 }
 
 // NewCompiler creates new compiler.
@@ -24,37 +53,128 @@ func NewCompiler() *Compiler {
 	}
 }
 
-// Build builds the script from the entrypoint.
-func (c *Compiler) Build(entrypoint string) (string, error) {
+// Build builds the script from the entrypoint. opts can be nil to build
+// from the current directory with the default options.
+func (c *Compiler) Build(entrypoint string, opts *BuildOptions) (*Bundle, error) {
+	buildOpts, err := opts.buildOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer clean(unsafe.Pointer(buildOpts), unrefTelco)
+
 	entrypointC := C.CString(entrypoint)
 	defer C.free(unsafe.Pointer(entrypointC))
 
-	var err *C.GError
-	ret := C.telco_compiler_build_sync(c.cc, entrypointC, nil, nil, &err)
-	if err != nil {
-		return "", &FError{err}
+	var gerr *C.GError
+	ret := C.telco_compiler_build_sync(c.cc, entrypointC, buildOpts, nil, &gerr)
+	if gerr != nil {
+		return nil, &FError{gerr}
+	}
+	defer C.g_free(C.gpointer(ret))
+
+	return newBundle(C.GoString(ret)), nil
+}
+
+// Watch builds the script from the entrypoint, rebuilds it whenever the files
+// change and reports the progress of every build on the returned channel. opts
+// can be nil to build from the current directory with the default options.
+// Events are queued, so slow receivers never block the compiler.
+//
+// The channel is closed once ctx is done. The core cannot stop watching on its
+// own, so the compiler keeps watching the files until it is released with Clean.
+func (c *Compiler) Watch(ctx context.Context, entrypoint string, opts *BuildOptions) (<-chan BuildEvent, error) {
+	var mu sync.Mutex
+	var queue []BuildEvent
+	notify := make(chan struct{}, 1)
+	push := func(ev BuildEvent) {
+		mu.Lock()
+		queue = append(queue, ev)
+		mu.Unlock()
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+
+	handlers := []signalHandler{
+		connectSignal(unsafe.Pointer(c.cc), "starting", func() {
+			push(BuildEvent{Type: BuildStarting})
+		}),
+		connectSignal(unsafe.Pointer(c.cc), "finished", func() {
+			push(BuildEvent{Type: BuildFinished})
+		}),
+		connectSignal(unsafe.Pointer(c.cc), "output", func(bundle string) {
+			push(BuildEvent{Type: BuildOutput, Bundle: bundle})
+		}),
+		connectSignal(unsafe.Pointer(c.cc), "diagnostics", func(diags any) {
+			push(BuildEvent{Type: BuildDiagnostics, Diagnostics: diagnosticsFromValue(diags)})
+		}),
+	}
+	disconnect := func() {
+		for _, h := range handlers {
+			h.disconnect()
+		}
 	}
 
-	return C.GoString(ret), nil
+	if err := c.watch(entrypoint, opts); err != nil {
+		disconnect()
+		return nil, err
+	}
+
+	events := make(chan BuildEvent)
+	go func() {
+		defer close(events)
+		defer disconnect()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			}
+
+			mu.Lock()
+			pending := queue
+			queue = nil
+			mu.Unlock()
+
+			for _, ev := range pending {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
 }
 
-// Watch watches for changes at the entrypoint and sends the "output" signal.
-func (c *Compiler) Watch(entrypoint string) error {
+func (c *Compiler) watch(entrypoint string, opts *BuildOptions) error {
+	watchOpts, err := opts.watchOptions()
+	if err != nil {
+		return err
+	}
+	defer clean(unsafe.Pointer(watchOpts), unrefTelco)
+
 	entrypointC := C.CString(entrypoint)
 	defer C.free(unsafe.Pointer(entrypointC))
 
-	var err *C.GError
-	C.telco_compiler_watch_sync(c.cc, entrypointC, nil, nil, &err)
-	if err != nil {
-		return &FError{err}
+	var gerr *C.GError
+	C.telco_compiler_watch_sync(c.cc, entrypointC, watchOpts, nil, &gerr)
+	if gerr != nil {
+		return &FError{gerr}
 	}
 
 	return nil
 }
 
 // Clean will clean resources held by the compiler.
+// The directories materialized by BuildFS are removed.
 func (c *Compiler) Clean() {
 	clean(unsafe.Pointer(c.cc), unrefTelco)
+	c.removeFSDirs()
 }
 
 // On connects compiler to specific signals. Once sigName is triggered,
@@ -64,20 +184,23 @@ func (c *Compiler) Clean() {
 //   - "starting" with callback as func() {}
 //   - "finished" with callback as func() {}
 //   - "output" with callback as func(bundle string) {}
-//   - "diagnostics" with callback as func(diag string) {}
+//   - "diagnostics" with callback as func(diags []telco.Diagnostic) {} or
+//     func(diag string) {}, called with the text of every diagnostic
 //   - "file_changed" with callback as func() {}
 func (c *Compiler) On(sigName string, fn any) {
-	// hijack diagnostics and pass only text
 	if sigName == "diagnostics" {
-		c.fn = reflect.ValueOf(fn)
-		connectClosure(unsafe.Pointer(c.cc), sigName, c.hijackFn)
-	} else {
-		connectClosure(unsafe.Pointer(c.cc), sigName, fn)
+		switch f := fn.(type) {
+		case func([]Diagnostic):
+			fn = func(diags any) {
+				f(diagnosticsFromValue(diags))
+			}
+		case func(string):
+			fn = func(diags any) {
+				for _, diag := range diagnosticsFromValue(diags) {
+					f(diag.Text)
+				}
+			}
+		}
 	}
-}
-
-func (c *Compiler) hijackFn(diag map[string]any) {
-	text := diag["text"].(string)
-	args := []reflect.Value{reflect.ValueOf(text)}
-	c.fn.Call(args)
+	connectClosure(unsafe.Pointer(c.cc), sigName, fn)
 }
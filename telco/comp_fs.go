@@ -0,0 +1,213 @@
+package telco
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// maxFSDirs is how many materialized trees BuildFS keeps for reuse.
+const maxFSDirs = 4
+
+// fsDir is the temporary directory holding the tree materialized by BuildFS.
+type fsDir struct {
+	path string
+	// users is the number of builds using the directory, which is not removed
+	// while they run.
+	users int
+}
+
+// BuildFS builds the script from the entrypoint inside fsys, such as the embed.FS
+// holding the agent sources and its node_modules. The compiler needs real files, so
+// the tree is copied into the temporary directory first; the directories of the
+// last few contents built are reused by the builds of the same content, older ones
+// are removed, and Clean removes them all.
+//
+// entrypoint and opts.ProjectRoot are slash-separated paths inside fsys, the
+// project root defaults to the root of fsys. opts can be nil.
+func (c *Compiler) BuildFS(fsys fs.FS, entrypoint string, opts *BuildOptions) (*Bundle, error) {
+	var buildOpts BuildOptions
+	if opts != nil {
+		buildOpts = *opts
+	}
+
+	root := path.Clean(buildOpts.ProjectRoot)
+	if !fs.ValidPath(root) {
+		return nil, fmt.Errorf("invalid project root %q", buildOpts.ProjectRoot)
+	}
+	if !fs.ValidPath(entrypoint) {
+		return nil, fmt.Errorf("invalid entrypoint %q", entrypoint)
+	}
+
+	dir, err := c.materializeFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+	defer c.releaseFSDir(dir)
+
+	buildOpts.ProjectRoot = filepath.Join(dir, filepath.FromSlash(root))
+	return c.Build(filepath.Join(dir, filepath.FromSlash(entrypoint)), &buildOpts)
+}
+
+// materializeFS copies fsys into the temporary directory, unless the directory
+// with the same content was already created by the compiler. The directory is
+// kept until it is released with releaseFSDir.
+func (c *Compiler) materializeFS(fsys fs.FS) (string, error) {
+	sum, err := hashFS(fsys)
+	if err != nil {
+		return "", err
+	}
+
+	c.fsMu.Lock()
+	defer c.fsMu.Unlock()
+
+	if d, ok := c.fsDirs[sum]; ok {
+		d.users++
+		c.touchFSDir(sum)
+		return d.path, nil
+	}
+
+	dir, err := os.MkdirTemp("", "telco-go-build-"+sum[:16]+"-")
+	if err != nil {
+		return "", err
+	}
+	if err := copyFS(dir, fsys); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	if c.fsDirs == nil {
+		c.fsDirs = make(map[string]*fsDir)
+	}
+	c.fsDirs[sum] = &fsDir{path: dir, users: 1}
+	c.touchFSDir(sum)
+	c.evictFSDirs()
+	return dir, nil
+}
+
+// releaseFSDir marks the build using the directory as done.
+func (c *Compiler) releaseFSDir(dir string) {
+	c.fsMu.Lock()
+	defer c.fsMu.Unlock()
+
+	for _, d := range c.fsDirs {
+		if d.path == dir {
+			d.users--
+			break
+		}
+	}
+	c.evictFSDirs()
+}
+
+// touchFSDir moves the hash to the end of fsOrder; c.fsMu needs to be held.
+func (c *Compiler) touchFSDir(sum string) {
+	for i, s := range c.fsOrder {
+		if s == sum {
+			c.fsOrder = append(c.fsOrder[:i], c.fsOrder[i+1:]...)
+			break
+		}
+	}
+	c.fsOrder = append(c.fsOrder, sum)
+}
+
+// evictFSDirs removes the least recently used directories not in use while there
+// are more than maxFSDirs; c.fsMu needs to be held.
+func (c *Compiler) evictFSDirs() {
+	for i := 0; len(c.fsOrder) > maxFSDirs && i < len(c.fsOrder); {
+		sum := c.fsOrder[i]
+		d := c.fsDirs[sum]
+		if d.users > 0 {
+			i++
+			continue
+		}
+		os.RemoveAll(d.path)
+		delete(c.fsDirs, sum)
+		c.fsOrder = append(c.fsOrder[:i], c.fsOrder[i+1:]...)
+	}
+}
+
+func (c *Compiler) removeFSDirs() {
+	c.fsMu.Lock()
+	defer c.fsMu.Unlock()
+
+	for sum, d := range c.fsDirs {
+		os.RemoveAll(d.path)
+		delete(c.fsDirs, sum)
+	}
+	c.fsOrder = nil
+}
+
+// hashFS returns the hex encoded SHA-256 of the paths and the content of the files in fsys.
+func hashFS(fsys fs.FS) (string, error) {
+	h := sha256.New()
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			writeHashField(h, "d", []byte(name))
+			return nil
+		}
+
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		writeHashField(h, "f", []byte(name))
+		binary.Write(h, binary.LittleEndian, info.Size())
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeHashField writes the length prefixed field so different trees never hash the same.
+func writeHashField(h hash.Hash, kind string, field []byte) {
+	h.Write([]byte(kind))
+	binary.Write(h, binary.LittleEndian, uint64(len(field)))
+	h.Write(field)
+}
+
+func copyFS(dir string, fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+
+		src, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, src); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
@@ -0,0 +1,66 @@
+package telco
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMaterializeFS(t *testing.T) {
+	c := &Compiler{}
+	defer c.removeFSDirs()
+
+	tree := func(i int) fstest.MapFS {
+		return fstest.MapFS{"agent.ts": {Data: []byte(fmt.Sprintf("log(%d);\n", i))}}
+	}
+
+	first, err := c.materializeFS(tree(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(first, "agent.ts")); err != nil || string(data) != "log(0);\n" {
+		t.Fatalf("agent.ts = %q, %v", data, err)
+	}
+	again, err := c.materializeFS(tree(0))
+	if err != nil || again != first {
+		t.Fatalf("same content materialized into %s, want %s (%v)", again, first, err)
+	}
+	c.releaseFSDir(again)
+
+	// the first directory is still used, so the oldest unused one is removed
+	var dirs []string
+	for i := 1; i <= maxFSDirs; i++ {
+		dir, err := c.materializeFS(tree(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.releaseFSDir(dir)
+		dirs = append(dirs, dir)
+	}
+	if _, err := os.Stat(first); err != nil {
+		t.Errorf("directory in use removed: %v", err)
+	}
+	if _, err := os.Stat(dirs[0]); !os.IsNotExist(err) {
+		t.Errorf("least recently used directory kept: %v", err)
+	}
+
+	c.releaseFSDir(first)
+	last, err := c.materializeFS(tree(maxFSDirs + 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.releaseFSDir(last)
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("released directory kept: %v", err)
+	}
+	if len(c.fsDirs) != maxFSDirs {
+		t.Errorf("%d directories kept, want %d", len(c.fsDirs), maxFSDirs)
+	}
+	for _, dir := range append(dirs[1:], last) {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("recent directory removed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+package telco
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CrashSourceDevice  = "process_crashed"
+	CrashSourceSession = "session_detached"
+)
+
+// crashDuplicateWindow is the time inside which the same crash reported by both
+// the device and the session is collected only once.
+const crashDuplicateWindow = 10 * time.Second
+
+// ParsedReport returns the report of the crash parsed into structured fields.
+func (c *Crash) ParsedReport() *CrashReport {
+	return ParseCrashReport(c.Report())
+}
+
+// CrashCollector collects crashes reported by devices and sessions, parses them,
+// computes their signature and persists them inside the CrashStore.
+// The crashes received from the signals are persisted on the goroutine of the
+// collector, so the signal handlers never wait for the disk.
+type CrashCollector struct {
+	store *CrashStore
+
+	mu       sync.Mutex
+	handlers []signalHandler
+	onCrash  []func(rec *CrashRecord, isNew bool)
+	onError  []func(err error)
+	recent   map[string]time.Time
+	closed   bool
+
+	// queue holds the records received from the signals until persisted.
+	queue   []*CrashRecord
+	started bool
+	notify  chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// NewCrashCollector returns new crash collector persisting crashes inside store.
+func NewCrashCollector(store *CrashStore) *CrashCollector {
+	return &CrashCollector{
+		store:   store,
+		recent:  make(map[string]time.Time),
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Store returns the store of the collector.
+func (c *CrashCollector) Store() *CrashStore {
+	return c.store
+}
+
+// OnCrash registers fn to be called for every collected crash. isNew is true
+// when the signature of the crash was not seen before. For the crashes received
+// from the signals fn is called on the goroutine of the collector, in the order
+// they were reported.
+func (c *CrashCollector) OnCrash(fn func(rec *CrashRecord, isNew bool)) {
+	c.mu.Lock()
+	c.onCrash = append(c.onCrash, fn)
+	c.mu.Unlock()
+}
+
+// OnError registers fn to be called when the crash received from the signal
+// could not be persisted.
+func (c *CrashCollector) OnError(fn func(err error)) {
+	c.mu.Lock()
+	c.onError = append(c.onError, fn)
+	c.mu.Unlock()
+}
+
+// WatchDevice collects crashes reported with "process_crashed" signal of the device.
+func (c *CrashCollector) WatchDevice(d *Device) error {
+	if d.device == nil {
+		return errors.New("could not watch crashes for nil device")
+	}
+
+	deviceID := d.ID()
+	return c.watch(unsafe.Pointer(d.device), "process_crashed", func(crash *Crash) {
+		c.collectFromSignal(crash, CrashSourceDevice, deviceID)
+	})
+}
+
+// WatchSession collects crashes carried by the "detached" signal of the session.
+// deviceID is recorded as the device of the crashes.
+func (c *CrashCollector) WatchSession(s *Session, deviceID string) error {
+	if s.s == nil {
+		return errors.New("could not watch crashes for nil session")
+	}
+
+	return c.watch(unsafe.Pointer(s.s), "detached", func(reason SessionDetachReason, crash *Crash) {
+		if crash == nil || crash.crash == nil {
+			return
+		}
+		c.collectFromSignal(crash, CrashSourceSession, deviceID)
+	})
+}
+
+func (c *CrashCollector) watch(obj unsafe.Pointer, sigName string, fn any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return errors.New("crash collector is closed")
+	}
+	if !c.started {
+		c.started = true
+		go c.deliver()
+	}
+	c.handlers = append(c.handlers, connectSignal(obj, sigName, fn))
+	return nil
+}
+
+// collectFromSignal reads the crash, which is only valid during the signal
+// emission, and queues it for the goroutine of the collector.
+func (c *CrashCollector) collectFromSignal(crash *Crash, source, deviceID string) {
+	if rec, err := newCrashRecord(crash, source, deviceID); err == nil {
+		c.push(rec)
+	}
+}
+
+func (c *CrashCollector) push(rec *CrashRecord) {
+	c.mu.Lock()
+	c.queue = append(c.queue, rec)
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *CrashCollector) deliver() {
+	defer close(c.stopped)
+
+	for {
+		var stopping bool
+		select {
+		case <-c.stop:
+			stopping = true
+		case <-c.notify:
+		}
+
+		c.mu.Lock()
+		pending := c.queue
+		c.queue = nil
+		c.mu.Unlock()
+
+		for _, rec := range pending {
+			c.process(rec)
+		}
+		if stopping {
+			return
+		}
+	}
+}
+
+// process persists the record and calls the callbacks.
+func (c *CrashCollector) process(rec *CrashRecord) {
+	rec, isNew, err := c.persist(rec)
+
+	c.mu.Lock()
+	onCrash := append([]func(*CrashRecord, bool){}, c.onCrash...)
+	onError := append([]func(error){}, c.onError...)
+	c.mu.Unlock()
+
+	if err != nil {
+		for _, fn := range onError {
+			fn(err)
+		}
+		return
+	}
+	if rec == nil {
+		return
+	}
+	for _, fn := range onCrash {
+		fn(rec, isNew)
+	}
+}
+
+// Collect parses and persists the crash. source describes where the crash came
+// from (CrashSourceDevice, CrashSourceSession or anything else). If the same
+// crash was collected recently, nil record is returned.
+func (c *CrashCollector) Collect(crash *Crash, source, deviceID string) (*CrashRecord, bool, error) {
+	rec, err := newCrashRecord(crash, source, deviceID)
+	if err != nil {
+		return nil, false, err
+	}
+	return c.persist(rec)
+}
+
+func newCrashRecord(crash *Crash, source, deviceID string) (*CrashRecord, error) {
+	if crash == nil || crash.crash == nil {
+		return nil, errors.New("could not collect nil crash")
+	}
+
+	rec := &CrashRecord{
+		ID:          uuid.New().String(),
+		Source:      source,
+		DeviceID:    deviceID,
+		PID:         crash.PID(),
+		ProcessName: crash.ProcessName(),
+		Summary:     crash.Summary(),
+		Report:      crash.Report(),
+		Params:      crash.Params(),
+		Time:        time.Now(),
+	}
+	rec.Parsed = ParseCrashReport(rec.Report)
+	rec.Signature = rec.Parsed.Signature(rec.ProcessName)
+	return rec, nil
+}
+
+// persist adds the record to the store unless the same crash was collected recently.
+func (c *CrashCollector) persist(rec *CrashRecord) (*CrashRecord, bool, error) {
+	if c.seenRecently(rec, rec.Time) {
+		return nil, false, nil
+	}
+
+	isNew, err := c.store.Add(rec)
+	if err != nil {
+		return nil, false, err
+	}
+	return rec, isNew, nil
+}
+
+// seenRecently reports whether the same crash was collected inside crashDuplicateWindow.
+func (c *CrashCollector) seenRecently(rec *CrashRecord, now time.Time) bool {
+	sum := sha256.Sum256([]byte(rec.DeviceID + "\x00" + rec.Report))
+	key := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for k, t := range c.recent {
+		if now.Sub(t) > crashDuplicateWindow {
+			delete(c.recent, k)
+		}
+	}
+
+	if _, ok := c.recent[key]; ok {
+		return true
+	}
+	c.recent[key] = now
+	return false
+}
+
+// Close disconnects the collector from all the devices and sessions it watches
+// and waits until the crashes already received are persisted.
+func (c *CrashCollector) Close() {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	handlers := c.handlers
+	c.handlers = nil
+	c.closed = true
+	started := c.started
+	c.mu.Unlock()
+
+	for _, h := range handlers {
+		h.disconnect()
+	}
+	if started {
+		close(c.stop)
+		<-c.stopped
+	}
+}
@@ -0,0 +1,418 @@
+package telco
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// CrashReport represents the crash report parsed into structured fields.
+// Apple crash reports and Android tombstones are understood; for anything else
+// only the signal and the faulting address are looked up.
+type CrashReport struct {
+	Signal          string        `json:"signal,omitempty"`
+	SignalNumber    int           `json:"signalNumber,omitempty"`
+	ExceptionType   string        `json:"exceptionType,omitempty"`
+	FaultAddress    uint64        `json:"faultAddress,omitempty"`
+	HasFaultAddress bool          `json:"hasFaultAddress,omitempty"`
+	CrashedThread   int           `json:"crashedThread"`
+	Threads         []CrashThread `json:"threads,omitempty"`
+	Images          []BinaryImage `json:"images,omitempty"`
+}
+
+// CrashThread represents the thread backtrace inside the crash report.
+type CrashThread struct {
+	Index   int          `json:"index"`
+	Name    string       `json:"name,omitempty"`
+	Crashed bool         `json:"crashed,omitempty"`
+	Frames  []CrashFrame `json:"frames,omitempty"`
+}
+
+// CrashFrame represents the single frame of the thread backtrace.
+type CrashFrame struct {
+	Index        int    `json:"index"`
+	Module       string `json:"module,omitempty"`
+	Address      uint64 `json:"address,omitempty"`
+	ModuleOffset uint64 `json:"moduleOffset,omitempty"`
+	Symbol       string `json:"symbol,omitempty"`
+	SymbolOffset uint64 `json:"symbolOffset,omitempty"`
+	BuildID      string `json:"buildId,omitempty"`
+	File         string `json:"file,omitempty"`
+	Line         int    `json:"line,omitempty"`
+	// Inlined is true for frames of functions inlined into the next frame;
+	// they are added by Symbolicate and share the address of the next frame.
+	Inlined bool `json:"inlined,omitempty"`
+}
+
+// String returns the frame as module!symbol+offset or module+moduleOffset.
+func (f CrashFrame) String() string {
+	switch {
+	case f.Symbol != "":
+		return fmt.Sprintf("%s!%s+0x%x", f.Module, f.Symbol, f.SymbolOffset)
+	case f.Module != "":
+		return fmt.Sprintf("%s+0x%x", f.Module, f.ModuleOffset)
+	default:
+		return fmt.Sprintf("0x%x", f.Address)
+	}
+}
+
+// BinaryImage represents the module loaded in the process at the time of the crash.
+type BinaryImage struct {
+	Start   uint64 `json:"start"`
+	End     uint64 `json:"end,omitempty"`
+	Name    string `json:"name"`
+	Arch    string `json:"arch,omitempty"`
+	BuildID string `json:"buildId,omitempty"`
+	Path    string `json:"path,omitempty"`
+}
+
+// Crashed returns the thread that crashed or nil if it is not known.
+func (r *CrashReport) Crashed() *CrashThread {
+	for i := range r.Threads {
+		if r.Threads[i].Crashed {
+			return &r.Threads[i]
+		}
+	}
+	if len(r.Threads) > 0 {
+		return &r.Threads[0]
+	}
+	return nil
+}
+
+// Image returns the binary image containing the address or nil if there is none.
+func (r *CrashReport) Image(address uint64) *BinaryImage {
+	for i := range r.Images {
+		img := &r.Images[i]
+		if img.End != 0 && address >= img.Start && address <= img.End {
+			return img
+		}
+	}
+	return nil
+}
+
+// signatureFrames is the number of top frames of the crashed thread used to compute the signature.
+const signatureFrames = 5
+
+// Signature returns the hash identifying the crash site: the signal and the top
+// frames of the crashed thread. Addresses are not part of the signature so the same
+// crash has the same signature across runs even with ASLR.
+func (r *CrashReport) Signature(processName string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", processName, r.Signal, r.ExceptionType)
+	if crashed := r.Crashed(); crashed != nil {
+		n := 0
+		for _, frame := range crashed.Frames {
+			if frame.Inlined {
+				continue
+			}
+			if n == signatureFrames {
+				break
+			}
+			n++
+			switch {
+			case frame.Symbol != "":
+				fmt.Fprintf(h, "%s!%s\n", frame.Module, frame.Symbol)
+			default:
+				fmt.Fprintf(h, "%s+0x%x\n", frame.Module, frame.ModuleOffset)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+var (
+	reAppleException = regexp.MustCompile(`^Exception Type:\s+(\S+)(?:\s+\((SIG[A-Z]+)\))?`)
+	reAppleCodes     = regexp.MustCompile(`^Exception (?:Codes|Subtype):.*\bat\s+(0x[0-9a-fA-F]+)`)
+	reAppleTermSig   = regexp.MustCompile(`^Termination Signal:\s+.*?:\s*(\d+)`)
+	reAppleCrashed   = regexp.MustCompile(`^Crashed Thread:\s+(\d+)`)
+	reAppleThread    = regexp.MustCompile(`^Thread (\d+)( Crashed)?:{1,2}\s*(.*)$`)
+	reAppleThreadNm  = regexp.MustCompile(`^Thread (\d+) name:\s*(.*)$`)
+	reAppleFrame     = regexp.MustCompile(`^(\d+)\s+(.+?)\s+(0x[0-9a-fA-F]+)\s+(.*)$`)
+	reAppleImage     = regexp.MustCompile(`^\s*(0x[0-9a-fA-F]+)\s+-\s+(0x[0-9a-fA-F]+)\s+(.*)$`)
+	reAppleSymbol    = regexp.MustCompile(`^(.*?) \+ (\d+)(?:\s+\((.+):(\d+)\))?$`)
+
+	reAndroidSignal = regexp.MustCompile(`signal (\d+) \((SIG[A-Z]+)\)(?:, code .*?)?(?:, fault addr (0x[0-9a-fA-F]+|--------))?`)
+	reAndroidThread = regexp.MustCompile(`^pid: \d+, tid: (\d+), name: (.*?)(?:\s+>>>.*)?$`)
+	reAndroidFrame  = regexp.MustCompile(`^\s*#(\d+) pc ([0-9a-fA-F]+)\s+(\S.*?)(?: \((.+)\))?$`)
+	// reAndroidFrameInfo matches the build id and the offset inside the mapped file
+	// which follow the path and the symbol of the frame.
+	reAndroidFrameInfo = regexp.MustCompile(` \((?:BuildId: ([0-9a-fA-F]+)|offset 0x[0-9a-fA-F]+)\)`)
+
+	reGenericSignal = regexp.MustCompile(`\b(SIG[A-Z]{3,})\b`)
+	reGenericFault  = regexp.MustCompile(`(?i)(?:fault(?:ing)? addr(?:ess)?|at address)[:\s]+(0x[0-9a-fA-F]+)`)
+)
+
+var signalNumbers = map[string]int{
+	"SIGHUP": 1, "SIGINT": 2, "SIGQUIT": 3, "SIGILL": 4, "SIGTRAP": 5, "SIGABRT": 6,
+	"SIGBUS": 7, "SIGFPE": 8, "SIGKILL": 9, "SIGSEGV": 11, "SIGPIPE": 13, "SIGSYS": 31,
+}
+
+// ParseCrashReport parses the report returned by Crash.Report.
+func ParseCrashReport(report string) *CrashReport {
+	r := &CrashReport{CrashedThread: -1}
+	if strings.Contains(report, "Binary Images:") || strings.Contains(report, "Exception Type:") {
+		r.parseApple(report)
+	} else if strings.Contains(report, "backtrace:") || reAndroidSignal.MatchString(report) {
+		r.parseAndroid(report)
+	}
+
+	if r.Signal == "" {
+		if m := reGenericSignal.FindStringSubmatch(report); m != nil {
+			r.Signal = m[1]
+		}
+	}
+	if !r.HasFaultAddress {
+		if m := reGenericFault.FindStringSubmatch(report); m != nil {
+			r.FaultAddress, r.HasFaultAddress = parseHex(m[1])
+		}
+	}
+	if r.SignalNumber == 0 {
+		r.SignalNumber = signalNumbers[r.Signal]
+	}
+	if r.CrashedThread >= 0 {
+		for i := range r.Threads {
+			if r.Threads[i].Index == r.CrashedThread {
+				r.Threads[i].Crashed = true
+			}
+		}
+	}
+
+	return r
+}
+
+func parseHex(s string) (uint64, bool) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 64)
+	return v, err == nil
+}
+
+func (r *CrashReport) parseApple(report string) {
+	var thread *CrashThread
+	inImages := false
+	threadNames := make(map[int]string)
+
+	sc := bufio.NewScanner(strings.NewReader(report))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+
+		if inImages {
+			if img, ok := parseAppleImage(line); ok {
+				r.Images = append(r.Images, img)
+				continue
+			}
+			if strings.TrimSpace(line) != "" {
+				inImages = false
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "Binary Images:"):
+			thread = nil
+			inImages = true
+		case reAppleException.MatchString(line):
+			m := reAppleException.FindStringSubmatch(line)
+			r.ExceptionType = m[1]
+			if m[2] != "" {
+				r.Signal = m[2]
+			}
+		case reAppleCodes.MatchString(line):
+			m := reAppleCodes.FindStringSubmatch(line)
+			r.FaultAddress, r.HasFaultAddress = parseHex(m[1])
+		case reAppleTermSig.MatchString(line):
+			n, _ := strconv.Atoi(reAppleTermSig.FindStringSubmatch(line)[1])
+			r.SignalNumber = n
+		case reAppleCrashed.MatchString(line):
+			r.CrashedThread, _ = strconv.Atoi(reAppleCrashed.FindStringSubmatch(line)[1])
+		case reAppleThreadNm.MatchString(line):
+			m := reAppleThreadNm.FindStringSubmatch(line)
+			idx, _ := strconv.Atoi(m[1])
+			threadNames[idx] = m[2]
+		case reAppleThread.MatchString(line):
+			m := reAppleThread.FindStringSubmatch(line)
+			idx, _ := strconv.Atoi(m[1])
+			r.Threads = append(r.Threads, CrashThread{
+				Index:   idx,
+				Name:    strings.TrimPrefix(m[3], "Dispatch queue: "),
+				Crashed: m[2] != "",
+			})
+			if m[2] != "" {
+				r.CrashedThread = idx
+			}
+			thread = &r.Threads[len(r.Threads)-1]
+		case thread != nil && reAppleFrame.MatchString(line):
+			m := reAppleFrame.FindStringSubmatch(line)
+			idx, _ := strconv.Atoi(m[1])
+			addr, _ := parseHex(m[3])
+			frame := CrashFrame{
+				Index:   idx,
+				Module:  m[2],
+				Address: addr,
+			}
+			if sm := reAppleSymbol.FindStringSubmatch(m[4]); sm != nil {
+				off, _ := strconv.ParseUint(sm[2], 10, 64)
+				if base, ok := parseHex(sm[1]); ok {
+					frame.ModuleOffset = addr - base
+				} else {
+					frame.Symbol = sm[1]
+					frame.SymbolOffset = off
+				}
+				if sm[3] != "" {
+					frame.File = sm[3]
+					frame.Line, _ = strconv.Atoi(sm[4])
+				}
+			}
+			thread.Frames = append(thread.Frames, frame)
+		case strings.TrimSpace(line) == "":
+			thread = nil
+		}
+	}
+
+	for i := range r.Threads {
+		if name, ok := threadNames[r.Threads[i].Index]; ok && r.Threads[i].Name == "" {
+			r.Threads[i].Name = name
+		}
+		for j := range r.Threads[i].Frames {
+			frame := &r.Threads[i].Frames[j]
+			for _, img := range r.Images {
+				if img.Name == frame.Module || frame.Address >= img.Start && frame.Address <= img.End {
+					if frame.ModuleOffset == 0 && frame.Address >= img.Start {
+						frame.ModuleOffset = frame.Address - img.Start
+					}
+					frame.BuildID = img.BuildID
+					break
+				}
+			}
+		}
+	}
+}
+
+func parseAppleImage(line string) (BinaryImage, bool) {
+	m := reAppleImage.FindStringSubmatch(line)
+	if m == nil {
+		return BinaryImage{}, false
+	}
+
+	start, _ := parseHex(m[1])
+	end, _ := parseHex(m[2])
+	img := BinaryImage{Start: start, End: end}
+
+	rest := m[3]
+	if i := strings.Index(rest, "<"); i >= 0 {
+		if j := strings.Index(rest[i:], ">"); j >= 0 {
+			img.BuildID = strings.ToLower(strings.ReplaceAll(rest[i+1:i+j], "-", ""))
+			img.Path = strings.TrimSpace(rest[i+j+1:])
+			rest = rest[:i]
+		}
+	}
+
+	fields := strings.Fields(rest)
+	var nameParts []string
+	for _, f := range fields {
+		switch {
+		case strings.HasPrefix(f, "(") && strings.HasSuffix(f, ")"):
+			// version, e.g. (1.0) or (*)
+		case isAppleArch(f):
+			img.Arch = f
+		default:
+			nameParts = append(nameParts, f)
+		}
+	}
+	img.Name = strings.TrimPrefix(strings.Join(nameParts, " "), "+")
+	if img.Name == "" && img.Path != "" {
+		img.Name = img.Path[strings.LastIndex(img.Path, "/")+1:]
+	}
+
+	return img, true
+}
+
+func isAppleArch(s string) bool {
+	switch s {
+	case "arm64", "arm64e", "arm64_32", "armv7", "armv7s", "armv7k", "x86_64", "x86_64h", "i386":
+		return true
+	}
+	return false
+}
+
+func (r *CrashReport) parseAndroid(report string) {
+	var thread *CrashThread
+	seen := make(map[string]bool)
+
+	sc := bufio.NewScanner(strings.NewReader(report))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+
+		if m := reAndroidSignal.FindStringSubmatch(line); m != nil && r.Signal == "" {
+			r.SignalNumber, _ = strconv.Atoi(m[1])
+			r.Signal = m[2]
+			if m[3] != "" {
+				r.FaultAddress, r.HasFaultAddress = parseHex(m[3])
+			}
+			continue
+		}
+
+		if m := reAndroidThread.FindStringSubmatch(line); m != nil {
+			tid, _ := strconv.Atoi(m[1])
+			r.Threads = append(r.Threads, CrashThread{
+				Index:   tid,
+				Name:    m[2],
+				Crashed: len(r.Threads) == 0,
+			})
+			if len(r.Threads) == 1 {
+				r.CrashedThread = tid
+			}
+			thread = &r.Threads[len(r.Threads)-1]
+			continue
+		}
+
+		var buildID string
+		if strings.Contains(line, " pc ") {
+			for _, m := range reAndroidFrameInfo.FindAllStringSubmatch(line, -1) {
+				if m[1] != "" {
+					buildID = strings.ToLower(m[1])
+				}
+			}
+			line = reAndroidFrameInfo.ReplaceAllString(line, "")
+		}
+
+		if m := reAndroidFrame.FindStringSubmatch(line); m != nil {
+			if thread == nil {
+				r.Threads = append(r.Threads, CrashThread{Crashed: true})
+				r.CrashedThread = 0
+				thread = &r.Threads[len(r.Threads)-1]
+			}
+			idx, _ := strconv.Atoi(m[1])
+			off, _ := parseHex(m[2])
+			path := m[3]
+			frame := CrashFrame{
+				Index:        idx,
+				Module:       path[strings.LastIndex(path, "/")+1:],
+				ModuleOffset: off,
+				BuildID:      buildID,
+			}
+			if sym := m[4]; sym != "" {
+				if i := strings.LastIndex(sym, "+"); i > 0 {
+					frame.Symbol = sym[:i]
+					frame.SymbolOffset, _ = strconv.ParseUint(sym[i+1:], 10, 64)
+				} else {
+					frame.Symbol = sym
+				}
+			}
+			thread.Frames = append(thread.Frames, frame)
+
+			if !seen[path] {
+				seen[path] = true
+				r.Images = append(r.Images, BinaryImage{
+					Name:    frame.Module,
+					Path:    path,
+					BuildID: frame.BuildID,
+				})
+			}
+		}
+	}
+}
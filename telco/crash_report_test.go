@@ -0,0 +1,146 @@
+package telco
+
+import "testing"
+
+const androidTombstone = `*** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+Build fingerprint: 'google/sunfish/sunfish:11/RQ3A.211001.001/7641976:user/release-keys'
+Revision: 'MP1.0'
+ABI: 'arm64'
+Timestamp: 2024-03-01 10:00:00+0000
+pid: 12345, tid: 12360, name: RenderThread  >>> com.example.app <<<
+uid: 10245
+signal 11 (SIGSEGV), code 1 (SEGV_MAPERR), fault addr 0x0000000000000010
+Cause: null pointer dereference
+    x0  0000000000000000  x1  0000007fd1b2c3a0  x2  0000000000000010  x3  0000000000000000
+
+backtrace:
+      #00 pc 000000000004e2a8  /data/app/~~abc==/com.example.app-xyz==/lib/arm64/libnative.so (Renderer::draw(Frame const&)+168) (BuildId: 5D2B1B1A7C9D0E1F)
+      #01 pc 0000000000001234  /data/local/tmp/target (BuildId: abcdef12)
+      #02 pc 00000000000b1f30  /data/app/~~abc==/com.example.app-xyz==/base.apk (offset 0x3000) (Java_com_example_Native_draw+24)
+      #03 pc 00000000000d3e5c  /apex/com.android.runtime/lib64/bionic/libc.so (__pthread_start(void*)+64) (BuildId: 0123456789abcdef)
+      #04 pc 0000000000051c88  /apex/com.android.runtime/lib64/bionic/libc.so (__start_thread+64) (BuildId: 0123456789abcdef)
+`
+
+func TestParseCrashReportAndroid(t *testing.T) {
+	r := ParseCrashReport(androidTombstone)
+
+	if r.Signal != "SIGSEGV" || r.SignalNumber != 11 {
+		t.Errorf("signal = %s (%d), want SIGSEGV (11)", r.Signal, r.SignalNumber)
+	}
+	if !r.HasFaultAddress || r.FaultAddress != 0x10 {
+		t.Errorf("fault address = %#x (%v), want 0x10", r.FaultAddress, r.HasFaultAddress)
+	}
+	if r.CrashedThread != 12360 {
+		t.Errorf("crashed thread = %d, want 12360", r.CrashedThread)
+	}
+
+	crashed := r.Crashed()
+	if crashed == nil || crashed.Name != "RenderThread" || len(crashed.Frames) != 5 {
+		t.Fatalf("crashed thread = %+v", crashed)
+	}
+
+	want := []CrashFrame{
+		{Index: 0, Module: "libnative.so", ModuleOffset: 0x4e2a8, Symbol: "Renderer::draw(Frame const&)", SymbolOffset: 168, BuildID: "5d2b1b1a7c9d0e1f"},
+		{Index: 1, Module: "target", ModuleOffset: 0x1234, BuildID: "abcdef12"},
+		{Index: 2, Module: "base.apk", ModuleOffset: 0xb1f30, Symbol: "Java_com_example_Native_draw", SymbolOffset: 24},
+		{Index: 3, Module: "libc.so", ModuleOffset: 0xd3e5c, Symbol: "__pthread_start(void*)", SymbolOffset: 64, BuildID: "0123456789abcdef"},
+		{Index: 4, Module: "libc.so", ModuleOffset: 0x51c88, Symbol: "__start_thread", SymbolOffset: 64, BuildID: "0123456789abcdef"},
+	}
+	for i, frame := range crashed.Frames {
+		if frame != want[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, frame, want[i])
+		}
+	}
+
+	if len(r.Images) != 4 {
+		t.Fatalf("images = %+v", r.Images)
+	}
+	if img := r.Images[1]; img.Path != "/data/local/tmp/target" || img.BuildID != "abcdef12" {
+		t.Errorf("image 1 = %+v", img)
+	}
+}
+
+const appleCrash = `Incident Identifier: 6D4E5C3B-2A19-4F08-B7E6-D5C4B3A29180
+Hardware Model:      iPhone14,2
+Process:             Example [4242]
+Path:                /private/var/containers/Bundle/Application/0A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9/Example.app/Example
+Identifier:          com.example.app
+Version:             1.0 (1)
+Code Type:           ARM-64 (Native)
+OS Version:          iPhone OS 16.5 (20F66)
+
+Exception Type:  EXC_BAD_ACCESS (SIGSEGV)
+Exception Subtype: KERN_INVALID_ADDRESS at 0x0000000000000018
+Exception Codes: 0x0000000000000001, 0x0000000000000018
+Termination Reason: SIGNAL 11 Segmentation fault: 11
+Terminating Process: exc handler [4242]
+
+Triggered by Thread:  1
+
+Thread 0 name:   Dispatch queue: com.apple.main-thread
+Thread 0:
+0   libsystem_kernel.dylib        	0x00000001d4e2a8b4 mach_msg2_trap + 8
+1   CoreFoundation                	0x00000001c2b1e4d0 __CFRunLoopServiceMachPort + 160
+
+Thread 1 name:   worker
+Thread 1 Crashed:
+0   Example                       	0x0000000104a2b1c4 Renderer::draw() + 52 (Renderer.cpp:120)
+1   Example                       	0x0000000104a2a010 0x104a28000 + 8208
+2   libsystem_pthread.dylib       	0x00000001ee7f56cc _pthread_start + 148
+
+Binary Images:
+       0x104a28000 -        0x104a2ffff Example arm64  <2c5f4d3a8f7e3b1a9c0d1e2f3a4b5c6d> /private/var/containers/Bundle/Application/0A1B2C3D-4E5F-6071-8293-A4B5C6D7E8F9/Example.app/Example
+       0x1c2b00000 -        0x1c2efffff CoreFoundation arm64e  <0a1b2c3d4e5f60718293a4b5c6d7e8f9> /System/Library/Frameworks/CoreFoundation.framework/CoreFoundation
+       0x1d4e28000 -        0x1d4e5ffff libsystem_kernel.dylib arm64e  <1a2b3c4d5e6f70819203a4b5c6d7e8f9> /usr/lib/system/libsystem_kernel.dylib
+       0x1ee7f0000 -        0x1ee7fbfff libsystem_pthread.dylib arm64e  <2a3b4c5d6e7f80910213a4b5c6d7e8f9> /usr/lib/system/libsystem_pthread.dylib
+`
+
+func TestParseCrashReportApple(t *testing.T) {
+	r := ParseCrashReport(appleCrash)
+
+	if r.ExceptionType != "EXC_BAD_ACCESS" || r.Signal != "SIGSEGV" || r.SignalNumber != 11 {
+		t.Errorf("exception = %s %s (%d)", r.ExceptionType, r.Signal, r.SignalNumber)
+	}
+	if !r.HasFaultAddress || r.FaultAddress != 0x18 {
+		t.Errorf("fault address = %#x (%v), want 0x18", r.FaultAddress, r.HasFaultAddress)
+	}
+	if len(r.Threads) != 2 || r.Threads[0].Name != "Dispatch queue: com.apple.main-thread" || r.Threads[1].Name != "worker" {
+		t.Fatalf("threads = %+v", r.Threads)
+	}
+
+	crashed := r.Crashed()
+	if crashed == nil || crashed.Index != 1 || len(crashed.Frames) != 3 {
+		t.Fatalf("crashed thread = %+v", crashed)
+	}
+
+	exampleID := "2c5f4d3a8f7e3b1a9c0d1e2f3a4b5c6d"
+	want := []CrashFrame{
+		{Index: 0, Module: "Example", Address: 0x104a2b1c4, ModuleOffset: 0x31c4, Symbol: "Renderer::draw()", SymbolOffset: 52, BuildID: exampleID, File: "Renderer.cpp", Line: 120},
+		{Index: 1, Module: "Example", Address: 0x104a2a010, ModuleOffset: 8208, BuildID: exampleID},
+		{Index: 2, Module: "libsystem_pthread.dylib", Address: 0x1ee7f56cc, ModuleOffset: 0x56cc, Symbol: "_pthread_start", SymbolOffset: 148, BuildID: "2a3b4c5d6e7f80910213a4b5c6d7e8f9"},
+	}
+	for i, frame := range crashed.Frames {
+		if frame != want[i] {
+			t.Errorf("frame %d = %+v, want %+v", i, frame, want[i])
+		}
+	}
+
+	if len(r.Images) != 4 {
+		t.Fatalf("images = %+v", r.Images)
+	}
+	img := r.Image(0x104a2b1c4)
+	if img == nil || img.Name != "Example" || img.Arch != "arm64" || img.BuildID != exampleID {
+		t.Errorf("image containing the crash = %+v", img)
+	}
+}
+
+func TestCrashReportSignature(t *testing.T) {
+	a := ParseCrashReport(appleCrash)
+	b := ParseCrashReport(appleCrash)
+	if a.Signature("Example") != b.Signature("Example") {
+		t.Error("signature differs for the same report")
+	}
+	if a.Signature("Example") == ParseCrashReport(androidTombstone).Signature("Example") {
+		t.Error("signature is the same for different reports")
+	}
+}
@@ -0,0 +1,385 @@
+package telco
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	crashIndexFile = "index.jsonl"
+	crashesDir     = "crashes"
+)
+
+// CrashRecord represents the crash persisted inside the CrashStore.
+type CrashRecord struct {
+	ID          string         `json:"id"`
+	Signature   string         `json:"signature"`
+	Source      string         `json:"source"`
+	DeviceID    string         `json:"deviceId,omitempty"`
+	PID         int            `json:"pid"`
+	ProcessName string         `json:"processName"`
+	Summary     string         `json:"summary"`
+	Report      string         `json:"report"`
+	Params      map[string]any `json:"params,omitempty"`
+	Parsed      *CrashReport   `json:"parsed,omitempty"`
+	Time        time.Time      `json:"time"`
+}
+
+// crashParams converts the parameters of the crash to the values accepted by
+// json.Marshal: maps with non-string keys get their keys formatted with fmt,
+// tuples become lists and non-finite floats are formatted as strings.
+func crashParams(params map[string]any) map[string]any {
+	if params == nil {
+		return nil
+	}
+	return jsonValue(params).(map[string]any)
+}
+
+func jsonValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = jsonValue(item)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case Tuple:
+		return jsonValue([]any(val))
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = jsonValue(item)
+		}
+		return out
+	case float64:
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return fmt.Sprint(val)
+		}
+	}
+	return v
+}
+
+// CrashIndexEntry represents the crash inside the index of the CrashStore.
+type CrashIndexEntry struct {
+	ID          string    `json:"id"`
+	Signature   string    `json:"signature"`
+	DeviceID    string    `json:"deviceId,omitempty"`
+	PID         int       `json:"pid"`
+	ProcessName string    `json:"processName"`
+	Signal      string    `json:"signal,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// CrashSignature represents all the crashes with the same signature.
+type CrashSignature struct {
+	Signature   string    `json:"signature"`
+	ProcessName string    `json:"processName"`
+	Signal      string    `json:"signal,omitempty"`
+	Count       int       `json:"count"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+	// FirstID is the ID of the first crash with the signature.
+	FirstID string `json:"firstId"`
+}
+
+// CrashQuery is used to select crashes from the CrashStore. Empty fields match everything.
+type CrashQuery struct {
+	Signature   string
+	DeviceID    string
+	ProcessName string
+	Signal      string
+	Since       time.Time
+	Until       time.Time
+	Limit       int
+}
+
+func (q *CrashQuery) match(e *CrashIndexEntry) bool {
+	switch {
+	case q.Signature != "" && q.Signature != e.Signature:
+		return false
+	case q.DeviceID != "" && q.DeviceID != e.DeviceID:
+		return false
+	case q.ProcessName != "" && q.ProcessName != e.ProcessName:
+		return false
+	case q.Signal != "" && q.Signal != e.Signal:
+		return false
+	case !q.Since.IsZero() && e.Time.Before(q.Since):
+		return false
+	case !q.Until.IsZero() && !e.Time.Before(q.Until):
+		return false
+	}
+	return true
+}
+
+// CrashStore persists crashes inside the directory.
+// Every crash is stored as crashes/ID.json while index.jsonl holds the index used
+// by Query and Signatures, one entry per line appended by Add. The index is
+// rebuilt from the crash files if it is missing or damaged.
+type CrashStore struct {
+	dir     string
+	mu      sync.Mutex
+	entries []CrashIndexEntry
+}
+
+// OpenCrashStore opens the crash store inside dir, creating it if needed.
+func OpenCrashStore(dir string) (*CrashStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, crashesDir), 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &CrashStore{dir: dir}
+
+	bts, err := os.ReadFile(filepath.Join(dir, crashIndexFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := s.rebuildIndex(); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	default:
+		// the last line is torn if the process died while appending it
+		if s.entries, err = parseIndex(bts); err != nil {
+			if err := s.rebuildIndex(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return s, nil
+}
+
+func parseIndex(bts []byte) ([]CrashIndexEntry, error) {
+	var entries []CrashIndexEntry
+	for len(bts) > 0 {
+		line := bts
+		if i := bytes.IndexByte(bts, '\n'); i >= 0 {
+			line, bts = bts[:i], bts[i+1:]
+		} else {
+			bts = nil
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e CrashIndexEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, fmt.Errorf("invalid crash index: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Dir returns the directory of the store.
+func (s *CrashStore) Dir() string {
+	return s.dir
+}
+
+// rebuildIndex recreates the index from the crash files.
+func (s *CrashStore) rebuildIndex() error {
+	files, err := filepath.Glob(filepath.Join(s.dir, crashesDir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	s.entries = nil
+	for _, file := range files {
+		rec, err := readCrashRecord(file)
+		if err != nil {
+			return err
+		}
+		s.entries = append(s.entries, indexEntry(rec))
+	}
+
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].Time.Before(s.entries[j].Time)
+	})
+
+	return s.writeIndex()
+}
+
+func indexEntry(rec *CrashRecord) CrashIndexEntry {
+	entry := CrashIndexEntry{
+		ID:          rec.ID,
+		Signature:   rec.Signature,
+		DeviceID:    rec.DeviceID,
+		PID:         rec.PID,
+		ProcessName: rec.ProcessName,
+		Time:        rec.Time,
+	}
+	if rec.Parsed != nil {
+		entry.Signal = rec.Parsed.Signal
+	}
+	return entry
+}
+
+func readCrashRecord(path string) (*CrashRecord, error) {
+	bts, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec CrashRecord
+	if err := json.Unmarshal(bts, &rec); err != nil {
+		return nil, fmt.Errorf("invalid crash record %s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *CrashStore) writeIndex() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range s.entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(filepath.Join(s.dir, crashIndexFile), buf.Bytes())
+}
+
+// appendIndex appends the entry to the index file.
+func (s *CrashStore) appendIndex(e CrashIndexEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, crashIndexFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Add persists the crash record. It returns true if this is the first crash
+// with the record signature inside the store. Params are converted to values
+// JSON can hold, e.g. maps with non-string keys get their keys formatted.
+func (s *CrashStore) Add(rec *CrashRecord) (bool, error) {
+	if rec.ID == "" || rec.Signature == "" {
+		return false, errors.New("crash record needs ID and signature")
+	}
+	rec.Params = crashParams(rec.Params)
+
+	bts, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return false, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	isNew := true
+	for _, e := range s.entries {
+		if e.Signature == rec.Signature {
+			isNew = false
+			break
+		}
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dir, crashesDir, rec.ID+".json"), bts); err != nil {
+		return false, err
+	}
+
+	entry := indexEntry(rec)
+	if err := s.appendIndex(entry); err != nil {
+		return false, err
+	}
+	s.entries = append(s.entries, entry)
+	return isNew, nil
+}
+
+// Get returns the crash record with the id provided.
+func (s *CrashStore) Get(id string) (*CrashRecord, error) {
+	if filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid crash id %q", id)
+	}
+	return readCrashRecord(filepath.Join(s.dir, crashesDir, id+".json"))
+}
+
+// Query returns the index entries matching the query, newest first.
+func (s *CrashStore) Query(q CrashQuery) []CrashIndexEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var ret []CrashIndexEntry
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if q.match(&s.entries[i]) {
+			ret = append(ret, s.entries[i])
+			if q.Limit > 0 && len(ret) == q.Limit {
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Signatures returns the summary of every signature inside the store, most
+// recently seen first.
+func (s *CrashStore) Signatures() []CrashSignature {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bySig := make(map[string]*CrashSignature)
+	var sigs []*CrashSignature
+	for _, e := range s.entries {
+		sig, ok := bySig[e.Signature]
+		if !ok {
+			sig = &CrashSignature{
+				Signature:   e.Signature,
+				ProcessName: e.ProcessName,
+				Signal:      e.Signal,
+				FirstSeen:   e.Time,
+				FirstID:     e.ID,
+			}
+			bySig[e.Signature] = sig
+			sigs = append(sigs, sig)
+		}
+		sig.Count++
+		if e.Time.Before(sig.FirstSeen) {
+			sig.FirstSeen = e.Time
+			sig.FirstID = e.ID
+		}
+		if e.Time.After(sig.LastSeen) {
+			sig.LastSeen = e.Time
+		}
+	}
+
+	ret := make([]CrashSignature, len(sigs))
+	for i, sig := range sigs {
+		ret[i] = *sig
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].LastSeen.After(ret[j].LastSeen)
+	})
+	return ret
+}
@@ -0,0 +1,136 @@
+package telco
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCrashParams(t *testing.T) {
+	params := map[string]any{
+		"exit-status": int32(11),
+		"registers":   map[any]any{uint32(0): "x0", uint32(1): map[any]any{true: Tuple{"a", 1.5}}},
+		"frames":      []map[string]any{{"pc": uint64(4096)}},
+		"tuple":       Tuple{int64(1), math.NaN()},
+		"list":        []any{math.Inf(1), "b"},
+	}
+	if _, err := json.Marshal(params); err == nil {
+		t.Fatal("raw params marshalled")
+	}
+
+	got := crashParams(params)
+	want := map[string]any{
+		"exit-status": int32(11),
+		"registers":   map[string]any{"0": "x0", "1": map[string]any{"true": []any{"a", 1.5}}},
+		"frames":      []any{map[string]any{"pc": uint64(4096)}},
+		"tuple":       []any{int64(1), "NaN"},
+		"list":        []any{"+Inf", "b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("crashParams() = %#v, want %#v", got, want)
+	}
+	if crashParams(nil) != nil {
+		t.Error("crashParams(nil) != nil")
+	}
+}
+
+func TestCrashStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenCrashStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	recs := []*CrashRecord{
+		{ID: "1", Signature: "a", ProcessName: "app", Time: start, Params: map[string]any{"regs": map[any]any{uint32(1): "x1"}}},
+		{ID: "2", Signature: "b", ProcessName: "app", Time: start.Add(time.Second)},
+		{ID: "3", Signature: "a", ProcessName: "app", Time: start.Add(2 * time.Second)},
+	}
+	for i, rec := range recs {
+		isNew, err := store.Add(rec)
+		if err != nil {
+			t.Fatalf("Add(%s) error %v", rec.ID, err)
+		}
+		if want := i < 2; isNew != want {
+			t.Errorf("Add(%s) isNew = %t, want %t", rec.ID, isNew, want)
+		}
+	}
+
+	rec, err := store.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"regs": map[string]any{"1": "x1"}}; !reflect.DeepEqual(rec.Params, want) {
+		t.Errorf("Get(1).Params = %v, want %v", rec.Params, want)
+	}
+
+	ids := func(s *CrashStore) []string {
+		var ids []string
+		for _, e := range s.Query(CrashQuery{Signature: "a"}) {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	reopened, err := OpenCrashStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(reopened), []string{"3", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reopened Query(a) = %v, want %v", got, want)
+	}
+
+	// the line torn by the process dying while appending it is rebuilt
+	f, err := os.OpenFile(filepath.Join(dir, crashIndexFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"4","sig`)
+	f.Close()
+
+	rebuilt, err := OpenCrashStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(rebuilt), []string{"3", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rebuilt Query(a) = %v, want %v", got, want)
+	}
+	if sigs := rebuilt.Signatures(); len(sigs) != 2 || sigs[0].Signature != "a" || sigs[0].Count != 2 {
+		t.Errorf("Signatures() = %+v", sigs)
+	}
+}
+
+func TestCrashCollectorQueue(t *testing.T) {
+	store, err := OpenCrashStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCrashCollector(store)
+
+	var collected []string
+	c.OnCrash(func(rec *CrashRecord, isNew bool) {
+		collected = append(collected, rec.ID)
+	})
+	c.started = true
+	go c.deliver()
+
+	now := time.Now()
+	c.push(&CrashRecord{ID: "1", Signature: "a", DeviceID: "usb", Report: "first", Time: now})
+	c.push(&CrashRecord{ID: "2", Signature: "a", DeviceID: "usb", Report: "first", Time: now})
+	c.push(&CrashRecord{ID: "3", Signature: "b", DeviceID: "usb", Report: "second", Time: now})
+	c.Close()
+
+	// the duplicate reported by the session is dropped
+	if want := []string{"1", "3"}; !reflect.DeepEqual(collected, want) {
+		t.Errorf("collected %v, want %v", collected, want)
+	}
+	if entries := store.Query(CrashQuery{}); len(entries) != 2 {
+		t.Errorf("stored %d crashes, want 2", len(entries))
+	}
+	c.Close()
+}
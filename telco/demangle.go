@@ -0,0 +1,45 @@
+package telco
+
+import (
+	"github.com/telco/telco-go/demangle"
+)
+
+// Demangle replaces the mangled C++, Rust and Swift symbols of all the frames with
+// their demangled names. It returns the number of frames demangled.
+func (r *CrashReport) Demangle() int {
+	demangled := 0
+	for t := range r.Threads {
+		for f := range r.Threads[t].Frames {
+			frame := &r.Threads[t].Frames[f]
+			if name, err := demangle.Demangle(frame.Symbol); err == nil {
+				frame.Symbol = name
+				demangled++
+			}
+		}
+	}
+	return demangled
+}
+
+// Demangle replaces the mangled symbols found inside the description, the stack
+// and the string values of the payload with their demangled names.
+func (m *Message) Demangle() {
+	m.Description = demangle.Text(m.Description)
+	m.Stack = demangle.Text(m.Stack)
+	m.Payload = demanglePayload(m.Payload)
+}
+
+func demanglePayload(v any) any {
+	switch val := v.(type) {
+	case string:
+		return demangle.Text(val)
+	case map[string]any:
+		for k, elem := range val {
+			val[k] = demanglePayload(elem)
+		}
+	case []any:
+		for i, elem := range val {
+			val[i] = demanglePayload(elem)
+		}
+	}
+	return v
+}
@@ -3,8 +3,8 @@ package telco
 //#include <telco-core.h>
 import "C"
 import (
+	"context"
 	"errors"
-	"reflect"
 	"runtime"
 	"sort"
 	"unsafe"
@@ -161,15 +161,16 @@ func (d *Device) EnumerateApplications(identifier string, scope Scope) ([]*Appli
 }
 
 // ProcessByPID returns the process by passed pid.
-func (d *Device) ProcessByPID(pid int, scope Scope) (*Process, error) {
+// The timeout of the lookup can be set with WithTimeout.
+func (d *Device) ProcessByPID(pid int, scope Scope, opts ...LookupOption) (*Process, error) {
 	if d.device != nil {
-		opts := C.telco_process_match_options_new()
-		C.telco_process_match_options_set_timeout(opts, C.gint(defaultProcessTimeout))
-		C.telco_process_match_options_set_scope(opts, C.TelcoScope(scope))
-		defer clean(unsafe.Pointer(opts), unrefTelco)
+		matchOpts := C.telco_process_match_options_new()
+		C.telco_process_match_options_set_timeout(matchOpts, C.gint(newLookupOptions(defaultProcessTimeout, opts).timeout))
+		C.telco_process_match_options_set_scope(matchOpts, C.TelcoScope(scope))
+		defer clean(unsafe.Pointer(matchOpts), unrefTelco)
 
 		var err *C.GError
-		proc := C.telco_device_get_process_by_pid_sync(d.device, C.guint(pid), opts, nil, &err)
+		proc := C.telco_device_get_process_by_pid_sync(d.device, C.guint(pid), matchOpts, nil, &err)
 		if err != nil {
 			return nil, &FError{err}
 		}
@@ -179,18 +180,19 @@ func (d *Device) ProcessByPID(pid int, scope Scope) (*Process, error) {
 }
 
 // ProcessByName returns the process by passed name.
-func (d *Device) ProcessByName(name string, scope Scope) (*Process, error) {
+// The timeout of the lookup can be set with WithTimeout.
+func (d *Device) ProcessByName(name string, scope Scope, opts ...LookupOption) (*Process, error) {
 	if d.device != nil {
 		nameC := C.CString(name)
 		defer C.free(unsafe.Pointer(nameC))
 
-		opts := C.telco_process_match_options_new()
-		C.telco_process_match_options_set_timeout(opts, C.gint(defaultProcessTimeout))
-		C.telco_process_match_options_set_scope(opts, C.TelcoScope(scope))
-		defer clean(unsafe.Pointer(opts), unrefTelco)
+		matchOpts := C.telco_process_match_options_new()
+		C.telco_process_match_options_set_timeout(matchOpts, C.gint(newLookupOptions(defaultProcessTimeout, opts).timeout))
+		C.telco_process_match_options_set_scope(matchOpts, C.TelcoScope(scope))
+		defer clean(unsafe.Pointer(matchOpts), unrefTelco)
 
 		var err *C.GError
-		proc := C.telco_device_get_process_by_name_sync(d.device, nameC, opts, nil, &err)
+		proc := C.telco_device_get_process_by_name_sync(d.device, nameC, matchOpts, nil, &err)
 		if err != nil {
 			return nil, &FError{err}
 		}
@@ -200,15 +202,16 @@ func (d *Device) ProcessByName(name string, scope Scope) (*Process, error) {
 }
 
 // FindProcessByPID will try to find the process with given pid.
-func (d *Device) FindProcessByPID(pid int, scope Scope) (*Process, error) {
+// The timeout of the lookup can be set with WithTimeout.
+func (d *Device) FindProcessByPID(pid int, scope Scope, opts ...LookupOption) (*Process, error) {
 	if d.device != nil {
-		opts := C.telco_process_match_options_new()
-		C.telco_process_match_options_set_timeout(opts, C.gint(defaultProcessTimeout))
-		C.telco_process_match_options_set_scope(opts, C.TelcoScope(scope))
-		defer clean(unsafe.Pointer(opts), unrefTelco)
+		matchOpts := C.telco_process_match_options_new()
+		C.telco_process_match_options_set_timeout(matchOpts, C.gint(newLookupOptions(defaultProcessTimeout, opts).timeout))
+		C.telco_process_match_options_set_scope(matchOpts, C.TelcoScope(scope))
+		defer clean(unsafe.Pointer(matchOpts), unrefTelco)
 
 		var err *C.GError
-		proc := C.telco_device_find_process_by_pid_sync(d.device, C.guint(pid), opts, nil, &err)
+		proc := C.telco_device_find_process_by_pid_sync(d.device, C.guint(pid), matchOpts, nil, &err)
 		if err != nil {
 			return nil, &FError{err}
 		}
@@ -218,18 +221,19 @@ func (d *Device) FindProcessByPID(pid int, scope Scope) (*Process, error) {
 }
 
 // FindProcessByName will try to find the process with name specified.
-func (d *Device) FindProcessByName(name string, scope Scope) (*Process, error) {
+// The timeout of the lookup can be set with WithTimeout.
+func (d *Device) FindProcessByName(name string, scope Scope, opts ...LookupOption) (*Process, error) {
 	if d.device != nil {
 		nameC := C.CString(name)
 		defer C.free(unsafe.Pointer(nameC))
 
-		opts := C.telco_process_match_options_new()
-		C.telco_process_match_options_set_timeout(opts, C.gint(defaultProcessTimeout))
-		C.telco_process_match_options_set_scope(opts, C.TelcoScope(scope))
-		defer clean(unsafe.Pointer(opts), unrefTelco)
+		matchOpts := C.telco_process_match_options_new()
+		C.telco_process_match_options_set_timeout(matchOpts, C.gint(newLookupOptions(defaultProcessTimeout, opts).timeout))
+		C.telco_process_match_options_set_scope(matchOpts, C.TelcoScope(scope))
+		defer clean(unsafe.Pointer(matchOpts), unrefTelco)
 
 		var err *C.GError
-		proc := C.telco_device_find_process_by_name_sync(d.device, nameC, opts, nil, &err)
+		proc := C.telco_device_find_process_by_name_sync(d.device, nameC, matchOpts, nil, &err)
 		if err != nil {
 			return nil, &FError{err}
 		}
@@ -241,28 +245,69 @@ func (d *Device) FindProcessByName(name string, scope Scope) (*Process, error) {
 // EnumerateProcesses will slice of processes running with scope provided
 func (d *Device) EnumerateProcesses(scope Scope) ([]*Process, error) {
 	if d.device != nil {
-		opts := C.telco_process_query_options_new()
-		C.telco_process_query_options_set_scope(opts, C.TelcoScope(scope))
-		defer clean(unsafe.Pointer(opts), unrefTelco)
+		return d.enumerateProcesses(scope, nil)
+	}
+	return nil, errors.New("could not enumerate processes for nil device")
+}
 
-		var err *C.GError
-		procList := C.telco_device_enumerate_processes_sync(d.device, opts, nil, &err)
-		if err != nil {
-			return nil, &FError{err}
-		}
+func (d *Device) enumerateProcesses(scope Scope, cancellable *C.GCancellable) ([]*Process, error) {
+	opts := C.telco_process_query_options_new()
+	C.telco_process_query_options_set_scope(opts, C.TelcoScope(scope))
+	defer clean(unsafe.Pointer(opts), unrefTelco)
 
-		procListSize := int(C.telco_process_list_size(procList))
-		procs := make([]*Process, procListSize)
+	var err *C.GError
+	procList := C.telco_device_enumerate_processes_sync(d.device, opts, cancellable, &err)
+	if err != nil {
+		return nil, &FError{err}
+	}
 
-		for i := 0; i < procListSize; i++ {
-			proc := C.telco_process_list_get(procList, C.gint(i))
-			procs[i] = &Process{proc}
-		}
+	procListSize := int(C.telco_process_list_size(procList))
+	procs := make([]*Process, procListSize)
 
-		clean(unsafe.Pointer(procList), unrefTelco)
-		return procs, nil
+	for i := 0; i < procListSize; i++ {
+		proc := C.telco_process_list_get(procList, C.gint(i))
+		procs[i] = &Process{proc}
 	}
-	return nil, errors.New("could not enumerate processes for nil device")
+
+	clean(unsafe.Pointer(procList), unrefTelco)
+	return procs, nil
+}
+
+// enumerateProcessesContext is EnumerateProcesses cancelling the enumeration
+// once ctx is done.
+func (d *Device) enumerateProcessesContext(ctx context.Context, scope Scope) ([]*Process, error) {
+	if d.device == nil {
+		return nil, errors.New("could not enumerate processes for nil device")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ErrContextCancelled
+	}
+
+	cancellable := C.g_cancellable_new()
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			C.g_cancellable_cancel(cancellable)
+		case <-finished:
+		}
+	}()
+	defer func() {
+		close(finished)
+		<-stopped
+		C.g_object_unref(C.gpointer(cancellable))
+	}()
+
+	procs, err := d.enumerateProcesses(scope, cancellable)
+	if err != nil && ctx.Err() != nil {
+		if ferr, ok := err.(*FError); ok {
+			C.g_error_free(ferr.error)
+		}
+		return nil, ErrContextCancelled
+	}
+	return procs, err
 }
 
 // EnableSpawnGating will enable spawn gating on the device.
@@ -405,23 +450,15 @@ func (d *Device) Kill(pid int) error {
 	return errors.New("could not kill for nil device")
 }
 
-// Attach will attach on specified process name or PID.
+// Attach will attach on the process specified by val.
+// val can be the process name, PID, *Process, *Application or Target; see NewTarget.
 // You can pass the nil as SessionOptions or you can create it if you want
 // the session to persist for specific timeout.
 func (d *Device) Attach(val any, opts *SessionOptions) (*Session, error) {
 	if d.device != nil {
-		var pid int
-		switch v := reflect.ValueOf(val); v.Kind() {
-		case reflect.String:
-			proc, err := d.ProcessByName(val.(string), ScopeMinimal)
-			if err != nil {
-				return nil, err
-			}
-			pid = proc.PID()
-		case reflect.Int:
-			pid = val.(int)
-		default:
-			return nil, errors.New("expected name of app/process or PID")
+		pid, err := d.ResolveTarget(val)
+		if err != nil {
+			return nil, err
 		}
 
 		var opt *C.TelcoSessionOptions = nil
@@ -430,10 +467,10 @@ func (d *Device) Attach(val any, opts *SessionOptions) (*Session, error) {
 			defer clean(unsafe.Pointer(opt), unrefTelco)
 		}
 
-		var err *C.GError
-		s := C.telco_device_attach_sync(d.device, C.guint(pid), opt, nil, &err)
-		if err != nil {
-			return nil, &FError{err}
+		var gerr *C.GError
+		s := C.telco_device_attach_sync(d.device, C.guint(pid), opt, nil, &gerr)
+		if gerr != nil {
+			return nil, &FError{gerr}
 		}
 		return &Session{s}, nil
 	}
@@ -441,26 +478,18 @@ func (d *Device) Attach(val any, opts *SessionOptions) (*Session, error) {
 }
 
 // InjectLibraryFile will inject the library in the target with path to library specified.
+// Target can be anything accepted by NewTarget.
 // Entrypoint is the entrypoint to the library and the data is any data you need to pass
-// to the library.
-func (d *Device) InjectLibraryFile(target any, path, entrypoint, data string) (uint, error) {
+// to the library. The returned InjectedLibrary is done once the library is uninjected.
+func (d *Device) InjectLibraryFile(target any, path, entrypoint, data string) (*InjectedLibrary, error) {
 	if d.device != nil {
-		var pid int
-		switch v := reflect.ValueOf(target); v.Kind() {
-		case reflect.String:
-			proc, err := d.ProcessByName(target.(string), ScopeMinimal)
-			if err != nil {
-				return 0, err
-			}
-			pid = proc.PID()
-		case reflect.Int:
-			pid = target.(int)
-		default:
-			return 0, errors.New("expected name of app/process or PID")
+		pid, err := d.ResolveTarget(target)
+		if err != nil {
+			return nil, err
 		}
 
 		if path == "" {
-			return 0, errors.New("you need to provide path to library")
+			return nil, errors.New("you need to provide path to library")
 		}
 
 		var pathC *C.char
@@ -480,44 +509,42 @@ func (d *Device) InjectLibraryFile(target any, path, entrypoint, data string) (u
 			defer C.free(unsafe.Pointer(dataC))
 		}
 
-		var err *C.GError
+		reg := d.injectionRegistry()
+		reg.begin()
+
+		var gerr *C.GError
 		id := C.telco_device_inject_library_file_sync(d.device,
 			C.guint(pid),
 			pathC,
 			entrypointC,
 			dataC,
 			nil,
-			&err)
-		if err != nil {
-			return 0, &FError{err}
+			&gerr)
+		if gerr != nil {
+			reg.end(nil)
+			return nil, &FError{gerr}
 		}
 
-		return uint(id), nil
+		lib := newInjectedLibrary(uint(id), pid, path, entrypoint, data)
+		reg.end(lib)
+		return lib, nil
 	}
-	return 0, errors.New("could not inject library for nil device")
+	return nil, errors.New("could not inject library for nil device")
 }
 
 // InjectLibraryBlob will inject the library in the target with byteData path.
+// Target can be anything accepted by NewTarget.
 // Entrypoint is the entrypoint to the library and the data is any data you need to pass
-// to the library.
-func (d *Device) InjectLibraryBlob(target any, byteData []byte, entrypoint, data string) (uint, error) {
+// to the library. The returned InjectedLibrary is done once the library is uninjected.
+func (d *Device) InjectLibraryBlob(target any, byteData []byte, entrypoint, data string) (*InjectedLibrary, error) {
 	if d.device != nil {
-		var pid int
-		switch v := reflect.ValueOf(target); v.Kind() {
-		case reflect.String:
-			proc, err := d.ProcessByName(target.(string), ScopeMinimal)
-			if err != nil {
-				return 0, err
-			}
-			pid = proc.PID()
-		case reflect.Int:
-			pid = target.(int)
-		default:
-			return 0, errors.New("expected name of app/process or PID")
+		pid, err := d.ResolveTarget(target)
+		if err != nil {
+			return nil, err
 		}
 
 		if len(byteData) == 0 {
-			return 0, errors.New("you need to provide byteData")
+			return nil, errors.New("you need to provide byteData")
 		}
 
 		var entrypointC *C.char = nil
@@ -538,25 +565,32 @@ func (d *Device) InjectLibraryBlob(target any, byteData []byte, entrypoint, data
 			defer clean(unsafe.Pointer(g), unrefGObject)
 		})
 
-		var err *C.GError
+		reg := d.injectionRegistry()
+		reg.begin()
+
+		var gerr *C.GError
 		id := C.telco_device_inject_library_blob_sync(d.device,
 			C.guint(pid),
 			gBytesData,
 			entrypointC,
 			dataC,
 			nil,
-			&err)
+			&gerr)
 		runtime.KeepAlive(gBytesData)
-		if err != nil {
-			return 0, &FError{err}
+		if gerr != nil {
+			reg.end(nil)
+			return nil, &FError{gerr}
 		}
 
-		return uint(id), nil
+		lib := newInjectedLibrary(uint(id), pid, "", entrypoint, data)
+		reg.end(lib)
+		return lib, nil
 	}
-	return 0, errors.New("could not inject library blob for nil device")
+	return nil, errors.New("could not inject library blob for nil device")
 }
 
-// OpenChannel open channel with the address and returns the IOStream
+// OpenChannel open channel with the address and returns the IOStream.
+// The IOStream implements net.Conn.
 func (d *Device) OpenChannel(address string) (*IOStream, error) {
 	if d.device != nil {
 		addressC := C.CString(address)
@@ -567,7 +601,9 @@ func (d *Device) OpenChannel(address string) (*IOStream, error) {
 		if err != nil {
 			return nil, &FError{err}
 		}
-		return NewIOStream(stream), nil
+		ios := NewIOStream(stream)
+		ios.address = address
+		return ios, nil
 	}
 	return nil, errors.New("could not open channel for nil device")
 }
@@ -589,7 +625,7 @@ func (d *Device) Clean() {
 //   - "child_removed" with callback as func(child *telco.Child) {}
 //   - "process_crashed" with callback as func(crash *telco.Crash) {}
 //   - "output" with callback as func(pid, fd int, data []byte) {}
-//   - "uninjected" with callback as func(id int) {}
+//   - "uninjected" with callback as func(id uint) {}
 //   - "lost" with callback as func() {}
 func (d *Device) On(sigName string, fn any) {
 	if d.device != nil {
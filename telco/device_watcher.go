@@ -0,0 +1,284 @@
+package telco
+
+//#include <telco-core.h>
+import "C"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// DeviceEventType represents the kind of change reported by WatchDevices.
+type DeviceEventType int
+
+const (
+	DeviceAdded DeviceEventType = iota
+	DeviceRemoved
+	DeviceChanged
+)
+
+func (t DeviceEventType) String() string {
+	return [...]string{"added",
+		"removed",
+		"changed"}[t]
+}
+
+// DeviceEvent is sent by WatchDevices for every device added or removed.
+// DeviceChanged follows every batch of additions and removals and carries no device.
+// The receiver owns the reference to the Device and releases it with Clean.
+type DeviceEvent struct {
+	Type   DeviceEventType
+	Device *Device
+}
+
+// WatchDevices reports the devices added to and removed from the manager on the
+// returned channel. Events are queued, so slow receivers never block the
+// manager. The channel is closed once ctx is done. Every Device received holds
+// its own reference and needs to be released with Clean.
+func (d *DeviceManager) WatchDevices(ctx context.Context) (<-chan DeviceEvent, error) {
+	if d.manager == nil {
+		return nil, errors.New("could not watch devices for nil manager")
+	}
+
+	var mu sync.Mutex
+	var queue []DeviceEvent
+	notify := make(chan struct{}, 1)
+	push := func(ev DeviceEvent) {
+		mu.Lock()
+		queue = append(queue, ev)
+		mu.Unlock()
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+
+	obj := unsafe.Pointer(d.manager)
+	handlers := []signalHandler{
+		connectSignal(obj, "added", func(dev *Device) {
+			// the device outlives the signal emission inside the queue
+			C.g_object_ref(C.gpointer(dev.device))
+			push(DeviceEvent{Type: DeviceAdded, Device: dev})
+		}),
+		connectSignal(obj, "removed", func(dev *Device) {
+			C.g_object_ref(C.gpointer(dev.device))
+			push(DeviceEvent{Type: DeviceRemoved, Device: dev})
+		}),
+		connectSignal(obj, "changed", func() {
+			push(DeviceEvent{Type: DeviceChanged})
+		}),
+	}
+
+	events := make(chan DeviceEvent)
+	go func() {
+		defer close(events)
+		defer func() {
+			for _, h := range handlers {
+				h.disconnect()
+			}
+			// release the devices of the events never received
+			mu.Lock()
+			for _, ev := range queue {
+				if ev.Device != nil {
+					ev.Device.Clean()
+				}
+			}
+			queue = nil
+			mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			}
+
+			mu.Lock()
+			pending := queue
+			queue = nil
+			mu.Unlock()
+
+			for i, ev := range pending {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					mu.Lock()
+					queue = append(pending[i:], queue...)
+					mu.Unlock()
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// DeviceSelector matches devices by their properties. Empty fields match any
+// device; the device needs to match all the fields set.
+type DeviceSelector struct {
+	// ID is the exact ID of the device.
+	ID string `json:"id,omitempty"`
+	// Types are the accepted device types.
+	Types []DeviceTypeName `json:"types,omitempty"`
+	// Name is the glob pattern, as accepted by path.Match, matched against the name.
+	Name string `json:"name,omitempty"`
+	// Params are glob patterns matched against the system parameters of the device.
+	// Nested parameters are addressed with dots, e.g. "os.id": "android".
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// String returns the human readable description of the selector.
+func (s DeviceSelector) String() string {
+	var parts []string
+	if s.ID != "" {
+		parts = append(parts, fmt.Sprintf("id=%q", s.ID))
+	}
+	if len(s.Types) > 0 {
+		types := make([]string, len(s.Types))
+		for i, t := range s.Types {
+			types[i] = DeviceType(t).String()
+		}
+		parts = append(parts, "type="+strings.Join(types, "|"))
+	}
+	if s.Name != "" {
+		parts = append(parts, fmt.Sprintf("name=%q", s.Name))
+	}
+	keys := make([]string, 0, len(s.Params))
+	for k := range s.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%q", k, s.Params[k]))
+	}
+	if len(parts) == 0 {
+		return "any device"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Match returns whether the device matches the selector. System parameters are
+// queried only when the selector has Params.
+func (s DeviceSelector) Match(dev *Device) (bool, error) {
+	if dev == nil || dev.device == nil {
+		return false, nil
+	}
+	if s.ID != "" && dev.ID() != s.ID {
+		return false, nil
+	}
+	if len(s.Types) > 0 {
+		matched := false
+		for _, t := range s.Types {
+			if dev.DeviceType() == DeviceType(t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	if s.Name != "" {
+		ok, err := path.Match(s.Name, dev.Name())
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	if len(s.Params) == 0 {
+		return true, nil
+	}
+
+	params, err := dev.Params()
+	if err != nil {
+		return false, err
+	}
+	for key, pattern := range s.Params {
+		val, ok := lookupParam(params, key)
+		if !ok {
+			return false, nil
+		}
+		if ok, err := path.Match(pattern, fmt.Sprint(val)); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// lookupParam returns the value of the dotted key inside the nested params.
+func lookupParam(params map[string]any, key string) (any, bool) {
+	var cur any = params
+	for _, part := range strings.Split(key, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if cur, ok = m[part]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// WaitForDevice returns the first device matching the selector, waiting for it to
+// be added if none of the current devices matches. It returns ErrContextCancelled
+// once ctx is done. The device returned needs to be released with Clean.
+func (d *DeviceManager) WaitForDevice(ctx context.Context, sel DeviceSelector) (*Device, error) {
+	if _, err := path.Match(sel.Name, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern: %w", err)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// watch before enumerating so the device added in between is not missed
+	events, err := d.WatchDevices(watchCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	devices, err := d.EnumerateDevices()
+	if err != nil {
+		return nil, err
+	}
+	var found *Device
+	for _, dev := range devices {
+		if found == nil {
+			if ok, _ := sel.Match(dev); ok {
+				found = dev
+				continue
+			}
+		}
+		dev.Clean()
+	}
+	if found != nil {
+		return found, nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ErrContextCancelled
+		case ev, ok := <-events:
+			if !ok {
+				return nil, ErrContextCancelled
+			}
+			if ev.Type != DeviceAdded {
+				if ev.Device != nil {
+					ev.Device.Clean()
+				}
+				continue
+			}
+			if ok, _ := sel.Match(ev.Device); ok {
+				return ev.Device, nil
+			}
+			ev.Device.Clean()
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package telco
+
+import "fmt"
+
+// DiagnosticCategory is the severity of the compiler diagnostic.
+type DiagnosticCategory string
+
+const (
+	DiagnosticCategoryError      DiagnosticCategory = "error"
+	DiagnosticCategoryWarning    DiagnosticCategory = "warning"
+	DiagnosticCategorySuggestion DiagnosticCategory = "suggestion"
+	DiagnosticCategoryMessage    DiagnosticCategory = "message"
+)
+
+// Diagnostic is the error or warning reported by the compiler.
+type Diagnostic struct {
+	Category DiagnosticCategory `telco:"category" json:"category"`
+	// Code is the TypeScript diagnostic code, e.g. 2304 for TS2304.
+	Code int `telco:"code" json:"code"`
+	// File is the location of the diagnostic, nil when not tied to a file.
+	File *DiagnosticFile `telco:"file" json:"file,omitempty"`
+	Text string          `telco:"text" json:"text"`
+}
+
+// DiagnosticFile is the location of the diagnostic. Line and Character are
+// zero-based, as reported by the compiler.
+type DiagnosticFile struct {
+	Path      string `telco:"path" json:"path"`
+	Line      int    `telco:"line" json:"line"`
+	Character int    `telco:"character" json:"character"`
+}
+
+// String returns the diagnostic in the format used by tsc, with one-based
+// line and character: PATH(LINE,CHARACTER): CATEGORY TSCODE: TEXT.
+func (d Diagnostic) String() string {
+	msg := fmt.Sprintf("%s TS%d: %s", d.Category, d.Code, d.Text)
+	if d.File == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s(%d,%d): %s", d.File.Path, d.File.Line+1, d.File.Character+1, msg)
+}
+
+// IsError returns whether the diagnostic is an error.
+func (d Diagnostic) IsError() bool {
+	return d.Category == DiagnosticCategoryError
+}
+
+// diagnosticsFromValue decodes the value of the "diagnostics" signal.
+func diagnosticsFromValue(v any) []Diagnostic {
+	var items []map[string]any
+	switch val := v.(type) {
+	case []map[string]any:
+		items = val
+	case map[string]any:
+		items = []map[string]any{val}
+	case []any:
+		for _, item := range val {
+			if mp, ok := item.(map[string]any); ok {
+				items = append(items, mp)
+			}
+		}
+	}
+
+	diags := make([]Diagnostic, 0, len(items))
+	for _, item := range items {
+		var diag Diagnostic
+		if err := DecodeParams(item, &diag); err != nil {
+			diag = Diagnostic{
+				Category: DiagnosticCategoryError,
+				Text:     fmt.Sprint(item["text"]),
+			}
+		}
+		diags = append(diags, diag)
+	}
+	return diags
+}
@@ -0,0 +1,114 @@
+package telco
+
+//#include <telco-core.h>
+import "C"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unsafe"
+)
+
+// ChannelAddress returns the OpenChannel address for the network and address
+// used with net.Dial.
+//
+//   - "tcp", "tcp4" and "tcp6" addresses "host:port" are mapped to "tcp:port" when the
+//     host is empty or the loopback, otherwise to "tcp:host:port".
+//   - "unix" addresses are mapped to "localabstract:name" when they start with "@"
+//     (Linux abstract sockets), otherwise to "localfilesystem:path".
+//   - "telco" addresses are used as they are.
+func ChannelAddress(network, address string) (string, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return "", err
+		}
+		switch host {
+		case "", "localhost", "127.0.0.1", "::1":
+			return "tcp:" + port, nil
+		}
+		return "tcp:" + net.JoinHostPort(host, port), nil
+	case "unix":
+		if strings.HasPrefix(address, "@") {
+			return "localabstract:" + address[1:], nil
+		}
+		return "localfilesystem:" + address, nil
+	case "telco":
+		return address, nil
+	}
+	return "", fmt.Errorf("unsupported network %q", network)
+}
+
+// DialContext connects to the address on the device using OpenChannel.
+// See ChannelAddress for the supported networks and how the addresses are mapped.
+// Closing the returned connection releases the channel. It can be used as the DialContext of http.Transport or as the dialer of the
+// gRPC client:
+//
+//	client := &http.Client{
+//		Transport: &http.Transport{DialContext: dev.DialContext},
+//	}
+func (d *Device) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if d.device == nil {
+		return nil, errors.New("could not dial for nil device")
+	}
+
+	channelAddr, err := ChannelAddress(network, address)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: ChannelAddr(address), Err: err}
+	}
+
+	ios, err := d.openChannelContext(ctx, channelAddr)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Addr: ChannelAddr(channelAddr), Err: err}
+	}
+	return ios, nil
+}
+
+// Dial is DialContext using the background context.
+func (d *Device) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+// openChannelContext is OpenChannel cancelled once ctx is done.
+func (d *Device) openChannelContext(ctx context.Context, address string) (*IOStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ErrContextCancelled
+	}
+
+	addressC := C.CString(address)
+	defer C.free(unsafe.Pointer(addressC))
+
+	cancellable := C.g_cancellable_new()
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			C.g_cancellable_cancel(cancellable)
+		case <-finished:
+		}
+	}()
+	defer func() {
+		close(finished)
+		<-stopped
+		C.g_object_unref(C.gpointer(cancellable))
+	}()
+
+	var gerr *C.GError
+	stream := C.telco_device_open_channel_sync(d.device, addressC, cancellable, &gerr)
+	if gerr != nil {
+		if ctx.Err() != nil {
+			C.g_error_free(gerr)
+			return nil, ErrContextCancelled
+		}
+		return nil, &FError{gerr}
+	}
+
+	ios := NewIOStream(stream)
+	ios.address = address
+	return ios, nil
+}
@@ -4,6 +4,8 @@ package telco
 import "C"
 import (
 	"errors"
+	"fmt"
+	"os"
 	"unsafe"
 )
 
@@ -20,14 +22,33 @@ type AuthenticationFn func(string) string
 //   - authentication using callback (providing AuthenticationCallback)
 //
 // If the Token and AuthenticationCallback are passed, static authentication will be used (token based)
+//
+// EParams can be read from the config files, except for AuthenticationCallback;
+// Certificate is the PEM encoded certificate followed by its private key, or the
+// path to such a file.
 type EParams struct {
-	Address                string
-	Port                   uint16
-	Certificate            string
-	Origin                 string
-	Token                  string
-	AuthenticationCallback AuthenticationFn
-	AssetRoot              string
+	Address                string           `json:"address" yaml:"address"`
+	Port                   uint16           `json:"port,omitempty" yaml:"port,omitempty"`
+	Certificate            string           `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	Origin                 string           `json:"origin,omitempty" yaml:"origin,omitempty"`
+	Token                  string           `json:"token,omitempty" yaml:"token,omitempty"`
+	AuthenticationCallback AuthenticationFn `json:"-" yaml:"-"`
+	AssetRoot              string           `json:"asset_root,omitempty" yaml:"asset_root,omitempty"`
+}
+
+// Validate returns the error if the params are not valid.
+func (params *EParams) Validate() error {
+	if params.Address == "" {
+		return errors.New("you need to provide address")
+	}
+	if params.AssetRoot != "" {
+		if info, err := os.Stat(params.AssetRoot); err != nil {
+			return fmt.Errorf("asset root: %w", err)
+		} else if !info.IsDir() {
+			return fmt.Errorf("asset root %s is not a directory", params.AssetRoot)
+		}
+	}
+	return validateCertificate(params.Certificate)
 }
 
 // EndpointParameters represent internal TelcoEndpointParameters
@@ -48,8 +69,8 @@ func authenticate(cb unsafe.Pointer, token *C.char) *C.char {
 // NewEndpointParameters returns *EndpointParameters needed to setup Portal by using
 // provided EParams object.
 func NewEndpointParameters(params *EParams) (*EndpointParameters, error) {
-	if params.Address == "" {
-		return nil, errors.New("you need to provide address")
+	if err := params.Validate(); err != nil {
+		return nil, err
 	}
 
 	addrC := C.CString(params.Address)
@@ -76,7 +97,7 @@ func NewEndpointParameters(params *EParams) (*EndpointParameters, error) {
 
 	var cert *C.GTlsCertificate = nil
 	if params.Certificate != "" {
-		crt, err := gTLSCertificateFromFile(params.Certificate)
+		crt, err := certificateFromConfig(params.Certificate)
 		if err != nil {
 			return nil, err
 		}
@@ -133,6 +154,23 @@ func (e *EndpointParameters) SetAssetRoot(assetPath string) {
 	C.telco_endpoint_parameters_set_asset_root(e.params, assetRoot)
 }
 
+// Params returns the EParams describing the endpoint parameters, with the
+// certificate and its private key PEM encoded, so the result needs to be stored
+// as a secret. Token and AuthenticationCallback cannot be read back
+// and are left empty.
+func (e *EndpointParameters) Params() EParams {
+	params := EParams{
+		Address:     e.Address(),
+		Port:        e.Port(),
+		Certificate: e.Certificate().configPEM(),
+		Origin:      e.Origin(),
+	}
+	if C.telco_endpoint_parameters_get_asset_root(e.params) != nil {
+		params.AssetRoot = e.AssetRoot()
+	}
+	return params
+}
+
 // Clean will clean the resources held by the endpoint parameters.
 func (e *EndpointParameters) Clean() {
 	clean(unsafe.Pointer(e.params), unrefTelco)
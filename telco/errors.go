@@ -4,4 +4,9 @@ import "errors"
 
 var (
 	ErrContextCancelled = errors.New("context cancelled")
+	ErrTargetNotFound   = errors.New("target not found")
+	ErrAmbiguousTarget  = errors.New("ambiguous target")
+	ErrDeviceLost       = errors.New("device lost")
+	ErrScriptDestroyed  = errors.New("script destroyed")
+	ErrNoIcon           = errors.New("no icon")
 )
@@ -0,0 +1,426 @@
+package telco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ForwardStats describes the connection handled by the Forwarder.
+type ForwardStats struct {
+	ID uint64
+	// Client is the address of the local client.
+	Client string
+	// Channel is the address of the channel opened on the device.
+	Channel string
+	Started time.Time
+	// Ended is zero while the connection is active.
+	Ended time.Time
+	// BytesSent is the number of bytes sent from the client to the device.
+	BytesSent uint64
+	// BytesReceived is the number of bytes sent from the device to the client.
+	BytesReceived uint64
+	// Err is the error which ended the connection, if any.
+	Err error
+}
+
+// Forwarder forwards the connections accepted on the local address to the
+// channels opened on the device, like iproxy does.
+type Forwarder struct {
+	listener net.Listener
+	remote   string
+	deviceID string
+	// find looks the lost device up again by its ID.
+	find func(id string) (forwardDevice, error)
+
+	// devMu is held for writing while the lost device is replaced.
+	devMu  sync.RWMutex
+	device forwardDevice
+	// owned is whether the device was looked up by the forwarder, which
+	// releases it once it is replaced or the forwarder is closed.
+	owned bool
+
+	mu      sync.Mutex
+	conns   map[uint64]*forwardConn
+	nextID  uint64
+	closed  bool
+	onClose func(ForwardStats)
+	onError func(error)
+
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+// forwardDevice is the part of Device used by the Forwarder.
+type forwardDevice interface {
+	IsLost() bool
+	OpenChannel(address string) (*IOStream, error)
+	Clean()
+}
+
+type forwardConn struct {
+	stats    ForwardStats
+	sent     atomic.Uint64
+	received atomic.Uint64
+	client   net.Conn
+	channel  *IOStream
+}
+
+// Forward listens on localAddr and, for every connection accepted, opens the
+// channel remoteAddr on the device and pipes the data in both directions.
+//
+// localAddr is the TCP address such as "127.0.0.1:8080" or the Unix socket path
+// prefixed with "unix:". remoteAddr is the channel address accepted by OpenChannel,
+// such as "tcp:8080"; the port alone is treated as "tcp:PORT".
+//
+// If the device is lost, it is looked up by its ID again on the manager of the
+// device when the next connection is accepted, so forwarding resumes once the
+// device is back. The device itself stays owned by the caller.
+// The forwarder is closed once ctx is done.
+func (d *Device) Forward(ctx context.Context, localAddr, remoteAddr string) (*Forwarder, error) {
+	if d.device == nil {
+		return nil, errors.New("could not forward for nil device")
+	}
+	if remoteAddr == "" {
+		return nil, errors.New("you need to provide remote address")
+	}
+	if !strings.Contains(remoteAddr, ":") {
+		remoteAddr = "tcp:" + remoteAddr
+	}
+
+	listener, err := listenLocal(localAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	mgr := d.Manager()
+	f := newForwarder(listener, remoteAddr, d.ID(), d, func(id string) (forwardDevice, error) {
+		dev, err := mgr.FindDeviceByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if dev.device == nil {
+			return nil, fmt.Errorf("device %s is lost", id)
+		}
+		return dev, nil
+	})
+
+	go f.serve()
+	go func() {
+		select {
+		case <-ctx.Done():
+			f.Close()
+		case <-f.done:
+		}
+	}()
+
+	return f, nil
+}
+
+func newForwarder(listener net.Listener, remote, deviceID string, dev forwardDevice, find func(id string) (forwardDevice, error)) *Forwarder {
+	return &Forwarder{
+		listener: listener,
+		remote:   remote,
+		deviceID: deviceID,
+		find:     find,
+		device:   dev,
+		conns:    make(map[uint64]*forwardConn),
+		done:     make(chan struct{}),
+	}
+}
+
+// listenLocal listens on the TCP address or on the Unix socket path prefixed
+// with "unix:".
+func listenLocal(localAddr string) (net.Listener, error) {
+	if strings.HasPrefix(localAddr, "unix:") {
+		return net.Listen("unix", strings.TrimPrefix(localAddr, "unix:"))
+	}
+	return net.Listen("tcp", strings.TrimPrefix(localAddr, "tcp:"))
+}
+
+// Addr returns the local address the forwarder listens on.
+func (f *Forwarder) Addr() net.Addr {
+	return f.listener.Addr()
+}
+
+// Done returns the channel closed once the forwarder stops accepting connections.
+func (f *Forwarder) Done() <-chan struct{} {
+	return f.done
+}
+
+// OnClose sets the callback called with the final stats of every connection
+// once it ends.
+func (f *Forwarder) OnClose(fn func(stats ForwardStats)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onClose = fn
+}
+
+// OnError sets the callback called when the connection could not be forwarded,
+// e.g. because the channel could not be opened.
+func (f *Forwarder) OnError(fn func(err error)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.onError = fn
+}
+
+// Stats returns the stats of the active connections ordered by their ID.
+func (f *Forwarder) Stats() []ForwardStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := make([]ForwardStats, 0, len(f.conns))
+	for _, c := range f.conns {
+		stats = append(stats, c.snapshot())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ID < stats[j].ID
+	})
+	return stats
+}
+
+// Shutdown stops accepting new connections and waits for the active ones to
+// finish. Once ctx is done the remaining connections are closed and
+// ErrContextCancelled is returned.
+func (f *Forwarder) Shutdown(ctx context.Context) error {
+	f.stopListening()
+
+	finished := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		f.releaseDevice()
+		return nil
+	case <-ctx.Done():
+		f.closeConns()
+		<-finished
+		f.releaseDevice()
+		return ErrContextCancelled
+	}
+}
+
+// Close stops accepting new connections and closes the active ones.
+func (f *Forwarder) Close() error {
+	err := f.stopListening()
+	f.closeConns()
+	f.wg.Wait()
+	f.releaseDevice()
+	return err
+}
+
+func (f *Forwarder) stopListening() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	err := f.listener.Close()
+	<-f.done
+	return err
+}
+
+func (f *Forwarder) closeConns() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.client.Close()
+		c.channel.Close()
+	}
+}
+
+func (f *Forwarder) serve() {
+	defer close(f.done)
+
+	for {
+		client, err := f.listener.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return
+		}
+		f.wg.Add(1)
+		go f.handle(client)
+	}
+}
+
+// channel opens the channel on the device, looking the device up again if it
+// was lost.
+func (f *Forwarder) channel() (*IOStream, error) {
+	f.devMu.RLock()
+	lost := f.device.IsLost()
+	f.devMu.RUnlock()
+
+	if lost {
+		if err := f.replaceDevice(); err != nil {
+			return nil, err
+		}
+	}
+
+	f.devMu.RLock()
+	defer f.devMu.RUnlock()
+	return f.device.OpenChannel(f.remote)
+}
+
+// replaceDevice replaces the lost device with the one found by its ID.
+func (f *Forwarder) replaceDevice() error {
+	f.devMu.Lock()
+	defer f.devMu.Unlock()
+
+	// another connection replaced it meanwhile
+	if !f.device.IsLost() {
+		return nil
+	}
+	found, err := f.find(f.deviceID)
+	if err != nil {
+		return err
+	}
+	if f.owned {
+		f.device.Clean()
+	}
+	f.device, f.owned = found, true
+	return nil
+}
+
+// releaseDevice releases the device looked up by the forwarder.
+func (f *Forwarder) releaseDevice() {
+	f.devMu.Lock()
+	defer f.devMu.Unlock()
+	if f.owned {
+		f.device.Clean()
+		f.owned = false
+	}
+}
+
+func (f *Forwarder) handle(client net.Conn) {
+	defer f.wg.Done()
+
+	channel, err := f.channel()
+	if err != nil {
+		client.Close()
+		f.reportError(fmt.Errorf("forward %s to %s: %w", client.RemoteAddr(), f.remote, err))
+		return
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		client.Close()
+		channel.Close()
+		channel.Clean()
+		return
+	}
+	f.nextID++
+	c := &forwardConn{
+		stats: ForwardStats{
+			ID:      f.nextID,
+			Client:  client.RemoteAddr().String(),
+			Channel: f.remote,
+			Started: time.Now(),
+		},
+		client:  client,
+		channel: channel,
+	}
+	f.conns[c.stats.ID] = c
+	f.mu.Unlock()
+
+	err = c.pipe()
+
+	f.mu.Lock()
+	delete(f.conns, c.stats.ID)
+	onClose := f.onClose
+	f.mu.Unlock()
+
+	channel.Clean()
+	if onClose != nil {
+		stats := c.snapshot()
+		stats.Ended = time.Now()
+		stats.Err = err
+		onClose(stats)
+	}
+}
+
+func (f *Forwarder) reportError(err error) {
+	f.mu.Lock()
+	onError := f.onError
+	f.mu.Unlock()
+	if onError != nil {
+		onError(err)
+	}
+}
+
+func (c *forwardConn) snapshot() ForwardStats {
+	stats := c.stats
+	stats.BytesSent = c.sent.Load()
+	stats.BytesReceived = c.received.Load()
+	return stats
+}
+
+func (c *forwardConn) pipe() error {
+	return pipeConns(c.client, c.channel, &c.sent, &c.received)
+}
+
+// pipeConns copies the data between a and b in both directions until both sides
+// are done, propagating the half-close from one side to the other. The bytes
+// copied from a to b are added to sent and the ones from b to a to received,
+// unless they are nil.
+func pipeConns(a, b net.Conn, sent, received *atomic.Uint64) error {
+	errs := make(chan error, 2)
+	copyHalf := func(dst, src net.Conn, n *atomic.Uint64) {
+		_, err := io.Copy(&countingWriter{dst, n}, src)
+		if cw, ok := dst.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		} else {
+			dst.Close()
+		}
+		errs <- err
+	}
+	go copyHalf(b, a, sent)
+	go copyHalf(a, b, received)
+
+	var firstErr error
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			// one side failed, unblock the other one
+			a.Close()
+			b.Close()
+		}
+	}
+
+	a.Close()
+	b.Close()
+	if errors.Is(firstErr, net.ErrClosed) {
+		return nil
+	}
+	return firstErr
+}
+
+// countingWriter counts the bytes written to w; n can be nil.
+type countingWriter struct {
+	w io.Writer
+	n *atomic.Uint64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	if cw.n != nil {
+		cw.n.Add(uint64(n))
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+package telco
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+type fakeForwardDevice struct {
+	name    string
+	lost    bool
+	cleaned int
+}
+
+func (d *fakeForwardDevice) IsLost() bool {
+	return d.lost
+}
+
+func (d *fakeForwardDevice) OpenChannel(address string) (*IOStream, error) {
+	if d.lost {
+		return nil, ErrDeviceLost
+	}
+	return &IOStream{address: d.name + "/" + address}, nil
+}
+
+func (d *fakeForwardDevice) Clean() {
+	d.cleaned++
+}
+
+func TestForwarderReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original := &fakeForwardDevice{name: "original"}
+	var found []*fakeForwardDevice
+	var findErr error
+	f := newForwarder(listener, "tcp:80", "abc", original, func(id string) (forwardDevice, error) {
+		if id != "abc" {
+			t.Errorf("find(%q), want abc", id)
+		}
+		if findErr != nil {
+			return nil, findErr
+		}
+		dev := &fakeForwardDevice{name: "found"}
+		found = append(found, dev)
+		return dev, nil
+	})
+	go f.serve()
+
+	open := func(want string) {
+		t.Helper()
+		ios, err := f.channel()
+		if err != nil {
+			t.Fatalf("channel() error %v, want %s", err, want)
+		}
+		if ios.address != want {
+			t.Fatalf("channel() opened %s, want %s", ios.address, want)
+		}
+	}
+
+	open("original/tcp:80")
+	if len(found) != 0 {
+		t.Fatalf("device looked up %d times while connected", len(found))
+	}
+
+	// the lost device is looked up again, the caller's device is not released
+	original.lost = true
+	open("found/tcp:80")
+	open("found/tcp:80")
+	if len(found) != 1 || original.cleaned != 0 {
+		t.Fatalf("looked up %d times, original cleaned %d times, want 1 and 0", len(found), original.cleaned)
+	}
+
+	// the device found by the forwarder is released once it is replaced
+	found[0].lost = true
+	findErr = errors.New("device not found")
+	if _, err := f.channel(); err != findErr {
+		t.Fatalf("channel() error %v, want %v", err, findErr)
+	}
+	if found[0].cleaned != 0 {
+		t.Fatalf("lost device cleaned %d times before it was replaced", found[0].cleaned)
+	}
+	findErr = nil
+	open("found/tcp:80")
+	if len(found) != 2 || found[0].cleaned != 1 {
+		t.Fatalf("looked up %d times, replaced device cleaned %d times, want 2 and 1", len(found), found[0].cleaned)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if found[1].cleaned != 1 || original.cleaned != 0 {
+		t.Errorf("after Close found cleaned %d times, original %d times, want 1 and 0", found[1].cleaned, original.cleaned)
+	}
+	f.Close()
+	if found[1].cleaned != 1 {
+		t.Errorf("second Close cleaned the device again")
+	}
+}
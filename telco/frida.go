@@ -20,11 +20,11 @@ package telco
 */
 import "C"
 import (
+	"fmt"
 	"sync"
+	"unsafe"
 )
 
-var data = &sync.Map{}
-
 // PatchAndroidSELinux tries to patch selinux; root access is required.
 func PatchAndroidSELinux() {
 	C.android_patch_selinux()
@@ -35,69 +35,165 @@ func Version() string {
 	return C.GoString(C.telco_version_string())
 }
 
+// defaultCache holds the default manager and the devices obtained through it.
+// Entries are dropped once the device is lost or removed from the manager.
+// The lock is never held while calling into telco, as the signals invalidating
+// the entries are emitted while those calls are in progress.
+type defaultCache struct {
+	mu      sync.Mutex
+	mgr     *DeviceManager
+	devices map[string]*cachedDevice
+}
+
+type cachedDevice struct {
+	dev *Device
+	// obj is the TelcoDevice the entry is invalidated by.
+	obj  unsafe.Pointer
+	lost signalHandler
+}
+
+var defaults = &defaultCache{
+	devices: make(map[string]*cachedDevice),
+}
+
 func getDeviceManager() *DeviceManager {
-	v, ok := data.Load("mgr")
-	if !ok {
-		mgr := NewDeviceManager()
-		data.Store("mgr", mgr)
+	return defaults.manager()
+}
+
+// DefaultDeviceManager returns the manager used by LocalDevice, USBDevice and DeviceById.
+func DefaultDeviceManager() *DeviceManager {
+	return defaults.manager()
+}
+
+func (c *defaultCache) manager() *DeviceManager {
+	c.mu.Lock()
+	mgr := c.mgr
+	c.mu.Unlock()
+	if mgr != nil {
 		return mgr
 	}
-	return v.(*DeviceManager)
+
+	mgr = NewDeviceManager()
+	c.mu.Lock()
+	if c.mgr != nil {
+		c.mu.Unlock()
+		mgr.Clean()
+		return c.mgr
+	}
+	c.mgr = mgr
+	c.mu.Unlock()
+
+	connectClosure(unsafe.Pointer(mgr.manager), "removed", func(dev *Device) {
+		if dev != nil {
+			c.invalidate(unsafe.Pointer(dev.device))
+		}
+	})
+	return mgr
+}
+
+// device returns the cached device under key or looks it up using lookup.
+func (c *defaultCache) device(key string, lookup func(mgr *DeviceManager) (*Device, error)) (*Device, error) {
+	c.mu.Lock()
+	cached, ok := c.devices[key]
+	c.mu.Unlock()
+	if ok {
+		return cached.dev, nil
+	}
+
+	dev, err := lookup(c.manager())
+	if err != nil {
+		return nil, err
+	}
+	if dev.device == nil {
+		return nil, fmt.Errorf("device %s is not available", key)
+	}
+
+	obj := unsafe.Pointer(dev.device)
+	entry := &cachedDevice{dev: dev, obj: obj}
+	entry.lost = connectSignal(obj, "lost", func() {
+		c.invalidate(obj)
+	})
+
+	c.mu.Lock()
+	if existing, ok := c.devices[key]; ok {
+		c.mu.Unlock()
+		entry.lost.disconnect()
+		dev.Clean()
+		return existing.dev, nil
+	}
+	c.devices[key] = entry
+	c.mu.Unlock()
+
+	// checked once the entry is cached, so losing the device in between
+	// invalidates it either way
+	if dev.IsLost() {
+		c.invalidate(obj)
+		return nil, fmt.Errorf("device %s is not available", key)
+	}
+	return dev, nil
+}
+
+// invalidate drops all the entries of the device obj.
+func (c *defaultCache) invalidate(obj unsafe.Pointer) {
+	var handlers []signalHandler
+	c.mu.Lock()
+	for key, entry := range c.devices {
+		if entry.obj == obj {
+			handlers = append(handlers, entry.lost)
+			delete(c.devices, key)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, h := range handlers {
+		h.disconnect()
+	}
+}
+
+// reset drops all the cached devices.
+func (c *defaultCache) reset() {
+	c.mu.Lock()
+	devices := c.devices
+	c.devices = make(map[string]*cachedDevice)
+	c.mu.Unlock()
+
+	for _, entry := range devices {
+		entry.lost.disconnect()
+	}
+}
+
+// ResetDefaults drops the devices cached by LocalDevice, USBDevice and DeviceById,
+// so the next call looks them up again. Devices returned before remain usable.
+func ResetDefaults() {
+	defaults.reset()
 }
 
 // LocalDevice is a wrapper around DeviceByType(DeviceTypeLocal).
 func LocalDevice() *Device {
-	mgr := getDeviceManager()
-	v, ok := data.Load("localDevice")
-	if !ok {
-		dev, _ := mgr.DeviceByType(DeviceTypeLocal)
-		data.Store("localDevice", dev)
-		return dev
-	}
-	return v.(*Device)
+	dev, _ := defaults.device("type:local", func(mgr *DeviceManager) (*Device, error) {
+		return mgr.DeviceByType(DeviceTypeLocal)
+	})
+	return dev
 }
 
 // USBDevice is a wrapper around DeviceByType(DeviceTypeUsb).
+// The device is looked up again once the cached one is lost, e.g. when it is
+// unplugged and plugged back in.
 func USBDevice() *Device {
-	mgr := getDeviceManager()
-	v, ok := data.Load("usbDevice")
-	if !ok {
-		_, ok := data.Load("enumeratedDevices")
-		if !ok {
-			mgr.EnumerateDevices()
-			data.Store("enumeratedDevices", true)
-		}
-		dev, err := mgr.DeviceByType(DeviceTypeUsb)
-		if err != nil {
-			return nil
-		}
-		data.Store("usbDevice", dev)
-		return dev
-	}
-	return v.(*Device)
+	dev, _ := defaults.device("type:usb", func(mgr *DeviceManager) (*Device, error) {
+		return mgr.DeviceByType(DeviceTypeUsb)
+	})
+	return dev
 }
 
 // DeviceById tries to get the device by id on the default manager
 func DeviceById(id string) (*Device, error) {
-	mgr := getDeviceManager()
-	v, ok := data.Load(id)
-	if !ok {
-		_, ok := data.Load("enumeratedDevices")
-		if !ok {
-			mgr.EnumerateDevices()
-			data.Store("enumeratedDevices", true)
-		}
-		dev, err := mgr.DeviceByID(id)
-		if err != nil {
-			return nil, err
-		}
-		data.Store(id, dev)
-		return dev, nil
-	}
-	return v.(*Device), nil
+	return defaults.device("id:"+id, func(mgr *DeviceManager) (*Device, error) {
+		return mgr.DeviceByID(id)
+	})
 }
 
-// Attach attaches at val(string or int pid) using local device.
+// Attach attaches at val(anything accepted by NewTarget) using local device.
 func Attach(val any) (*Session, error) {
 	dev := LocalDevice()
 	return dev.Attach(val, nil)
@@ -0,0 +1,53 @@
+package telco
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"unsafe"
+)
+
+func TestDefaultCacheInvalidate(t *testing.T) {
+	var usb, remote int
+	usbDev, remoteDev := &Device{}, &Device{}
+
+	c := &defaultCache{devices: map[string]*cachedDevice{
+		"type:usb":  {dev: usbDev, obj: unsafe.Pointer(&usb)},
+		"id:abc":    {dev: usbDev, obj: unsafe.Pointer(&usb)},
+		"id:remote": {dev: remoteDev, obj: unsafe.Pointer(&remote)},
+	}}
+	keys := func() []string {
+		var keys []string
+		for key := range c.devices {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	// every key cached for the lost device is dropped
+	c.invalidate(unsafe.Pointer(&usb))
+	if got, want := keys(), []string{"id:remote"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after invalidating usb device cached %v, want %v", got, want)
+	}
+
+	// unknown devices leave the cache intact
+	var other int
+	c.invalidate(unsafe.Pointer(&other))
+	if got, want := keys(), []string{"id:remote"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("after invalidating unknown device cached %v, want %v", got, want)
+	}
+
+	cached, err := c.device("id:remote", func(*DeviceManager) (*Device, error) {
+		t.Fatal("cached device looked up again")
+		return nil, nil
+	})
+	if err != nil || cached != remoteDev {
+		t.Fatalf("device(id:remote) = %p, %v, want %p", cached, err, remoteDev)
+	}
+
+	c.reset()
+	if got := keys(); len(got) != 0 {
+		t.Fatalf("after reset cached %v", got)
+	}
+}
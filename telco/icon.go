@@ -0,0 +1,143 @@
+package telco
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+// IconFormat represents the encoding of the icon data.
+type IconFormat string
+
+const (
+	IconFormatRGBA IconFormat = "rgba"
+	IconFormatPNG  IconFormat = "png"
+)
+
+// Icon represents the icon of the device, process or application.
+// Icons come either as raw RGBA pixels with the width and height provided
+// or as PNG encoded image.
+type Icon struct {
+	Format IconFormat
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// UnmarshalParams decodes the icon from the icon dictionary found inside
+// the parameters ("format", "width", "height" and "image").
+func (i *Icon) UnmarshalParams(value any) error {
+	mp, ok := value.(map[string]any)
+	if !ok {
+		return fmt.Errorf("expected icon as map, got %T", value)
+	}
+
+	var raw struct {
+		Format string `telco:"format"`
+		Width  int    `telco:"width"`
+		Height int    `telco:"height"`
+		Image  []byte `telco:"image"`
+	}
+	if err := DecodeParams(mp, &raw); err != nil {
+		return err
+	}
+
+	i.Format = IconFormat(raw.Format)
+	i.Width = raw.Width
+	i.Height = raw.Height
+	i.Data = raw.Image
+
+	if i.Format == IconFormatPNG && (i.Width == 0 || i.Height == 0) {
+		if cfg, err := png.DecodeConfig(bytes.NewReader(i.Data)); err == nil {
+			i.Width = cfg.Width
+			i.Height = cfg.Height
+		}
+	}
+
+	return nil
+}
+
+// Size returns the width and height of the icon.
+func (i *Icon) Size() image.Point {
+	return image.Pt(i.Width, i.Height)
+}
+
+// Image decodes the icon into image.Image.
+func (i *Icon) Image() (image.Image, error) {
+	switch i.Format {
+	case IconFormatRGBA:
+		if i.Width <= 0 || i.Height <= 0 {
+			return nil, fmt.Errorf("invalid icon size %dx%d", i.Width, i.Height)
+		}
+		if len(i.Data) != i.Width*i.Height*4 {
+			return nil, fmt.Errorf("expected %d bytes of rgba data for %dx%d icon, got %d",
+				i.Width*i.Height*4,
+				i.Width,
+				i.Height,
+				len(i.Data))
+		}
+		return &image.NRGBA{
+			Pix:    i.Data,
+			Stride: i.Width * 4,
+			Rect:   image.Rect(0, 0, i.Width, i.Height),
+		}, nil
+	case IconFormatPNG:
+		return png.Decode(bytes.NewReader(i.Data))
+	default:
+		return nil, fmt.Errorf("unsupported icon format %q", i.Format)
+	}
+}
+
+// PNG returns the icon encoded as PNG.
+func (i *Icon) PNG() ([]byte, error) {
+	if i.Format == IconFormatPNG {
+		return i.Data, nil
+	}
+
+	img, err := i.Image()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// largestIcon returns the biggest icon or nil if there are none.
+func largestIcon(icons []Icon) *Icon {
+	var largest *Icon
+	for idx := range icons {
+		icon := &icons[idx]
+		if largest == nil || icon.Width*icon.Height > largest.Width*largest.Height {
+			largest = icon
+		}
+	}
+	return largest
+}
+
+// Icon returns the icon of the device or ErrNoIcon if the device has none.
+func (d *Device) Icon() (*Icon, error) {
+	if d == nil || d.device == nil {
+		return nil, errors.New("could not obtain icon for nil device")
+	}
+	return iconFromValue(d.DeviceIcon())
+}
+
+// iconFromValue decodes the icon dictionary returned for a device.
+func iconFromValue(value any) (*Icon, error) {
+	mp, ok := value.(map[string]any)
+	if !ok || len(mp) == 0 {
+		return nil, ErrNoIcon
+	}
+
+	var ret Icon
+	if err := ret.UnmarshalParams(mp); err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
@@ -0,0 +1,31 @@
+package telco
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDeviceIcon(t *testing.T) {
+	if _, err := (&Device{}).Icon(); err == nil || !strings.Contains(err.Error(), "nil device") {
+		t.Errorf("Icon() of nil device error %v", err)
+	}
+
+	tests := []struct {
+		value any
+		want  *Icon
+		err   error
+	}{
+		{nil, nil, ErrNoIcon},
+		{map[string]any{}, nil, ErrNoIcon},
+		{"icon", nil, ErrNoIcon},
+		{map[string]any{"format": "rgba", "width": int64(2), "height": int64(1), "image": []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+			&Icon{Format: IconFormatRGBA, Width: 2, Height: 1, Data: []byte{1, 2, 3, 4, 5, 6, 7, 8}}, nil},
+	}
+	for _, tt := range tests {
+		got, err := iconFromValue(tt.value)
+		if err != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("iconFromValue(%v) = %+v, %v, want %+v, %v", tt.value, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package telco
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"unsafe"
+)
+
+// InjectedLibrary represents the library injected with InjectLibraryFile or
+// InjectLibraryBlob. It is done once the device emits "uninjected" for it,
+// which happens when the entrypoint of the library returns and the library
+// is unloaded, or once the device is lost.
+type InjectedLibrary struct {
+	id         uint
+	pid        int
+	path       string
+	entrypoint string
+	data       string
+
+	done chan struct{}
+	once sync.Once
+	lost bool
+}
+
+func newInjectedLibrary(id uint, pid int, path, entrypoint, data string) *InjectedLibrary {
+	return &InjectedLibrary{
+		id:         id,
+		pid:        pid,
+		path:       path,
+		entrypoint: entrypoint,
+		data:       data,
+		done:       make(chan struct{}),
+	}
+}
+
+// ID returns the id of the injection assigned by the device.
+func (l *InjectedLibrary) ID() uint {
+	return l.id
+}
+
+// PID returns the pid of the process the library was injected into.
+func (l *InjectedLibrary) PID() int {
+	return l.pid
+}
+
+// Path returns the path of the library; it is empty for the libraries
+// injected with InjectLibraryBlob.
+func (l *InjectedLibrary) Path() string {
+	return l.path
+}
+
+// Entrypoint returns the entrypoint used for the injection.
+func (l *InjectedLibrary) Entrypoint() string {
+	return l.entrypoint
+}
+
+// Data returns the data passed to the entrypoint.
+func (l *InjectedLibrary) Data() string {
+	return l.data
+}
+
+// Done returns the channel closed once the library is uninjected or the
+// device is lost.
+func (l *InjectedLibrary) Done() <-chan struct{} {
+	return l.done
+}
+
+// Wait blocks until the library is uninjected. It returns ErrDeviceLost if the
+// device was lost before that and ErrContextCancelled if ctx is done first.
+func (l *InjectedLibrary) Wait(ctx context.Context) error {
+	select {
+	case <-l.done:
+		if l.lost {
+			return ErrDeviceLost
+		}
+		return nil
+	case <-ctx.Done():
+		return ErrContextCancelled
+	}
+}
+
+func (l *InjectedLibrary) finish(lost bool) {
+	l.once.Do(func() {
+		l.lost = lost
+		close(l.done)
+	})
+}
+
+// injectionRegistry tracks the live injections of the device.
+type injectionRegistry struct {
+	mu   sync.Mutex
+	libs map[uint]*InjectedLibrary
+	// pending is the number of injection calls that did not return yet.
+	pending int
+	// uninjected holds the ids reported while injection calls were pending,
+	// which may belong to the libraries these calls return.
+	uninjected map[uint]struct{}
+}
+
+// injections holds the injectionRegistry for every device pointer.
+var injections = &sync.Map{}
+
+// injectionRegistry returns the registry of the device, connecting to the
+// "uninjected" and "lost" signals the first time.
+func (d *Device) injectionRegistry() *injectionRegistry {
+	key := unsafe.Pointer(d.device)
+	if v, ok := injections.Load(key); ok {
+		return v.(*injectionRegistry)
+	}
+
+	reg := &injectionRegistry{
+		libs:       make(map[uint]*InjectedLibrary),
+		uninjected: make(map[uint]struct{}),
+	}
+	if v, loaded := injections.LoadOrStore(key, reg); loaded {
+		return v.(*injectionRegistry)
+	}
+
+	connectClosure(key, "uninjected", func(id uint) {
+		reg.remove(id)
+	})
+	connectClosure(key, "lost", func() {
+		injections.Delete(key)
+		reg.clear()
+	})
+	return reg
+}
+
+// begin is called before the injection call, so that the ids uninjected
+// before it returns are kept for end.
+func (r *injectionRegistry) begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending++
+}
+
+// end is called once the injection call returns, with the library injected or
+// nil if it failed. The uninjected ids are dropped once no call is pending.
+func (r *injectionRegistry) end(lib *InjectedLibrary) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending--
+	if lib != nil {
+		if _, ok := r.uninjected[lib.id]; ok {
+			delete(r.uninjected, lib.id)
+			lib.finish(false)
+		} else {
+			r.libs[lib.id] = lib
+		}
+	}
+	if r.pending == 0 && len(r.uninjected) > 0 {
+		r.uninjected = make(map[uint]struct{})
+	}
+}
+
+func (r *injectionRegistry) remove(id uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	lib, ok := r.libs[id]
+	if !ok {
+		if r.pending > 0 {
+			r.uninjected[id] = struct{}{}
+		}
+		return
+	}
+	delete(r.libs, id)
+	lib.finish(false)
+}
+
+func (r *injectionRegistry) clear() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, lib := range r.libs {
+		delete(r.libs, id)
+		lib.finish(true)
+	}
+	r.uninjected = make(map[uint]struct{})
+}
+
+func (r *injectionRegistry) list() []*InjectedLibrary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	libs := make([]*InjectedLibrary, 0, len(r.libs))
+	for _, lib := range r.libs {
+		libs = append(libs, lib)
+	}
+	sort.Slice(libs, func(i, j int) bool {
+		return libs[i].id < libs[j].id
+	})
+	return libs
+}
+
+// Injections returns the libraries injected on the device that were not
+// uninjected yet, ordered by their ID.
+// Only the injections done through this package are tracked.
+func (d *Device) Injections() []*InjectedLibrary {
+	if d.device == nil {
+		return nil
+	}
+	v, ok := injections.Load(unsafe.Pointer(d.device))
+	if !ok {
+		return nil
+	}
+	return v.(*injectionRegistry).list()
+}
+
+// Injection returns the live injection with the id provided or nil if there is none.
+func (d *Device) Injection(id uint) *InjectedLibrary {
+	for _, lib := range d.Injections() {
+		if lib.id == id {
+			return lib
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package telco
+
+import (
+	"context"
+	"testing"
+)
+
+func TestInjectionRegistry(t *testing.T) {
+	reg := &injectionRegistry{
+		libs:       make(map[uint]*InjectedLibrary),
+		uninjected: make(map[uint]struct{}),
+	}
+
+	// uninjected before the injection call returned
+	reg.begin()
+	reg.remove(1)
+	early := newInjectedLibrary(1, 100, "", "main", "")
+	reg.end(early)
+	select {
+	case <-early.Done():
+	default:
+		t.Error("library uninjected before the call returned is not done")
+	}
+
+	// ids of other injections are not kept once no call is pending
+	reg.remove(2)
+	reg.begin()
+	reg.remove(3)
+	reg.end(nil)
+	if len(reg.uninjected) != 0 {
+		t.Errorf("uninjected = %v, want none", reg.uninjected)
+	}
+
+	reg.begin()
+	lib := newInjectedLibrary(2, 100, "", "main", "")
+	reg.end(lib)
+	if libs := reg.list(); len(libs) != 1 || libs[0] != lib {
+		t.Fatalf("libs = %v, want [%v]", libs, lib)
+	}
+	reg.remove(2)
+	if err := lib.Wait(context.Background()); err != nil || len(reg.list()) != 0 {
+		t.Errorf("Wait = %v, libs = %v", err, reg.list())
+	}
+}
@@ -1,27 +1,32 @@
 package telco
 
-/*#include <telco-core.h>
-
-static void *
-read_input_stream(GInputStream *stream, gsize count, gsize *bytes_read, GError **error) {
-	void * buffer;
-	*bytes_read = g_input_stream_read(stream,buffer,count,NULL,&error);
-	return buffer;
-}
-*/
+//#include <telco-core.h>
 import "C"
 import (
 	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
 	"unsafe"
 )
 
 // IOStream type represents struct used to interact with the device using channels.
+// It implements net.Conn, so it can be used with io.Copy, bufio and net/http.
 type IOStream struct {
-	stream *C.GIOStream
-	input  *C.GInputStream
-	output *C.GOutputStream
+	stream  *C.GIOStream
+	input   *C.GInputStream
+	output  *C.GOutputStream
+	address string
+
+	mu     sync.Mutex
+	closed bool
+	rd     streamDeadline
+	wd     streamDeadline
 }
 
+var _ net.Conn = (*IOStream)(nil)
+
 // NewIOStream creates new IOStream.
 func NewIOStream(stream *C.GIOStream) *IOStream {
 	input := C.g_io_stream_get_input_stream(stream)
@@ -33,115 +38,355 @@ func NewIOStream(stream *C.GIOStream) *IOStream {
 	}
 }
 
+// ChannelAddr is the address of the channel opened with OpenChannel,
+// such as "tcp:8080".
+type ChannelAddr string
+
+// Network returns "telco".
+func (a ChannelAddr) Network() string {
+	return "telco"
+}
+
+// String returns the address of the channel.
+func (a ChannelAddr) String() string {
+	return string(a)
+}
+
+// LocalAddr returns the address of the channel; both ends of the channel
+// are represented by it.
+func (ios *IOStream) LocalAddr() net.Addr {
+	return ChannelAddr(ios.address)
+}
+
+// RemoteAddr returns the address of the channel.
+func (ios *IOStream) RemoteAddr() net.Addr {
+	return ChannelAddr(ios.address)
+}
+
 // IsClosed returns whether the stream is closed or not.
 func (ios *IOStream) IsClosed() bool {
+	ios.mu.Lock()
+	defer ios.mu.Unlock()
+	if ios.stream == nil {
+		return true
+	}
 	closed := C.g_io_stream_is_closed(ios.stream)
 	return int(closed) == 1
 }
 
-// Close closes the stream.
+// Close closes the stream. Pending Read and Write calls are unblocked and
+// return net.ErrClosed; Close waits for them to return before closing the
+// underlying stream. The stream is released afterwards, so Clean is only
+// needed for the streams that are never closed.
 func (ios *IOStream) Close() error {
+	ios.mu.Lock()
+	if ios.closed {
+		ios.mu.Unlock()
+		return net.ErrClosed
+	}
+	ios.closed = true
+	ios.mu.Unlock()
+
+	// the operations in flight need to return before the stream is closed
+	ios.rd.close()
+	ios.wd.close()
+
 	var err *C.GError
 	C.g_io_stream_close(ios.stream, nil, &err)
+	ios.Clean()
 	if err != nil {
 		return &FError{err}
 	}
 	return nil
 }
 
-// Read tries to read len(data) bytes into the data from the stream.
-func (ios *IOStream) Read(data *[]byte) (int, error) {
-	if len(*data) == 0 {
+// CloseRead closes the reading side of the stream.
+func (ios *IOStream) CloseRead() error {
+	cancellable, err := ios.rd.begin()
+	if err != nil {
+		return err
+	}
+	defer ios.rd.end(cancellable)
+
+	var gerr *C.GError
+	C.g_input_stream_close(ios.input, cancellable, &gerr)
+	if gerr != nil {
+		return &FError{gerr}
+	}
+	return nil
+}
+
+// CloseWrite closes the writing side of the stream, so the other end reads EOF
+// while the stream can still be read from.
+func (ios *IOStream) CloseWrite() error {
+	cancellable, err := ios.wd.begin()
+	if err != nil {
+		return err
+	}
+	defer ios.wd.end(cancellable)
+
+	var gerr *C.GError
+	C.g_output_stream_close(ios.output, cancellable, &gerr)
+	if gerr != nil {
+		return &FError{gerr}
+	}
+	return nil
+}
+
+func (ios *IOStream) isClosed() bool {
+	ios.mu.Lock()
+	defer ios.mu.Unlock()
+	return ios.closed
+}
+
+// Read reads up to len(data) bytes into data. It returns io.EOF once the other
+// end closes the channel.
+func (ios *IOStream) Read(data []byte) (int, error) {
+	if ios.isClosed() {
+		return 0, net.ErrClosed
+	}
+	if len(data) == 0 {
 		return 0, nil
 	}
 
-	buf := C.CBytes(*data)
-	defer C.free(unsafe.Pointer(buf))
+	cancellable, err := ios.rd.begin()
+	if err != nil {
+		return 0, err
+	}
 
-	count := C.gsize(len(*data))
-	var err *C.GError
+	var gerr *C.GError
 	read := C.g_input_stream_read(ios.input,
-		unsafe.Pointer(buf),
-		count,
-		nil,
-		&err)
-	if err != nil {
-		return -1, &FError{err}
+		unsafe.Pointer(&data[0]),
+		C.gsize(len(data)),
+		cancellable,
+		&gerr)
+	expired := ios.rd.end(cancellable)
+	if gerr != nil {
+		return 0, ios.streamError(gerr, expired)
 	}
 
 	if int(read) == 0 {
 		return 0, io.EOF
 	}
-
-	dataRead := C.GoBytes(unsafe.Pointer(buf), C.int(read))
-	copy(*data, dataRead)
-
 	return int(read), nil
 }
 
 // ReadAll tries to read all the bytes provided with the count from the stream.
+// Fewer bytes are returned when the stream reaches EOF first.
 func (ios *IOStream) ReadAll(count int) ([]byte, error) {
-	bt := make([]byte, count)
-	buf := C.CBytes(bt)
-	defer C.free(unsafe.Pointer(buf))
+	if ios.isClosed() {
+		return nil, net.ErrClosed
+	}
+	data := make([]byte, count)
+	if count == 0 {
+		return data, nil
+	}
+
+	cancellable, err := ios.rd.begin()
+	if err != nil {
+		return nil, err
+	}
 
-	countC := C.gsize(count)
 	var bytesRead C.gsize
-	var err *C.GError
+	var gerr *C.GError
 	C.g_input_stream_read_all(
 		ios.input,
-		buf,
-		countC,
+		unsafe.Pointer(&data[0]),
+		C.gsize(count),
 		&bytesRead,
-		nil,
-		&err)
-	if err != nil {
-		return nil, &FError{err}
+		cancellable,
+		&gerr)
+	expired := ios.rd.end(cancellable)
+	if gerr != nil {
+		return nil, ios.streamError(gerr, expired)
 	}
-	return C.GoBytes(unsafe.Pointer(buf), C.int(bytesRead)), nil
+	return data[:int(bytesRead)], nil
 }
 
-// Write tries to write len(data) bytes to the stream.
+// Write writes all the data to the stream. It returns the number of bytes written
+// and the error if fewer than len(data) bytes were written.
 func (ios *IOStream) Write(data []byte) (int, error) {
+	if ios.isClosed() {
+		return 0, net.ErrClosed
+	}
 	if len(data) == 0 {
 		return 0, nil
 	}
-	buf := C.CBytes(data)
-	count := C.gsize(len(data))
-	var err *C.GError
-	written := C.g_output_stream_write(ios.output,
-		buf,
-		count,
-		nil,
-		&err)
+
+	cancellable, err := ios.wd.begin()
 	if err != nil {
-		return 0, &FError{err}
+		return 0, err
+	}
+
+	var written C.gsize
+	var gerr *C.GError
+	C.g_output_stream_write_all(ios.output,
+		unsafe.Pointer(&data[0]),
+		C.gsize(len(data)),
+		&written,
+		cancellable,
+		&gerr)
+	expired := ios.wd.end(cancellable)
+	if gerr != nil {
+		return int(written), ios.streamError(gerr, expired)
 	}
 	return int(written), nil
 }
 
 // WriteAll tries to write all the data provided.
 func (ios *IOStream) WriteAll(data []byte) error {
-	if len(data) == 0 {
-		return nil
-	}
-	buf := C.CBytes(data)
-	count := C.gsize(len(data))
-	var err *C.GError
-	C.g_output_stream_write(ios.output,
-		buf,
-		count,
-		nil,
-		&err)
-	if err != nil {
-		return &FError{err}
-	}
+	_, err := ios.Write(data)
+	return err
+}
+
+// SetDeadline sets the read and write deadlines of the stream.
+func (ios *IOStream) SetDeadline(t time.Time) error {
+	ios.rd.set(t)
+	ios.wd.set(t)
+	return nil
+}
+
+// SetReadDeadline sets the deadline for the pending and future Read calls.
+// Once it is exceeded they fail with os.ErrDeadlineExceeded.
+// A zero value for t means Read will not time out.
+func (ios *IOStream) SetReadDeadline(t time.Time) error {
+	ios.rd.set(t)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for the pending and future Write calls.
+// Once it is exceeded they fail with os.ErrDeadlineExceeded.
+// A zero value for t means Write will not time out.
+func (ios *IOStream) SetWriteDeadline(t time.Time) error {
+	ios.wd.set(t)
 	return nil
 }
 
-// Clean will clean resources held by the iostream.
+// streamError converts the error of the cancelled operation into the error
+// describing why it was cancelled.
+func (ios *IOStream) streamError(gerr *C.GError, expired bool) error {
+	switch {
+	case expired:
+		C.g_error_free(gerr)
+		return os.ErrDeadlineExceeded
+	case ios.isClosed():
+		C.g_error_free(gerr)
+		return net.ErrClosed
+	}
+	return &FError{gerr}
+}
+
+// Clean will clean resources held by the iostream. It is called by Close,
+// so it is safe to call it on the closed stream.
 func (ios *IOStream) Clean() {
+	ios.mu.Lock()
+	defer ios.mu.Unlock()
 	clean(unsafe.Pointer(ios.stream), unrefGObject)
-	clean(unsafe.Pointer(ios.input), unrefGObject)
-	clean(unsafe.Pointer(ios.output), unrefGObject)
+	ios.stream = nil
+	ios.input = nil
+	ios.output = nil
+}
+
+// streamDeadline cancels the pending operations on one side of the stream
+// once the deadline is exceeded.
+type streamDeadline struct {
+	mu       sync.Mutex
+	deadline time.Time
+	timer    *time.Timer
+	// pending maps the cancellable of every operation in flight to whether
+	// it was cancelled by the deadline.
+	pending map[*C.GCancellable]bool
+	closed  bool
+	ops     sync.WaitGroup
+}
+
+// begin returns the cancellable for the new operation, os.ErrDeadlineExceeded
+// if the deadline was already exceeded or net.ErrClosed once the side is closed.
+func (d *streamDeadline) begin() (*C.GCancellable, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return nil, net.ErrClosed
+	}
+	if !d.deadline.IsZero() && !time.Now().Before(d.deadline) {
+		return nil, os.ErrDeadlineExceeded
+	}
+	if d.pending == nil {
+		d.pending = make(map[*C.GCancellable]bool)
+	}
+	cancellable := C.g_cancellable_new()
+	d.pending[cancellable] = false
+	d.ops.Add(1)
+	if d.timer == nil {
+		d.arm()
+	}
+	return cancellable, nil
+}
+
+// end releases the cancellable and returns whether the deadline was exceeded.
+func (d *streamDeadline) end(cancellable *C.GCancellable) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expired := d.pending[cancellable]
+	delete(d.pending, cancellable)
+	if len(d.pending) == 0 && d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	C.g_object_unref(C.gpointer(cancellable))
+	d.ops.Done()
+	return expired
+}
+
+// arm starts the timer cancelling the pending operations; d.mu needs to be held.
+func (d *streamDeadline) arm() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	if d.deadline.IsZero() || len(d.pending) == 0 {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(d.deadline), func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		// the deadline was changed after the timer fired
+		if d.timer != timer {
+			return
+		}
+		d.timer = nil
+		for cancellable := range d.pending {
+			d.pending[cancellable] = true
+			C.g_cancellable_cancel(cancellable)
+		}
+	})
+	d.timer = timer
+}
+
+func (d *streamDeadline) set(t time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.deadline = t
+	d.arm()
+}
+
+// close cancels the pending operations, makes the new ones fail with
+// net.ErrClosed and waits until the pending ones return.
+func (d *streamDeadline) close() {
+	d.mu.Lock()
+	d.closed = true
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	for cancellable := range d.pending {
+		C.g_cancellable_cancel(cancellable)
+	}
+	d.mu.Unlock()
+
+	d.ops.Wait()
 }
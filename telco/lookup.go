@@ -0,0 +1,32 @@
+package telco
+
+import "time"
+
+// LookupOption configures the device and process lookups such as FindDeviceByID
+// or FindProcessByName.
+type LookupOption func(*lookupOptions)
+
+type lookupOptions struct {
+	// timeout is in milliseconds, -1 waits forever
+	timeout int
+}
+
+// WithTimeout sets how long the lookup waits for the device or the process to
+// appear. Negative timeout waits forever.
+func WithTimeout(timeout time.Duration) LookupOption {
+	return func(o *lookupOptions) {
+		if timeout < 0 {
+			o.timeout = -1
+			return
+		}
+		o.timeout = int(timeout / time.Millisecond)
+	}
+}
+
+func newLookupOptions(defaultTimeout int, opts []LookupOption) lookupOptions {
+	o := lookupOptions{timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
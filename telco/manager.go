@@ -3,7 +3,10 @@ package telco
 //#include <telco-core.h>
 import "C"
 
-import "unsafe"
+import (
+	"context"
+	"unsafe"
+)
 
 // DeviceManager is the main structure which holds on devices available to Telco
 // Single instance of the DeviceManager is created when you call telco.Attach() or telco.LocalDevice().
@@ -64,11 +67,12 @@ func (d *DeviceManager) RemoteDevice() (*Device, error) {
 }
 
 // DeviceByID will return device with id passed or an error if it can't find any.
-func (d *DeviceManager) DeviceByID(id string) (*Device, error) {
+// The timeout of the lookup can be set with WithTimeout.
+func (d *DeviceManager) DeviceByID(id string, opts ...LookupOption) (*Device, error) {
 	idC := C.CString(id)
 	defer C.free(unsafe.Pointer(idC))
 
-	timeout := C.gint(defaultDeviceTimeout)
+	timeout := C.gint(newLookupOptions(defaultDeviceTimeout, opts).timeout)
 
 	var err *C.GError
 	device := C.telco_device_manager_get_device_by_id_sync(d.manager, idC, timeout, nil, &err)
@@ -79,11 +83,14 @@ func (d *DeviceManager) DeviceByID(id string) (*Device, error) {
 }
 
 // DeviceByType will return device or an error by device type specified.
-func (d *DeviceManager) DeviceByType(devType DeviceType) (*Device, error) {
+// The timeout of the lookup can be set with WithTimeout.
+func (d *DeviceManager) DeviceByType(devType DeviceType, opts ...LookupOption) (*Device, error) {
+	timeout := C.gint(newLookupOptions(1, opts).timeout)
+
 	var err *C.GError
 	device := C.telco_device_manager_get_device_by_type_sync(d.manager,
 		C.TelcoDeviceType(devType),
-		1,
+		timeout,
 		nil,
 		&err)
 	if err != nil {
@@ -92,12 +99,13 @@ func (d *DeviceManager) DeviceByType(devType DeviceType) (*Device, error) {
 	return &Device{device: device}, nil
 }
 
-// FindDeviceByID will try to find the device by id specified
-func (d *DeviceManager) FindDeviceByID(id string) (*Device, error) {
+// FindDeviceByID will try to find the device by id specified.
+// The timeout of the lookup can be set with WithTimeout.
+func (d *DeviceManager) FindDeviceByID(id string, opts ...LookupOption) (*Device, error) {
 	devID := C.CString(id)
 	defer C.free(unsafe.Pointer(devID))
 
-	timeout := C.gint(defaultDeviceTimeout)
+	timeout := C.gint(newLookupOptions(defaultDeviceTimeout, opts).timeout)
 
 	var err *C.GError
 	device := C.telco_device_manager_find_device_by_id_sync(d.manager,
@@ -112,14 +120,15 @@ func (d *DeviceManager) FindDeviceByID(id string) (*Device, error) {
 	return &Device{device: device}, nil
 }
 
-// FindDeviceByType will try to find the device by device type specified
-func (d *DeviceManager) FindDeviceByType(devType DeviceType) (*Device, error) {
-	timeout := C.gint(defaultDeviceTimeout)
+// FindDeviceByType will try to find the device by device type specified.
+// The timeout of the lookup can be set with WithTimeout.
+func (d *DeviceManager) FindDeviceByType(devType DeviceType, opts ...LookupOption) (*Device, error) {
+	timeout := C.gint(newLookupOptions(defaultDeviceTimeout, opts).timeout)
 
 	var err *C.GError
 	device := C.telco_device_manager_find_device_by_type_sync(d.manager,
 		C.TelcoDeviceType(devType),
-		C.gint(timeout),
+		timeout,
 		nil,
 		&err)
 	if err != nil {
@@ -131,12 +140,43 @@ func (d *DeviceManager) FindDeviceByType(devType DeviceType) (*Device, error) {
 
 // AddRemoteDevice add a remote device from the provided address with remoteOpts populated
 func (d *DeviceManager) AddRemoteDevice(address string, remoteOpts *RemoteDeviceOptions) (*Device, error) {
+	return d.AddRemoteDeviceContext(context.Background(), address, remoteOpts)
+}
+
+// AddRemoteDeviceContext is AddRemoteDevice cancelled once ctx is done, in which
+// case it returns ErrContextCancelled.
+func (d *DeviceManager) AddRemoteDeviceContext(ctx context.Context, address string, remoteOpts *RemoteDeviceOptions) (*Device, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ErrContextCancelled
+	}
+
 	addressC := C.CString(address)
 	defer C.free(unsafe.Pointer(addressC))
 
+	cancellable := C.g_cancellable_new()
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			C.g_cancellable_cancel(cancellable)
+		case <-finished:
+		}
+	}()
+	defer func() {
+		close(finished)
+		<-stopped
+		C.g_object_unref(C.gpointer(cancellable))
+	}()
+
 	var err *C.GError
-	device := C.telco_device_manager_add_remote_device_sync(d.manager, addressC, remoteOpts.opts, nil, &err)
+	device := C.telco_device_manager_add_remote_device_sync(d.manager, addressC, remoteOpts.opts, cancellable, &err)
 	if err != nil {
+		if ctx.Err() != nil {
+			C.g_error_free(err)
+			return nil, ErrContextCancelled
+		}
 		return nil, &FError{err}
 	}
 
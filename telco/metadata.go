@@ -0,0 +1,114 @@
+package telco
+
+import "time"
+
+// OSInfo represents the operating system part of the SystemInfo.
+type OSInfo struct {
+	ID      string `telco:"id"`
+	Name    string `telco:"name"`
+	Version string `telco:"version"`
+}
+
+// SystemInfo represents typed system parameters of the device.
+type SystemInfo struct {
+	OS       OSInfo `telco:"os"`
+	Platform string `telco:"platform"`
+	Arch     string `telco:"arch"`
+	Access   string `telco:"access"`
+	Name     string `telco:"name"`
+
+	// Raw holds all the parameters, including the ones not covered by the fields.
+	Raw map[string]any `telco:"-"`
+}
+
+// ProcessInfo represents typed parameters of the process.
+// Fields other than PID and Name are populated only when the process
+// was obtained with ScopeMetadata or ScopeFull.
+type ProcessInfo struct {
+	PID     int       `telco:"-"`
+	Name    string    `telco:"-"`
+	PPID    int       `telco:"ppid"`
+	User    string    `telco:"user"`
+	Path    string    `telco:"path"`
+	Started time.Time `telco:"started"`
+	Icons   []Icon    `telco:"icons"`
+
+	// Raw holds all the parameters, including the ones not covered by the fields.
+	Raw map[string]any `telco:"-"`
+}
+
+// ApplicationInfo represents typed parameters of the application.
+// Fields are populated only when the application was obtained with
+// ScopeMetadata or ScopeFull.
+type ApplicationInfo struct {
+	Identifier string            `telco:"-"`
+	Name       string            `telco:"-"`
+	PID        int               `telco:"-"`
+	Version    string            `telco:"version"`
+	Build      string            `telco:"build"`
+	Path       string            `telco:"path"`
+	Containers map[string]string `telco:"containers"`
+	Debuggable bool              `telco:"debuggable"`
+	PPID       int               `telco:"ppid"`
+	User       string            `telco:"user"`
+	Started    time.Time         `telco:"started"`
+	Icons      []Icon            `telco:"icons"`
+
+	// Raw holds all the parameters, including the ones not covered by the fields.
+	Raw map[string]any `telco:"-"`
+}
+
+// Icon returns the largest icon of the process or nil if there are none.
+// Icons are populated only with ScopeFull.
+func (p *ProcessInfo) Icon() *Icon {
+	return largestIcon(p.Icons)
+}
+
+// Icon returns the largest icon of the application or nil if there are none.
+// Icons are populated only with ScopeFull.
+func (a *ApplicationInfo) Icon() *Icon {
+	return largestIcon(a.Icons)
+}
+
+// SystemInfo returns typed system parameters of the device.
+func (d *Device) SystemInfo() (*SystemInfo, error) {
+	params, err := d.Params()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &SystemInfo{Raw: params}
+	if err := DecodeParams(params, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Info returns typed parameters of the process.
+func (p *Process) Info() (*ProcessInfo, error) {
+	params := p.Params()
+	info := &ProcessInfo{
+		PID:  p.PID(),
+		Name: p.Name(),
+		Raw:  params,
+	}
+	if err := DecodeParams(params, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Info returns typed parameters of the application.
+func (a *Application) Info() (*ApplicationInfo, error) {
+	params := a.Params()
+	info := &ApplicationInfo{
+		Identifier: a.Identifier(),
+		Name:       a.Name(),
+		PID:        a.PID(),
+		Raw:        params,
+	}
+	if err := DecodeParams(params, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -59,9 +59,9 @@ func ScriptMessageToMessage(message string) (*Message, error) {
 	if err := json.Unmarshal([]byte(message), &m); err != nil {
 		return nil, err
 	}
-	if m.Type != MessageTypeError {
+	if raw, ok := m.Payload.(string); ok && m.Type != MessageTypeError {
 		var payload map[string]any
-		if err := json.Unmarshal([]byte(m.Payload.(string)), &payload); err == nil {
+		if err := json.Unmarshal([]byte(raw), &payload); err == nil {
 			m.Payload = payload
 			m.IsPayloadMap = true
 		}
@@ -0,0 +1,106 @@
+package telco
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/telco/telco-go/demangle"
+)
+
+// NativePointer is the address sent by the agent, either as the "0x..." string
+// a NativePointer serializes to or as a number.
+type NativePointer uint64
+
+// String returns the address in the "0x..." form.
+func (p NativePointer) String() string {
+	return fmt.Sprintf("%#x", uint64(p))
+}
+
+// UnmarshalParams decodes the pointer from its string or numeric form.
+func (p *NativePointer) UnmarshalParams(value any) error {
+	switch val := value.(type) {
+	case string:
+		n, err := strconv.ParseUint(val, 0, 64)
+		if err != nil {
+			return fmt.Errorf("invalid pointer %q", val)
+		}
+		*p = NativePointer(n)
+		return nil
+	default:
+		var n uint64
+		if err := newConvertOptions(nil).decodeValue(value, reflect.ValueOf(&n).Elem(), "pointer"); err != nil {
+			return err
+		}
+		*p = NativePointer(n)
+		return nil
+	}
+}
+
+// Module is the module loaded inside the process, as returned by
+// Process.enumerateModules() inside the agent.
+type Module struct {
+	Name string        `telco:"name" json:"name"`
+	Base NativePointer `telco:"base" json:"base"`
+	Size uint64        `telco:"size" json:"size"`
+	Path string        `telco:"path" json:"path"`
+}
+
+// ModuleExport is the export of the module, as returned by
+// Module.enumerateExports() inside the agent. Type is "function" or "variable".
+type ModuleExport struct {
+	Type    string        `telco:"type" json:"type"`
+	Name    string        `telco:"name" json:"name"`
+	Address NativePointer `telco:"address" json:"address"`
+}
+
+// DemangledName returns the demangled name of the export, or the name itself
+// if it is not a mangled C++, Rust or Swift symbol.
+func (e ModuleExport) DemangledName() string {
+	return demangle.Filter(e.Name)
+}
+
+// DecodeModules decodes the modules returned by Script.ExportsCall for an
+// export returning Process.enumerateModules().
+func DecodeModules(v any) ([]Module, error) {
+	return decodeList[Module](v, "module")
+}
+
+// DecodeModuleExports decodes the exports returned by Script.ExportsCall for
+// an export returning Module.enumerateExports(). When demangled is true the
+// names are replaced with their demangled form.
+func DecodeModuleExports(v any, demangled bool) ([]ModuleExport, error) {
+	exports, err := decodeList[ModuleExport](v, "export")
+	if err != nil {
+		return nil, err
+	}
+	if demangled {
+		for i := range exports {
+			exports[i].Name = exports[i].DemangledName()
+		}
+	}
+	return exports, nil
+}
+
+// decodeList decodes the list of maps into the structs of type T.
+func decodeList[T any](v any, what string) ([]T, error) {
+	if err, ok := v.(error); ok {
+		return nil, err
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("expected list of %ss, got %T", what, v)
+	}
+
+	ret := make([]T, len(items))
+	for i, item := range items {
+		mp, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected %s %d as map, got %T", what, i, item)
+		}
+		if err := DecodeParams(mp, &ret[i]); err != nil {
+			return nil, fmt.Errorf("%s %d: %w", what, i, err)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,55 @@
+package telco
+
+import "testing"
+
+func TestDecodeModuleExports(t *testing.T) {
+	ret := []any{
+		map[string]any{"type": "function", "name": "_ZN3foo3barEv", "address": "0x7f0012345678"},
+		map[string]any{"type": "variable", "name": "main", "address": float64(4096)},
+	}
+
+	exports, err := DecodeModuleExports(ret, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ModuleExport{
+		{Type: "function", Name: "foo::bar()", Address: 0x7f0012345678},
+		{Type: "variable", Name: "main", Address: 4096},
+	}
+	for i, exp := range exports {
+		if exp != want[i] {
+			t.Errorf("export %d = %+v, want %+v", i, exp, want[i])
+		}
+	}
+
+	exports, err = DecodeModuleExports(ret, false)
+	if err != nil || exports[0].Name != "_ZN3foo3barEv" || exports[0].DemangledName() != "foo::bar()" {
+		t.Errorf("exports without demangling = %+v, %v", exports, err)
+	}
+
+	if _, err := DecodeModuleExports([]any{map[string]any{"address": "nope"}}, false); err == nil {
+		t.Error("invalid address decoded")
+	}
+}
+
+func TestDecodeModules(t *testing.T) {
+	ret := []any{
+		map[string]any{"name": "libc.so.6", "base": "0x7f0000000000", "size": float64(2 << 20), "path": "/usr/lib/libc.so.6"},
+	}
+
+	modules, err := DecodeModules(ret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Module{Name: "libc.so.6", Base: 0x7f0000000000, Size: 2 << 20, Path: "/usr/lib/libc.so.6"}
+	if len(modules) != 1 || modules[0] != want {
+		t.Errorf("modules = %+v, want %+v", modules, want)
+	}
+	if modules[0].Base.String() != "0x7f0000000000" {
+		t.Errorf("base = %s", modules[0].Base)
+	}
+
+	if _, err := DecodeModules("nope"); err == nil {
+		t.Error("decoded modules from a string")
+	}
+}
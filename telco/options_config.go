@@ -0,0 +1,384 @@
+package telco
+
+//#include <telco-core.h>
+import "C"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// SessionConfig is the serializable form of SessionOptions.
+type SessionConfig struct {
+	Realm RealmName `json:"realm,omitempty" yaml:"realm,omitempty"`
+	// PersistTimeout is the number of seconds the session is kept after the
+	// connection is lost.
+	PersistTimeout uint `json:"persist_timeout,omitempty" yaml:"persist_timeout,omitempty"`
+}
+
+// Validate returns the error if the config is not valid.
+func (c SessionConfig) Validate() error {
+	if r := Realm(c.Realm); r != RealmNative && r != RealmEmulated {
+		return fmt.Errorf("invalid realm %d", int(c.Realm))
+	}
+	return nil
+}
+
+// Options returns the SessionOptions described by the config.
+func (c SessionConfig) Options() (*SessionOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return NewSessionOptions(Realm(c.Realm), c.PersistTimeout), nil
+}
+
+// Config returns the config describing the options.
+func (s *SessionOptions) Config() SessionConfig {
+	return SessionConfig{
+		Realm:          RealmName(s.Realm()),
+		PersistTimeout: uint(s.PersistTimeout()),
+	}
+}
+
+// ScriptConfig is the serializable form of ScriptOptions.
+type ScriptConfig struct {
+	Name    string            `json:"name,omitempty" yaml:"name,omitempty"`
+	Runtime ScriptRuntimeName `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+	// Snapshot is the snapshot created by Session.SnapshotScript, base64 encoded in JSON.
+	Snapshot          []byte                `json:"snapshot,omitempty" yaml:"snapshot,omitempty"`
+	SnapshotTransport SnapshotTransportName `json:"snapshot_transport,omitempty" yaml:"snapshot_transport,omitempty"`
+}
+
+// Validate returns the error if the config is not valid.
+func (c ScriptConfig) Validate() error {
+	if r := ScriptRuntime(c.Runtime); r < ScriptRuntimeDefault || r > ScriptRuntimeV8 {
+		return fmt.Errorf("invalid runtime %d", int(c.Runtime))
+	}
+	if t := SnapshotTransport(c.SnapshotTransport); t != SnapshotTransportInline && t != SnapshotTransportSharedMemory {
+		return fmt.Errorf("invalid snapshot transport %d", int(c.SnapshotTransport))
+	}
+	return nil
+}
+
+// Options returns the ScriptOptions described by the config.
+func (c ScriptConfig) Options() (*ScriptOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := NewScriptOptions(c.Name)
+	opts.SetRuntime(ScriptRuntime(c.Runtime))
+	if len(c.Snapshot) > 0 {
+		opts.SetSnapshot(c.Snapshot)
+	}
+	opts.SetSnapshotTransport(SnapshotTransport(c.SnapshotTransport))
+	return opts, nil
+}
+
+// Config returns the config describing the options.
+func (s *ScriptOptions) Config() ScriptConfig {
+	cfg := ScriptConfig{
+		Name:              s.Name(),
+		Runtime:           ScriptRuntimeName(s.Runtime()),
+		SnapshotTransport: SnapshotTransportName(s.SnapshotTransport()),
+	}
+	if snapshot := s.Snapshot(); len(snapshot) > 0 {
+		cfg.Snapshot = snapshot
+	}
+	return cfg
+}
+
+// SnapshotConfig is the serializable form of SnapshotOptions.
+type SnapshotConfig struct {
+	WarmupScript string            `json:"warmup_script,omitempty" yaml:"warmup_script,omitempty"`
+	Runtime      ScriptRuntimeName `json:"runtime,omitempty" yaml:"runtime,omitempty"`
+}
+
+// Validate returns the error if the config is not valid.
+func (c SnapshotConfig) Validate() error {
+	if r := ScriptRuntime(c.Runtime); r < ScriptRuntimeDefault || r > ScriptRuntimeV8 {
+		return fmt.Errorf("invalid runtime %d", int(c.Runtime))
+	}
+	return nil
+}
+
+// Options returns the SnapshotOptions described by the config.
+func (c SnapshotConfig) Options() (*SnapshotOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return NewSnapshotOptions(c.WarmupScript, ScriptRuntime(c.Runtime)), nil
+}
+
+// Config returns the config describing the options.
+func (s *SnapshotOptions) Config() SnapshotConfig {
+	return SnapshotConfig{
+		WarmupScript: s.WarmupScript(),
+		Runtime:      ScriptRuntimeName(s.Runtime()),
+	}
+}
+
+// SpawnConfig is the serializable form of SpawnOptions.
+//
+//	argv: ["/system/bin/app_process", "-Xzygote"]
+//	inherit_env: true
+//	envp: ["LD_PRELOAD=/data/local/tmp/agent.so"]
+//	aux:
+//	  uid: 10123
+type SpawnConfig struct {
+	Argv []string `json:"argv,omitempty" yaml:"argv,omitempty"`
+	// Envp replaces the environment of the spawned process, as "KEY=value" entries.
+	Envp []string `json:"envp,omitempty" yaml:"envp,omitempty"`
+	// InheritEnv makes envp the environment of the current process with Envp
+	// applied to it, as done by MergeEnv.
+	InheritEnv bool `json:"inherit_env,omitempty" yaml:"inherit_env,omitempty"`
+	// Env is added to the environment the process is spawned with.
+	Env   []string  `json:"env,omitempty" yaml:"env,omitempty"`
+	Cwd   string    `json:"cwd,omitempty" yaml:"cwd,omitempty"`
+	Stdio StdioName `json:"stdio,omitempty" yaml:"stdio,omitempty"`
+	// Aux holds the platform specific extras, see SpawnOptions.SetAux. Whole
+	// numbers decoded from JSON are set as int64.
+	Aux map[string]any `json:"aux,omitempty" yaml:"aux,omitempty"`
+}
+
+// Validate returns the error if the config is not valid.
+func (c SpawnConfig) Validate() error {
+	if stdio := Stdio(c.Stdio); stdio != StdioInherit && stdio != StdioPipe {
+		return fmt.Errorf("invalid stdio %d", int(c.Stdio))
+	}
+	if err := validateEnv("envp", c.Envp); err != nil {
+		return err
+	}
+	if err := validateEnv("env", c.Env); err != nil {
+		return err
+	}
+	for key, value := range c.Aux {
+		variant, err := goToGVariant(configValue(value))
+		if err != nil {
+			return fmt.Errorf("aux %s: %w", key, err)
+		}
+		C.g_variant_unref(variant)
+	}
+	return nil
+}
+
+func validateEnv(name string, env []string) error {
+	for _, entry := range env {
+		if key, _, ok := strings.Cut(entry, "="); !ok || key == "" {
+			return fmt.Errorf("invalid %s entry %q, expected KEY=value", name, entry)
+		}
+	}
+	return nil
+}
+
+// Options returns the SpawnOptions described by the config.
+func (c SpawnConfig) Options() (*SpawnOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := NewSpawnOptions()
+	if c.Argv != nil {
+		opts.SetArgv(c.Argv)
+	}
+	if c.InheritEnv {
+		opts.SetEnvpList(MergeEnv(os.Environ(), c.Envp...))
+	} else if c.Envp != nil {
+		opts.SetEnvpList(c.Envp)
+	}
+	if c.Env != nil {
+		opts.SetEnvList(c.Env)
+	}
+	if c.Cwd != "" {
+		opts.SetCwd(c.Cwd)
+	}
+	opts.SetStdio(Stdio(c.Stdio))
+
+	aux := make(map[string]any, len(c.Aux))
+	for key, value := range c.Aux {
+		aux[key] = configValue(value)
+	}
+	if err := opts.SetAux(aux); err != nil {
+		opts.Clean()
+		return nil, err
+	}
+	return opts, nil
+}
+
+// Config returns the config describing the options.
+func (s *SpawnOptions) Config() SpawnConfig {
+	cfg := SpawnConfig{
+		Cwd:   s.Cwd(),
+		Stdio: StdioName(s.Stdio()),
+		Aux:   s.Aux(),
+	}
+	if argv := s.Argv(); len(argv) > 0 {
+		cfg.Argv = argv
+	}
+	if envp := s.Envp(); len(envp) > 0 {
+		cfg.Envp = envp
+	}
+	if env := s.Env(); len(env) > 0 {
+		cfg.Env = env
+	}
+	return cfg
+}
+
+// configValue converts the value decoded from JSON to the Go type it stands for:
+// whole numbers become int64 and json.Number is parsed.
+func configValue(v any) any {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<63 {
+			return int64(val)
+		}
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return n
+		}
+		if f, err := val.Float64(); err == nil {
+			return f
+		}
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = configValue(item)
+		}
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for k, item := range val {
+			out[k] = configValue(item)
+		}
+		return out
+	}
+	return v
+}
+
+// PortalConfig is the serializable form of PortalOptions.
+type PortalConfig struct {
+	// Certificate is the PEM encoded certificate followed by its private key,
+	// or the path to such a file.
+	Certificate string   `json:"certificate,omitempty" yaml:"certificate,omitempty"`
+	Token       string   `json:"token,omitempty" yaml:"token,omitempty"`
+	ACL         []string `json:"acl,omitempty" yaml:"acl,omitempty"`
+}
+
+// Validate returns the error if the config is not valid.
+func (c PortalConfig) Validate() error {
+	for _, acl := range c.ACL {
+		if acl == "" {
+			return errors.New("empty acl")
+		}
+	}
+	return validateCertificate(c.Certificate)
+}
+
+// Options returns the PortalOptions described by the config.
+func (c PortalConfig) Options() (*PortalOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := NewPortalOptions()
+	if c.Certificate != "" {
+		if err := opts.SetCertificate(c.Certificate); err != nil {
+			opts.Clean()
+			return nil, err
+		}
+	}
+	if c.Token != "" {
+		opts.SetToken(c.Token)
+	}
+	if len(c.ACL) > 0 {
+		opts.SetACL(c.ACL)
+	}
+	return opts, nil
+}
+
+// Config returns the config describing the options, with the certificate and its
+// private key PEM encoded; the config needs to be stored as a secret.
+func (p *PortalOptions) Config() PortalConfig {
+	cfg := PortalConfig{
+		Certificate: p.Certificate().configPEM(),
+		Token:       p.Token(),
+	}
+	if acl := p.ACL(); len(acl) > 0 {
+		cfg.ACL = acl
+	}
+	return cfg
+}
+
+// RelayConfig is the serializable form of Relay.
+type RelayConfig struct {
+	Address  string        `json:"address" yaml:"address"`
+	Username string        `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string        `json:"password,omitempty" yaml:"password,omitempty"`
+	Kind     RelayKindName `json:"kind,omitempty" yaml:"kind,omitempty"`
+}
+
+// PeerConfig is the serializable form of PeerOptions.
+type PeerConfig struct {
+	StunServer string        `json:"stun_server,omitempty" yaml:"stun_server,omitempty"`
+	Relays     []RelayConfig `json:"relays,omitempty" yaml:"relays,omitempty"`
+}
+
+// Validate returns the error if the config is not valid.
+func (c PeerConfig) Validate() error {
+	for i, relay := range c.Relays {
+		if relay.Address == "" {
+			return fmt.Errorf("relay %d without address", i)
+		}
+		if kind := RelayKind(relay.Kind); kind < RelayKindTurnUDP || kind > RelayKindTurnTLS {
+			return fmt.Errorf("relay %s: invalid kind %d", relay.Address, int(relay.Kind))
+		}
+	}
+	return nil
+}
+
+// Options returns the PeerOptions described by the config.
+func (c PeerConfig) Options() (*PeerOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := NewPeerOptions()
+	if c.StunServer != "" {
+		opts.SetStunServer(c.StunServer)
+	}
+	for _, r := range c.Relays {
+		relay := NewRelay(r.Address, r.Username, r.Password, RelayKind(r.Kind))
+		opts.AddRelay(relay)
+		relay.Clean()
+	}
+	return opts, nil
+}
+
+// Config returns the config describing the options.
+func (p *PeerOptions) Config() PeerConfig {
+	cfg := PeerConfig{StunServer: p.StunServer()}
+	for _, relay := range p.Relays() {
+		cfg.Relays = append(cfg.Relays, RelayConfig{
+			Address:  relay.Address(),
+			Username: relay.Username(),
+			Password: relay.Password(),
+			Kind:     RelayKindName(relay.RelayKind()),
+		})
+		relay.Clean()
+	}
+	return cfg
+}
+
+// validateCertificate checks that the certificate path, unless PEM is inlined, exists.
+func validateCertificate(cert string) error {
+	if cert == "" || strings.HasPrefix(strings.TrimSpace(cert), "-----BEGIN") {
+		return nil
+	}
+	if _, err := os.Stat(cert); err != nil {
+		return fmt.Errorf("certificate: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package telco
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	tests := []struct {
+		config any
+		text   string
+	}{
+		{&SessionConfig{Realm: RealmName(RealmEmulated), PersistTimeout: 30},
+			`{"realm":"emulated","persist_timeout":30}`},
+		{&ScriptConfig{Name: "agent", Runtime: ScriptRuntimeName(ScriptRuntimeV8), Snapshot: []byte{1, 2}, SnapshotTransport: SnapshotTransportName(SnapshotTransportSharedMemory)},
+			`{"name":"agent","runtime":"v8","snapshot":"AQI=","snapshot_transport":"shared-memory"}`},
+		{&SnapshotConfig{WarmupScript: "init();", Runtime: ScriptRuntimeName(ScriptRuntimeQJS)},
+			`{"warmup_script":"init();","runtime":"qjs"}`},
+		{&SpawnConfig{Argv: []string{"/bin/ls", "-l"}, Env: []string{"A=1"}, Cwd: "/tmp", Stdio: StdioName(StdioPipe)},
+			`{"argv":["/bin/ls","-l"],"env":["A=1"],"cwd":"/tmp","stdio":"pipe"}`},
+		{&PeerConfig{StunServer: "stun.example.com:3478", Relays: []RelayConfig{{Address: "relay.example.com:3478", Kind: RelayKindName(RelayKindTurnTLS)}}},
+			`{"stun_server":"stun.example.com:3478","relays":[{"address":"relay.example.com:3478","kind":"turn-tls"}]}`},
+		{&DeviceSelector{Types: []DeviceTypeName{DeviceTypeName(DeviceTypeUsb), DeviceTypeName(DeviceTypeRemote)}},
+			`{"types":["usb","remote"]}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.config)
+		if err != nil {
+			t.Errorf("Marshal(%+v) error %v", tt.config, err)
+			continue
+		}
+		if string(data) != tt.text {
+			t.Errorf("Marshal(%+v) = %s, want %s", tt.config, data, tt.text)
+		}
+
+		decoded := reflect.New(reflect.TypeOf(tt.config).Elem()).Interface()
+		if err := json.Unmarshal(data, decoded); err != nil {
+			t.Errorf("Unmarshal(%s) error %v", data, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, tt.config) {
+			t.Errorf("Unmarshal(%s) = %+v, want %+v", data, decoded, tt.config)
+		}
+	}
+}
+
+func TestConfigEnumNames(t *testing.T) {
+	// the enums outside the config structs keep their numeric encoding
+	if data, err := json.Marshal(RealmEmulated); err != nil || string(data) != "1" {
+		t.Errorf("Marshal(RealmEmulated) = %s, %v, want 1", data, err)
+	}
+
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{`{"realm":"native"}`, ""},
+		{`{"realm":"emulated"}`, ""},
+		{`{"realm":"virtual"}`, `unknown telco.Realm "virtual"`},
+		{`{"realm":1}`, "cannot unmarshal number"},
+	}
+	for _, tt := range tests {
+		var cfg SessionConfig
+		err := json.Unmarshal([]byte(tt.text), &cfg)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("Unmarshal(%s) error %v, want %q", tt.text, err, tt.err)
+		}
+	}
+
+	if _, err := json.Marshal(SessionConfig{Realm: 7}); err == nil || !strings.Contains(err.Error(), "invalid telco.Realm 7") {
+		t.Errorf("Marshal of invalid realm error %v", err)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config interface{ Validate() error }
+		err    string
+	}{
+		{"session", SessionConfig{Realm: RealmName(RealmEmulated)}, ""},
+		{"session realm", SessionConfig{Realm: 2}, "invalid realm 2"},
+		{"script", ScriptConfig{Runtime: ScriptRuntimeName(ScriptRuntimeV8)}, ""},
+		{"script runtime", ScriptConfig{Runtime: -1}, "invalid runtime -1"},
+		{"script snapshot transport", ScriptConfig{SnapshotTransport: 2}, "invalid snapshot transport 2"},
+		{"snapshot runtime", SnapshotConfig{Runtime: 3}, "invalid runtime 3"},
+		{"spawn", SpawnConfig{Envp: []string{"A=1", "B="}, Aux: map[string]any{"uid": float64(10123)}}, ""},
+		{"spawn stdio", SpawnConfig{Stdio: 2}, "invalid stdio 2"},
+		{"spawn envp", SpawnConfig{Envp: []string{"A"}}, `invalid envp entry "A"`},
+		{"spawn env", SpawnConfig{Env: []string{"=1"}}, `invalid env entry "=1"`},
+		{"portal", PortalConfig{Certificate: "-----BEGIN CERTIFICATE-----", ACL: []string{"admin"}}, ""},
+		{"portal acl", PortalConfig{ACL: []string{""}}, "empty acl"},
+		{"portal certificate", PortalConfig{Certificate: "/nonexistent/cert.pem"}, "certificate:"},
+		{"peer", PeerConfig{Relays: []RelayConfig{{Address: "relay:3478"}}}, ""},
+		{"peer relay address", PeerConfig{Relays: []RelayConfig{{}}}, "relay 0 without address"},
+		{"peer relay kind", PeerConfig{Relays: []RelayConfig{{Address: "relay:3478", Kind: 3}}}, "relay relay:3478: invalid kind 3"},
+	}
+
+	for _, tt := range tests {
+		err := tt.config.Validate()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: Validate() error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestConfigValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  any
+	}{
+		{float64(10123), int64(10123)},
+		{1.5, 1.5},
+		{json.Number("42"), int64(42)},
+		{json.Number("0.25"), 0.25},
+		{[]any{float64(1), "a"}, []any{int64(1), "a"}},
+		{map[string]any{"uid": float64(0)}, map[string]any{"uid": int64(0)}},
+		{"text", "text"},
+	}
+	for _, tt := range tests {
+		if got := configValue(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("configValue(%#v) = %#v, want %#v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package telco
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParamsUnmarshaler is implemented by types that know how to decode themselves
+// from the value found inside the parameters map.
+type ParamsUnmarshaler interface {
+	UnmarshalParams(value any) error
+}
+
+var (
+	paramsUnmarshalerType = reflect.TypeOf((*ParamsUnmarshaler)(nil)).Elem()
+	timeType              = reflect.TypeOf(time.Time{})
+)
+
+// DecodeParams decodes parameters returned by Device.Params, Process.Params,
+// Application.Params, Crash.Params etc into the struct pointed to by v.
+//
+// Fields are matched by the key inside the telco struct tag, or the tag set by
+// WithStructTag, or, when there is no tag, by the field name compared
+// case-insensitively. Fields tagged with telco:"-" are skipped. Nested maps
+// decode into structs or maps, strings decode into time.Time as RFC 3339 and
+// numbers are converted between numeric types.
+//
+//	type Info struct {
+//		Path    string    `telco:"path"`
+//		PPID    int       `telco:"ppid"`
+//		Started time.Time `telco:"started"`
+//	}
+func DecodeParams(params map[string]any, v any, opts ...ConvertOption) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("expected non-nil pointer to struct")
+	}
+	o := newConvertOptions(opts)
+	return o.decodeStruct(params, rv.Elem(), "")
+}
+
+func (o convertOptions) decodeStruct(params map[string]any, dst reflect.Value, path string) error {
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup(o.tag)
+		key, _, _ := strings.Cut(tag, ",")
+		if key == "-" {
+			continue
+		}
+
+		var value any
+		var found bool
+		if ok && key != "" {
+			value, found = params[key]
+		} else {
+			key = field.Name
+			for k, v := range params {
+				if strings.EqualFold(k, field.Name) {
+					value, found = v, true
+					break
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+
+		if err := o.decodeValue(value, dst.Field(i), path+key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o convertOptions) decodeValue(src any, dst reflect.Value, path string) error {
+	if src == nil {
+		return nil
+	}
+
+	if dst.CanAddr() && dst.Addr().Type().Implements(paramsUnmarshalerType) {
+		return dst.Addr().Interface().(ParamsUnmarshaler).UnmarshalParams(src)
+	}
+
+	sv := reflect.ValueOf(src)
+	if dst.Kind() != reflect.Interface && sv.Type().AssignableTo(dst.Type()) {
+		dst.Set(sv)
+		return nil
+	}
+
+	mismatch := func() error {
+		return fmt.Errorf("cannot decode %s: %T into %s", path, src, dst.Type())
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if !sv.Type().AssignableTo(dst.Type()) {
+			return mismatch()
+		}
+		dst.Set(sv)
+	case reflect.Pointer:
+		elem := reflect.New(dst.Type().Elem())
+		if err := o.decodeValue(src, elem.Elem(), path); err != nil {
+			return err
+		}
+		dst.Set(elem)
+	case reflect.String:
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		default:
+			dst.SetString(fmt.Sprint(src))
+		}
+	case reflect.Bool:
+		switch b := src.(type) {
+		case bool:
+			dst.SetBool(b)
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return mismatch()
+			}
+			dst.SetBool(parsed)
+		default:
+			return mismatch()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := intFromValue(src)
+		if !ok {
+			return mismatch()
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("cannot decode %s: %v overflows %s", path, src, dst.Type())
+		}
+		dst.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := uintFromValue(src)
+		if !ok {
+			return mismatch()
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("cannot decode %s: %v overflows %s", path, src, dst.Type())
+		}
+		dst.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, ok := toFloat(src)
+		if !ok {
+			return mismatch()
+		}
+		dst.SetFloat(n)
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			t, ok := toTime(src)
+			if !ok {
+				return mismatch()
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+		mp, ok := src.(map[string]any)
+		if !ok {
+			return mismatch()
+		}
+		return o.decodeStruct(mp, dst, path+".")
+	case reflect.Map:
+		mp, ok := src.(map[string]any)
+		if !ok || dst.Type().Key().Kind() != reflect.String {
+			return mismatch()
+		}
+		out := reflect.MakeMapWithSize(dst.Type(), len(mp))
+		for k, v := range mp {
+			elem := reflect.New(dst.Type().Elem()).Elem()
+			if err := o.decodeValue(v, elem, path+"."+k); err != nil {
+				return err
+			}
+			out.SetMapIndex(reflect.ValueOf(k).Convert(dst.Type().Key()), elem)
+		}
+		dst.Set(out)
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			if s, ok := src.(string); ok {
+				dst.SetBytes([]byte(s))
+				return nil
+			}
+		}
+
+		var items []any
+		switch sv.Kind() {
+		case reflect.Slice, reflect.Array:
+			items = make([]any, sv.Len())
+			for i := range items {
+				items[i] = sv.Index(i).Interface()
+			}
+		case reflect.Map:
+			// single element is sometimes flattened into the map itself
+			items = []any{src}
+		default:
+			return mismatch()
+		}
+
+		out := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := o.decodeValue(item, out.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(out)
+	default:
+		return mismatch()
+	}
+
+	return nil
+}
+
+// intFromValue converts the integer, the whole float or the decimal string into
+// int64. Integers are converted directly so values above 2^53 keep their precision.
+func intFromValue(src any) (int64, bool) {
+	sv := reflect.ValueOf(src)
+	switch sv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if sv.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(sv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := sv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, false
+		}
+		return int64(f), true
+	case reflect.String:
+		n, err := strconv.ParseInt(sv.String(), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// uintFromValue is intFromValue for the unsigned integers; negative values fail.
+func uintFromValue(src any) (uint64, bool) {
+	sv := reflect.ValueOf(src)
+	switch sv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if sv.Int() < 0 {
+			return 0, false
+		}
+		return uint64(sv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return sv.Uint(), true
+	case reflect.Float32, reflect.Float64:
+		f := sv.Float()
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 {
+			return 0, false
+		}
+		return uint64(f), true
+	case reflect.String:
+		n, err := strconv.ParseUint(sv.String(), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
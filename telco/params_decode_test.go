@@ -0,0 +1,113 @@
+package telco
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeParams(t *testing.T) {
+	type file struct {
+		Path  string `telco:"path"`
+		Inode uint64 `telco:"inode"`
+	}
+	type info struct {
+		Base    uint64            `telco:"base"`
+		Offset  int64             `telco:"offset"`
+		PPID    int               `telco:"ppid"`
+		Size    int32             `telco:"size"`
+		Ratio   float64           `telco:"ratio"`
+		Name    string            `telco:"name"`
+		Debug   bool              `telco:"debug"`
+		Started time.Time         `telco:"started"`
+		File    *file             `telco:"file"`
+		Env     map[string]string `telco:"env"`
+		Args    []string          `telco:"args"`
+		Skipped string            `telco:"-"`
+		Folded  string
+	}
+
+	params := map[string]any{
+		"base":    uint64(0xffffff8000001234),
+		"offset":  int64(-(1 << 60) - 1),
+		"ppid":    float64(1),
+		"size":    "4096",
+		"ratio":   int64(2),
+		"name":    "nginx",
+		"debug":   "true",
+		"started": "2024-03-01T10:00:00Z",
+		"file":    map[string]any{"path": "/bin/sh", "inode": uint64(math.MaxUint64)},
+		"env":     map[string]any{"HOME": "/root"},
+		"args":    []any{"-c", "id"},
+		"-":       "skipped",
+		"FOLDED":  "yes",
+	}
+
+	var got info
+	if err := DecodeParams(params, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := info{
+		Base:    0xffffff8000001234,
+		Offset:  -(1 << 60) - 1,
+		PPID:    1,
+		Size:    4096,
+		Ratio:   2,
+		Name:    "nginx",
+		Debug:   true,
+		Started: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		File:    &file{Path: "/bin/sh", Inode: math.MaxUint64},
+		Env:     map[string]string{"HOME": "/root"},
+		Args:    []string{"-c", "id"},
+		Folded:  "yes",
+	}
+	switch {
+	case got.Base != want.Base, got.Offset != want.Offset, got.PPID != want.PPID,
+		got.Size != want.Size, got.Ratio != want.Ratio, got.Name != want.Name,
+		got.Debug != want.Debug, !got.Started.Equal(want.Started),
+		got.File == nil || *got.File != *want.File, got.Env["HOME"] != "/root",
+		strings.Join(got.Args, " ") != "-c id", got.Skipped != "", got.Folded != want.Folded:
+		t.Errorf("DecodeParams = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeParamsIntegers(t *testing.T) {
+	tests := []struct {
+		src  any
+		dst  any
+		want string
+	}{
+		{int64(128), new(int8), "overflows int8"},
+		{int64(-1), new(uint32), "cannot decode v"},
+		{uint64(math.MaxUint64), new(int64), "cannot decode v"},
+		{int64(1 << 40), new(int32), "overflows int32"},
+		{uint64(256), new(uint8), "overflows uint8"},
+		{1.5, new(int), "cannot decode v"},
+		{"12x", new(int), "cannot decode v"},
+		{true, new(int), "cannot decode v"},
+	}
+
+	for _, tt := range tests {
+		err := decodeInto(tt.src, tt.dst)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("decoding %T(%v) into %T: error %v, want %q", tt.src, tt.src, tt.dst, err, tt.want)
+		}
+	}
+
+	var n uint64
+	if err := decodeInto(uint64(1<<53+1), &n); err != nil || n != 1<<53+1 {
+		t.Errorf("uint64 above 2^53 decoded as %d, %v", n, err)
+	}
+	var i int64
+	if err := decodeInto(int64(-(1<<53)-1), &i); err != nil || i != -(1<<53)-1 {
+		t.Errorf("int64 below -2^53 decoded as %d, %v", i, err)
+	}
+}
+
+// decodeInto decodes src as the key "v" into the value pointed to by dst.
+func decodeInto(src, dst any) error {
+	return newConvertOptions(nil).decodeValue(src, reflect.ValueOf(dst).Elem(), "v")
+}
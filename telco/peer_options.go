@@ -1,6 +1,18 @@
 package telco
 
-//#include <telco-core.h>
+/*
+#include <telco-core.h>
+
+static void collect_relay(gpointer relay, gpointer relays) {
+	g_ptr_array_add((GPtrArray *) relays, g_object_ref(relay));
+}
+
+static GPtrArray * get_relays(TelcoPeerOptions * opts) {
+	GPtrArray * relays = g_ptr_array_new();
+	telco_peer_options_enumerate_relays(opts, collect_relay, relays);
+	return relays;
+}
+*/
 import "C"
 import (
 	"unsafe"
@@ -32,6 +44,19 @@ func (p *PeerOptions) AddRelay(relay *Relay) {
 	C.telco_peer_options_add_relay(p.opts, relay.r)
 }
 
+// Relays returns the relays added to the peer options.
+func (p *PeerOptions) Relays() []*Relay {
+	arr := C.get_relays(p.opts)
+	defer C.g_ptr_array_free(arr, C.gboolean(1))
+
+	pdata := unsafe.Slice(arr.pdata, int(arr.len))
+	relays := make([]*Relay, len(pdata))
+	for i, relay := range pdata {
+		relays[i] = &Relay{(*C.TelcoRelay)(relay)}
+	}
+	return relays
+}
+
 // SetStunServer sets the stun server for peer options.
 func (p *PeerOptions) SetStunServer(stunServer string) {
 	stunC := C.CString(stunServer)
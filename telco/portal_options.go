@@ -35,14 +35,15 @@ func (p *PortalOptions) ACL() []string {
 	return cArrayToStringSlice(arr, C.int(sz))
 }
 
-// SetCertificate sets the certificate for the portal.
-func (p *PortalOptions) SetCertificate(certPath string) error {
-	cert, err := gTLSCertificateFromFile(certPath)
+// SetCertificate sets the certificate for the portal, cert being the path to the
+// PEM encoded certificate or the PEM itself.
+func (p *PortalOptions) SetCertificate(cert string) error {
+	crt, err := certificateFromConfig(cert)
 	if err != nil {
 		return err
 	}
 
-	C.telco_portal_options_set_certificate(p.opts, cert.cert)
+	C.telco_portal_options_set_certificate(p.opts, crt.cert)
 	return nil
 }
 
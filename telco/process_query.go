@@ -0,0 +1,742 @@
+package telco
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Op represents the comparison operator used inside the Filter.
+type Op string
+
+const (
+	OpEq       Op = "=="
+	OpNe       Op = "!="
+	OpMatch    Op = "~"
+	OpNotMatch Op = "!~"
+	OpLt       Op = "<"
+	OpLe       Op = "<="
+	OpGt       Op = ">"
+	OpGe       Op = ">="
+)
+
+// Filter decides whether the process is returned by QueryProcesses.
+// Filters are created with Where, And, Or, Not, FilterFunc or ParseFilter.
+type Filter interface {
+	// String returns the filter in the compact form understood by ParseFilter.
+	String() string
+	match(p *queriedProcess) bool
+	validate() error
+}
+
+// Query describes which processes QueryProcesses returns and in which order.
+//
+// Keys used by the Filter and SortBy are "name" and "pid" which are taken from the
+// process itself, while any other key ("ppid", "user", "path", "started" etc) is
+// looked up inside Process.Params() obtained with ScopeFull.
+type Query struct {
+	Filter     Filter
+	SortBy     string
+	Descending bool
+	Limit      int
+}
+
+// String returns the query in the compact form understood by ParseQuery.
+func (q Query) String() string {
+	var parts []string
+	if q.Filter != nil {
+		parts = append(parts, q.Filter.String())
+	}
+	if q.SortBy != "" {
+		order := "asc"
+		if q.Descending {
+			order = "desc"
+		}
+		parts = append(parts, fmt.Sprintf("order by %s %s", q.SortBy, order))
+	}
+	if q.Limit > 0 {
+		parts = append(parts, fmt.Sprintf("limit %d", q.Limit))
+	}
+	return strings.Join(parts, " ")
+}
+
+// QueryProcesses returns the processes on the device matching the query.
+func (d *Device) QueryProcesses(ctx context.Context, q Query) ([]*Process, error) {
+	if d.device == nil {
+		return nil, errors.New("could not query processes for nil device")
+	}
+
+	if q.Filter != nil {
+		if err := q.Filter.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	procs, err := d.enumerateProcessesContext(ctx, ScopeFull)
+	if err != nil {
+		return nil, err
+	}
+
+	queried := make([]*queriedProcess, 0, len(procs))
+	for _, proc := range procs {
+		qp := &queriedProcess{proc: proc, params: proc.Params()}
+		if q.Filter == nil || q.Filter.match(qp) {
+			queried = append(queried, qp)
+		}
+	}
+
+	if q.SortBy != "" {
+		sort.SliceStable(queried, func(i, j int) bool {
+			a, aok := queried[i].value(q.SortBy)
+			b, bok := queried[j].value(q.SortBy)
+			// processes without the key always go last
+			if !aok || !bok {
+				return aok && !bok
+			}
+			if q.Descending {
+				return compareValues(a, b) > 0
+			}
+			return compareValues(a, b) < 0
+		})
+	}
+
+	if q.Limit > 0 && len(queried) > q.Limit {
+		queried = queried[:q.Limit]
+	}
+
+	ret := make([]*Process, len(queried))
+	for i, qp := range queried {
+		ret[i] = qp.proc
+	}
+	return ret, nil
+}
+
+type queriedProcess struct {
+	proc   *Process
+	params map[string]any
+}
+
+func (p *queriedProcess) value(key string) (any, bool) {
+	switch key {
+	case "name":
+		return p.proc.Name(), true
+	case "pid":
+		return int64(p.proc.PID()), true
+	}
+	v, ok := p.params[key]
+	return v, ok
+}
+
+type condFilter struct {
+	key   string
+	op    Op
+	value any
+	re    *regexp.Regexp
+	err   error
+}
+
+// Where returns the filter comparing the value under key with the value provided.
+// For OpMatch and OpNotMatch, value needs to be regular expression as string
+// or *regexp.Regexp.
+func Where(key string, op Op, value any) Filter {
+	f := &condFilter{key: key, op: op, value: value}
+	switch op {
+	case OpEq, OpNe, OpLt, OpLe, OpGt, OpGe:
+	case OpMatch, OpNotMatch:
+		switch v := value.(type) {
+		case *regexp.Regexp:
+			f.re = v
+		case string:
+			f.re, f.err = regexp.Compile(v)
+		default:
+			f.err = fmt.Errorf("expected regular expression for %s %s", key, op)
+		}
+	default:
+		f.err = fmt.Errorf("unknown operator %q", op)
+	}
+	return f
+}
+
+func (f *condFilter) String() string {
+	value := f.value
+	if f.re != nil {
+		value = f.re.String()
+	}
+
+	var v string
+	switch vv := value.(type) {
+	case string:
+		v = strconv.Quote(vv)
+	case time.Time:
+		v = strconv.Quote(vv.Format(time.RFC3339))
+	default:
+		v = fmt.Sprint(vv)
+	}
+	return fmt.Sprintf("%s%s%s", f.key, f.op, v)
+}
+
+func (f *condFilter) validate() error {
+	return f.err
+}
+
+func (f *condFilter) match(p *queriedProcess) bool {
+	v, ok := p.value(f.key)
+	if !ok {
+		return f.op == OpNe || f.op == OpNotMatch
+	}
+
+	switch f.op {
+	case OpMatch:
+		return f.re.MatchString(fmt.Sprint(v))
+	case OpNotMatch:
+		return !f.re.MatchString(fmt.Sprint(v))
+	case OpEq:
+		return compareValues(v, f.value) == 0
+	case OpNe:
+		return compareValues(v, f.value) != 0
+	case OpLt:
+		return compareValues(v, f.value) < 0
+	case OpLe:
+		return compareValues(v, f.value) <= 0
+	case OpGt:
+		return compareValues(v, f.value) > 0
+	case OpGe:
+		return compareValues(v, f.value) >= 0
+	}
+	return false
+}
+
+type andFilter []Filter
+
+// And returns the filter matching processes matched by all filters.
+func And(filters ...Filter) Filter {
+	return andFilter(filters)
+}
+
+func (f andFilter) String() string {
+	return joinFilters(f, " and ")
+}
+
+func (f andFilter) validate() error {
+	return validateFilters(f)
+}
+
+func (f andFilter) match(p *queriedProcess) bool {
+	for _, filter := range f {
+		if !filter.match(p) {
+			return false
+		}
+	}
+	return true
+}
+
+type orFilter []Filter
+
+// Or returns the filter matching processes matched by any of the filters.
+func Or(filters ...Filter) Filter {
+	return orFilter(filters)
+}
+
+func (f orFilter) String() string {
+	return joinFilters(f, " or ")
+}
+
+func (f orFilter) validate() error {
+	return validateFilters(f)
+}
+
+func (f orFilter) match(p *queriedProcess) bool {
+	for _, filter := range f {
+		if filter.match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+type notFilter struct {
+	filter Filter
+}
+
+// Not returns the filter matching processes not matched by filter.
+func Not(filter Filter) Filter {
+	return notFilter{filter}
+}
+
+func (f notFilter) String() string {
+	return "not (" + f.filter.String() + ")"
+}
+
+func (f notFilter) validate() error {
+	return f.filter.validate()
+}
+
+func (f notFilter) match(p *queriedProcess) bool {
+	return !f.filter.match(p)
+}
+
+type funcFilter func(proc *Process, params map[string]any) bool
+
+// FilterFunc returns the filter matching processes for which fn returns true.
+// Params are the parameters of the process obtained with ScopeFull.
+func FilterFunc(fn func(proc *Process, params map[string]any) bool) Filter {
+	return funcFilter(fn)
+}
+
+func (f funcFilter) String() string {
+	return "<func>"
+}
+
+func (f funcFilter) validate() error {
+	return nil
+}
+
+func (f funcFilter) match(p *queriedProcess) bool {
+	return f(p.proc, p.params)
+}
+
+func joinFilters(filters []Filter, sep string) string {
+	s := make([]string, len(filters))
+	for i, filter := range filters {
+		switch filter.(type) {
+		case andFilter, orFilter:
+			s[i] = "(" + filter.String() + ")"
+		default:
+			s[i] = filter.String()
+		}
+	}
+	return strings.Join(s, sep)
+}
+
+func validateFilters(filters []Filter) error {
+	for _, filter := range filters {
+		if err := filter.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compareValues compares a and b as numbers, times or strings, in that order,
+// depending on what both of them can be converted to. Integers are compared as
+// integers, so values above 2^53 keep their precision.
+func compareValues(a, b any) int {
+	if c, ok := compareIntegers(a, b); ok {
+		return c
+	}
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	if x, ok := toTime(a); ok {
+		if y, ok := toTime(b); ok {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	}
+
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// compareIntegers compares a and b if both of them are integers.
+func compareIntegers(a, b any) (int, bool) {
+	x, xneg, ok := integerValue(a)
+	if !ok {
+		return 0, false
+	}
+	y, yneg, ok := integerValue(b)
+	if !ok {
+		return 0, false
+	}
+
+	switch {
+	case xneg && !yneg:
+		return -1, true
+	case !xneg && yneg:
+		return 1, true
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	}
+	return 0, true
+}
+
+// integerValue returns the integer as uint64 and whether it is negative. The
+// negative integers keep their order as uint64 in two's complement.
+func integerValue(v any) (uint64, bool, bool) {
+	sv := reflect.ValueOf(v)
+	switch sv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := sv.Int()
+		return uint64(n), n < 0, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return sv.Uint(), false, true
+	}
+	return 0, false, false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int8:
+		return float64(n), true
+	case int16:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint8:
+		return float64(n), true
+	case uint16:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return parsed, true
+	}
+	return time.Time{}, false
+}
+
+// ParseFilter parses the filter from the compact form, for example:
+//
+//	name~"^nginx" and user=="www"
+//	(ppid==1 or path~"/usr/sbin/") and not name=="sshd"
+//	started>"2024-01-01T00:00:00Z"
+//
+// Operators available are ==, !=, ~ (regex match), !~, <, <=, > and >=; conditions
+// are combined with and, or, not and parentheses.
+// Values can be quoted strings, numbers, true/false or bare words.
+func ParseFilter(s string) (Filter, error) {
+	q, err := ParseQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if q.SortBy != "" || q.Limit != 0 {
+		return nil, errors.New("order by and limit are not allowed inside the filter")
+	}
+	return q.Filter, nil
+}
+
+// ParseQuery parses the query from the compact form. The filter, as understood by
+// ParseFilter, can be followed by "order by KEY [asc|desc]" and "limit N", for example:
+//
+//	user=="www" order by started desc limit 5
+func ParseQuery(s string) (Query, error) {
+	tokens, err := lexQuery(s)
+	if err != nil {
+		return Query{}, err
+	}
+
+	p := &queryParser{tokens: tokens}
+
+	var q Query
+	if !p.peekKeyword("order") && !p.peekKeyword("limit") && !p.done() {
+		q.Filter, err = p.parseOr()
+		if err != nil {
+			return Query{}, err
+		}
+	}
+
+	if p.peekKeyword("order") {
+		p.next()
+		if !p.peekKeyword("by") {
+			return Query{}, p.errorf(p.peek(), "expected by after order")
+		}
+		p.next()
+		tok := p.next()
+		if tok.kind != tokenIdent {
+			return Query{}, p.errorf(tok, "expected key to order by")
+		}
+		q.SortBy = tok.text
+		switch {
+		case p.peekKeyword("asc"):
+			p.next()
+		case p.peekKeyword("desc"):
+			p.next()
+			q.Descending = true
+		}
+	}
+
+	if p.peekKeyword("limit") {
+		p.next()
+		tok := p.next()
+		limit, err := strconv.Atoi(tok.text)
+		if tok.kind != tokenNumber || err != nil || limit < 1 {
+			return Query{}, p.errorf(tok, "expected limit as positive integer")
+		}
+		q.Limit = limit
+	}
+
+	if !p.done() {
+		return Query{}, p.errorf(p.peek(), "unexpected %q", p.peek().text)
+	}
+
+	if q.Filter != nil {
+		if err := q.Filter.validate(); err != nil {
+			return Query{}, err
+		}
+	}
+
+	return q, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOp
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexQuery(s string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			str, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %w", i, err)
+			}
+			tokens = append(tokens, token{tokenString, str, i})
+			i = j + 1
+		case strings.ContainsRune("=!~<>", rune(c)):
+			op := string(c)
+			if i+1 < len(s) && (s[i+1] == '=' || (c == '!' && s[i+1] == '~')) {
+				op = s[i : i+2]
+			}
+			switch Op(op) {
+			case OpEq, OpNe, OpMatch, OpNotMatch, OpLt, OpLe, OpGt, OpGe:
+			default:
+				return nil, fmt.Errorf("unknown operator %q at %d", op, i)
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, s[i:j], i})
+			i = j
+		case c == '_' || c == '/' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			j := i + 1
+			for j < len(s) && !strings.ContainsRune(" \t\n\r()\"=!~<>", rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, s[i:j], i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(s)}), nil
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) done() bool {
+	return p.peek().kind == tokenEOF
+}
+
+func (p *queryParser) peekKeyword(keyword string) bool {
+	tok := p.peek()
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+// errorf returns the error at the position of tok.
+func (p *queryParser) errorf(tok token, format string, args ...any) error {
+	return fmt.Errorf("invalid query at %d: %s", tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *queryParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{left}
+	for p.peekKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, right)
+	}
+	if len(filters) == 1 {
+		return left, nil
+	}
+	return Or(filters...), nil
+}
+
+func (p *queryParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	filters := []Filter{left}
+	for p.peekKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, right)
+	}
+	if len(filters) == 1 {
+		return left, nil
+	}
+	return And(filters...), nil
+}
+
+func (p *queryParser) parseUnary() (Filter, error) {
+	if p.peekKeyword("not") {
+		p.next()
+		f, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(f), nil
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokenRParen {
+			return nil, p.errorf(p.peek(), "expected )")
+		}
+		p.next()
+		return f, nil
+	}
+
+	return p.parseCond()
+}
+
+func (p *queryParser) parseCond() (Filter, error) {
+	key := p.next()
+	if key.kind != tokenIdent {
+		return nil, p.errorf(key, "expected key, got %q", key.text)
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		return nil, p.errorf(op, "expected operator after %s", key.text)
+	}
+
+	val := p.next()
+	var value any
+	switch val.kind {
+	case tokenString:
+		value = val.text
+	case tokenNumber:
+		if n, err := strconv.ParseInt(val.text, 10, 64); err == nil {
+			value = n
+		} else if f, err := strconv.ParseFloat(val.text, 64); err == nil {
+			value = f
+		} else {
+			return nil, p.errorf(val, "invalid number %q", val.text)
+		}
+	case tokenIdent:
+		switch val.text {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		default:
+			value = val.text
+		}
+	default:
+		return nil, p.errorf(val, "expected value after %s%s", key.text, op.text)
+	}
+
+	return Where(key.text, Op(op.text), value), nil
+}
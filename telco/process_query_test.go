@@ -0,0 +1,150 @@
+package telco
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`name=="nginx"`, `name=="nginx"`},
+		{`name == nginx`, `name=="nginx"`},
+		{`pid>=100 and pid<200`, `pid>=100 and pid<200`},
+		{`name~"^ng" or name!~"x$"`, `name~"^ng" or name!~"x$"`},
+		{`(ppid==1 or path~"/usr/sbin/") and not name=="sshd"`, `(ppid==1 or path~"/usr/sbin/") and not (name=="sshd")`},
+		{`a==1 or b==2 and c==3`, `a==1 or (b==2 and c==3)`},
+		{`NOT debuggable==true`, `not (debuggable==true)`},
+		{`load>0.5`, `load>0.5`},
+		{`started>"2024-01-01T00:00:00Z"`, `started>"2024-01-01T00:00:00Z"`},
+		{`user=="www" order by started desc limit 5`, `user=="www" order by started desc limit 5`},
+		{`order by pid`, `order by pid asc`},
+		{`limit 1`, `limit 1`},
+		{``, ``},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if got := q.String(); got != tt.want {
+			t.Errorf("ParseQuery(%q) = %s, want %s", tt.query, got, tt.want)
+		}
+
+		// the compact form parses back into the same query
+		again, err := ParseQuery(q.String())
+		if err != nil {
+			t.Errorf("ParseQuery(%q): %v", q.String(), err)
+			continue
+		}
+		if again.String() != q.String() {
+			t.Errorf("round trip of %q = %s", q.String(), again.String())
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`name=="nginx`, "unterminated string at 6"},
+		{`name=>1`, `unknown operator "=" at 4`},
+		{`pid==1 #`, `unexpected '#' at 7`},
+		{`==1`, "invalid query at 0: expected key"},
+		{`name "x"`, "invalid query at 5: expected operator after name"},
+		{`name==`, "invalid query at 6: expected value after name=="},
+		{`name==(`, "invalid query at 6: expected value after name=="},
+		{`pid==1.2.3`, `invalid query at 5: invalid number "1.2.3"`},
+		{`(pid==1`, "invalid query at 7: expected )"},
+		{`pid==1 pid==2`, `invalid query at 7: unexpected "pid"`},
+		{`pid==1 order pid`, "invalid query at 13: expected by after order"},
+		{`order by ==`, "invalid query at 9: expected key to order by"},
+		{`limit 0`, "invalid query at 6: expected limit as positive integer"},
+		{`limit -1`, "invalid query at 6: expected limit as positive integer"},
+		{`limit x`, "invalid query at 6: expected limit as positive integer"},
+		{`pid==1 limit 2 desc`, `invalid query at 15: unexpected "desc"`},
+		{`name~"("`, "missing closing )"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		if err == nil {
+			t.Errorf("ParseQuery(%q) succeeded, want error %q", tt.query, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseQuery(%q) error = %q, want %q", tt.query, err, tt.want)
+		}
+	}
+}
+
+func TestParseFilterRejectsOrderAndLimit(t *testing.T) {
+	for _, s := range []string{`pid==1 order by pid`, `pid==1 limit 3`} {
+		if _, err := ParseFilter(s); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded", s)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	params := map[string]any{
+		"ppid":    int64(1),
+		"user":    "www",
+		"path":    "/usr/sbin/nginx",
+		"started": "2024-03-01T10:00:00Z",
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`user=="www"`, true},
+		{`user!="www"`, false},
+		{`ppid==1 and path~"/usr/sbin/"`, true},
+		{`ppid>1 or user=="root"`, false},
+		{`not ppid>1`, true},
+		{`started>"2024-01-01T00:00:00Z"`, true},
+		{`started<"2024-01-01T00:00:00Z"`, false},
+		{`missing=="x"`, false},
+	}
+
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("ParseFilter(%q): %v", tt.filter, err)
+		}
+		if got := f.match(&queriedProcess{params: params}); got != tt.want {
+			t.Errorf("%s matched %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b any
+		want int
+	}{
+		{int64(1), int64(2), -1},
+		{uint64(1<<53 + 1), int64(1 << 53), 1},
+		{int64(1<<62 + 1), uint64(1<<62 + 1), 0},
+		{uint64(1<<64 - 1), uint64(1<<64 - 2), 1},
+		{int64(-1), uint64(1<<64 - 1), -1},
+		{uint64(1<<64 - 1), int32(-1), 1},
+		{int64(-3), int8(-2), -1},
+		{int64(2), 2.5, -1},
+		{1.5, 1.5, 0},
+		{"2024-03-01T10:00:00Z", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 1},
+		{"b", "a", 1},
+	}
+	for _, tt := range tests {
+		if got := compareValues(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareValues(%v, %v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
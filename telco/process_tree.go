@@ -0,0 +1,259 @@
+package telco
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ProcessNode represents the process inside the ProcessTree.
+type ProcessNode struct {
+	Process  *Process
+	PID      int
+	PPID     int
+	Name     string
+	Params   map[string]any
+	Parent   *ProcessNode
+	Children []*ProcessNode
+}
+
+// ProcessTree represents the process hierarchy of the device built from the
+// "ppid" parameter of the processes.
+// Processes whose parent is not running are the roots of the tree.
+type ProcessTree struct {
+	Roots []*ProcessNode
+	nodes map[int]*ProcessNode
+}
+
+// ProcessTree returns the process hierarchy of the device.
+func (d *Device) ProcessTree(ctx context.Context) (*ProcessTree, error) {
+	if d.device == nil {
+		return nil, errors.New("could not build process tree for nil device")
+	}
+
+	procs, err := d.enumerateProcessesContext(ctx, ScopeMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewProcessTree(procs), nil
+}
+
+// NewProcessTree builds the tree from the processes provided. The processes need
+// to be enumerated with ScopeMetadata or ScopeFull so they carry the "ppid" parameter.
+func NewProcessTree(procs []*Process) *ProcessTree {
+	t := &ProcessTree{
+		nodes: make(map[int]*ProcessNode, len(procs)),
+	}
+
+	for _, proc := range procs {
+		params := proc.Params()
+		ppid := -1
+		if v, ok := toFloat(params["ppid"]); ok {
+			ppid = int(v)
+		}
+		node := &ProcessNode{
+			Process: proc,
+			PID:     proc.PID(),
+			PPID:    ppid,
+			Name:    proc.Name(),
+			Params:  params,
+		}
+		t.nodes[node.PID] = node
+	}
+
+	for _, node := range t.nodes {
+		parent, ok := t.nodes[node.PPID]
+		if !ok || parent == node || parent.hasAncestor(node) {
+			t.Roots = append(t.Roots, node)
+			continue
+		}
+		node.Parent = parent
+		parent.Children = append(parent.Children, node)
+	}
+
+	sortNodes(t.Roots)
+	for _, node := range t.nodes {
+		sortNodes(node.Children)
+	}
+
+	return t
+}
+
+func sortNodes(nodes []*ProcessNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].PID < nodes[j].PID
+	})
+}
+
+// Node returns the node for the pid provided or nil if there is no such process.
+func (t *ProcessTree) Node(pid int) *ProcessNode {
+	return t.nodes[pid]
+}
+
+// Len returns the number of processes inside the tree.
+func (t *ProcessTree) Len() int {
+	return len(t.nodes)
+}
+
+// Subtree returns the tree rooted at the process with the pid provided or nil
+// if there is no such process.
+func (t *ProcessTree) Subtree(pid int) *ProcessTree {
+	root := t.nodes[pid]
+	if root == nil {
+		return nil
+	}
+
+	sub := &ProcessTree{
+		Roots: []*ProcessNode{root},
+		nodes: map[int]*ProcessNode{root.PID: root},
+	}
+	for _, node := range root.Descendants() {
+		sub.nodes[node.PID] = node
+	}
+	return sub
+}
+
+// Descendants returns all descendants of the process with the pid provided.
+func (t *ProcessTree) Descendants(pid int) []*ProcessNode {
+	node := t.nodes[pid]
+	if node == nil {
+		return nil
+	}
+	return node.Descendants()
+}
+
+// Walk calls fn for every node of the tree in depth-first order. If fn returns
+// false, children of the node are skipped.
+func (t *ProcessTree) Walk(fn func(node *ProcessNode, depth int) bool) {
+	for _, root := range t.Roots {
+		root.Walk(fn)
+	}
+}
+
+// Walk calls fn for the node and all of its descendants in depth-first order.
+// If fn returns false, children of the node are skipped.
+func (n *ProcessNode) Walk(fn func(node *ProcessNode, depth int) bool) {
+	n.walk(fn, 0)
+}
+
+func (n *ProcessNode) walk(fn func(node *ProcessNode, depth int) bool, depth int) {
+	if !fn(n, depth) {
+		return
+	}
+	for _, child := range n.Children {
+		child.walk(fn, depth+1)
+	}
+}
+
+// Descendants returns all children of the node, their children and so on.
+func (n *ProcessNode) Descendants() []*ProcessNode {
+	var nodes []*ProcessNode
+	for _, child := range n.Children {
+		child.Walk(func(node *ProcessNode, depth int) bool {
+			nodes = append(nodes, node)
+			return true
+		})
+	}
+	return nodes
+}
+
+// Ancestors returns the parent of the node, its parent and so on up to the root.
+func (n *ProcessNode) Ancestors() []*ProcessNode {
+	var nodes []*ProcessNode
+	for p := n.Parent; p != nil; p = p.Parent {
+		nodes = append(nodes, p)
+	}
+	return nodes
+}
+
+func (n *ProcessNode) hasAncestor(node *ProcessNode) bool {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p == node {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the node in format PID NAME.
+func (n *ProcessNode) String() string {
+	return fmt.Sprintf("%d %s", n.PID, n.Name)
+}
+
+// MarshalJSON returns the node and its children as JSON.
+func (n *ProcessNode) MarshalJSON() ([]byte, error) {
+	params := make(map[string]any, len(n.Params))
+	for k, v := range n.Params {
+		if k != "icons" {
+			params[k] = v
+		}
+	}
+
+	children := n.Children
+	if children == nil {
+		children = []*ProcessNode{}
+	}
+
+	return json.Marshal(struct {
+		PID      int            `json:"pid"`
+		PPID     int            `json:"ppid"`
+		Name     string         `json:"name"`
+		Params   map[string]any `json:"params,omitempty"`
+		Children []*ProcessNode `json:"children"`
+	}{n.PID, n.PPID, n.Name, params, children})
+}
+
+// MarshalJSON returns the roots of the tree as JSON array.
+func (t *ProcessTree) MarshalJSON() ([]byte, error) {
+	roots := t.Roots
+	if roots == nil {
+		roots = []*ProcessNode{}
+	}
+	return json.Marshal(roots)
+}
+
+// Render writes the tree to w as ASCII art, for example:
+//
+//	1 launchd
+//	├── 20 nginx
+//	│   └── 21 nginx
+//	└── 22 sshd
+func (t *ProcessTree) Render(w io.Writer) error {
+	for _, root := range t.Roots {
+		if _, err := fmt.Fprintln(w, root); err != nil {
+			return err
+		}
+		if err := renderChildren(w, root, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderChildren(w io.Writer, node *ProcessNode, prefix string) error {
+	for i, child := range node.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(node.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+		if _, err := fmt.Fprintf(w, "%s%s%s\n", prefix, branch, child); err != nil {
+			return err
+		}
+		if err := renderChildren(w, child, prefix+indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the tree rendered as ASCII art.
+func (t *ProcessTree) String() string {
+	var sb strings.Builder
+	t.Render(&sb)
+	return sb.String()
+}
@@ -0,0 +1,187 @@
+package telco
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// ProcessEventType represents the kind of change reported by WatchProcesses.
+type ProcessEventType int
+
+const (
+	ProcessStarted ProcessEventType = iota
+	ProcessExited
+	ProcessChanged
+)
+
+func (t ProcessEventType) String() string {
+	return [...]string{"started",
+		"exited",
+		"changed"}[t]
+}
+
+// ProcessEvent is sent by WatchProcesses for every process that started,
+// exited or changed between two snapshots.
+type ProcessEvent struct {
+	Type ProcessEventType
+	// Process is the new process for ProcessStarted and ProcessChanged and
+	// the last seen process for ProcessExited.
+	Process *Process
+	// Previous is the last seen process for ProcessChanged.
+	Previous *Process
+}
+
+// WatchProcesses polls the processes on the device every interval and reports the
+// differences between the snapshots on the returned channel.
+// The first snapshot is taken before WatchProcesses returns and is not reported.
+//
+// When spawn gating is enabled, "spawn_added" triggers the poll immediately so new
+// processes are reported without waiting for the interval.
+//
+// If the pid of the exited process is reused before the next poll, ProcessExited
+// for the old process is reported before ProcessStarted for the new one. With
+// ScopeMinimal the process name is the only thing telling them apart; use
+// ScopeMetadata or ScopeFull to compare by start time as well.
+//
+// The channel is closed once ctx is done or the device is lost.
+func (d *Device) WatchProcesses(ctx context.Context, interval time.Duration, scope Scope) (<-chan ProcessEvent, error) {
+	if d.device == nil {
+		return nil, errors.New("could not watch processes for nil device")
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval needs to be positive")
+	}
+
+	prev, err := d.processSnapshot(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	trigger := make(chan struct{}, 1)
+	handler := connectSignal(unsafe.Pointer(d.device), "spawn_added", func() {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	})
+
+	events := make(chan ProcessEvent)
+	go func() {
+		defer close(events)
+		defer handler.disconnect()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-trigger:
+			}
+
+			if d.IsLost() {
+				return
+			}
+
+			next, err := d.processSnapshot(scope)
+			if err != nil {
+				// keep the previous snapshot and try again on the next tick
+				continue
+			}
+
+			for _, ev := range diffProcessSnapshots(prev, next) {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+			prev = next
+		}
+	}()
+
+	return events, nil
+}
+
+type processEntry struct {
+	proc    *Process
+	name    string
+	started any
+	params  map[string]any
+}
+
+func (d *Device) processSnapshot(scope Scope) (map[int]*processEntry, error) {
+	procs, err := d.EnumerateProcesses(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[int]*processEntry, len(procs))
+	for _, proc := range procs {
+		params := proc.Params()
+		// icons are not compared, they only slow down the diff
+		delete(params, "icons")
+		snapshot[proc.PID()] = &processEntry{
+			proc:    proc,
+			name:    proc.Name(),
+			started: params["started"],
+			params:  params,
+		}
+	}
+	return snapshot, nil
+}
+
+// sameProcess reports whether both entries describe the same process instance,
+// as opposed to two processes that happen to have the same pid.
+func (e *processEntry) sameProcess(other *processEntry) bool {
+	if e.started != nil && other.started != nil {
+		return reflect.DeepEqual(e.started, other.started)
+	}
+	return e.name == other.name
+}
+
+// diffProcessSnapshots returns the events turning prev into next: the exited
+// processes first, then the started and changed ones, each ordered by pid.
+func diffProcessSnapshots(prev, next map[int]*processEntry) []ProcessEvent {
+	var events []ProcessEvent
+
+	for _, pid := range sortedPIDs(prev) {
+		old := prev[pid]
+		cur, ok := next[pid]
+		if !ok || !old.sameProcess(cur) {
+			events = append(events, ProcessEvent{Type: ProcessExited, Process: old.proc})
+		}
+	}
+
+	for _, pid := range sortedPIDs(next) {
+		cur := next[pid]
+		old, ok := prev[pid]
+		switch {
+		case !ok || !old.sameProcess(cur):
+			events = append(events, ProcessEvent{Type: ProcessStarted, Process: cur.proc})
+		case old.name != cur.name || !reflect.DeepEqual(old.params, cur.params):
+			events = append(events, ProcessEvent{
+				Type:     ProcessChanged,
+				Process:  cur.proc,
+				Previous: old.proc,
+			})
+		}
+	}
+
+	return events
+}
+
+func sortedPIDs(snapshot map[int]*processEntry) []int {
+	pids := make([]int, 0, len(snapshot))
+	for pid := range snapshot {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
@@ -0,0 +1,53 @@
+package telco
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffProcessSnapshots(t *testing.T) {
+	labels := make(map[*Process]string)
+	entry := func(label, name string, started any, params map[string]any) *processEntry {
+		proc := &Process{}
+		labels[proc] = label
+		return &processEntry{proc: proc, name: name, started: started, params: params}
+	}
+
+	init1 := entry("init", "init", nil, nil)
+	sshd := entry("sshd", "sshd", nil, map[string]any{"user": "root"})
+	sshdAsWWW := entry("sshd'", "sshd", nil, map[string]any{"user": "www"})
+	nginx := entry("nginx", "nginx", "2024-03-01T10:00:00Z", nil)
+	nginxRestarted := entry("nginx'", "nginx", "2024-03-01T11:00:00Z", nil)
+	nginxSame := entry("nginx''", "nginx", "2024-03-01T10:00:00Z", nil)
+	bash := entry("bash", "bash", nil, nil)
+	vim := entry("vim", "vim", nil, nil)
+
+	tests := []struct {
+		name       string
+		prev, next map[int]*processEntry
+		want       []string
+	}{
+		{"unchanged", map[int]*processEntry{1: init1}, map[int]*processEntry{1: init1}, nil},
+		{"added", map[int]*processEntry{1: init1}, map[int]*processEntry{1: init1, 30: bash, 20: vim}, []string{"started vim", "started bash"}},
+		{"removed", map[int]*processEntry{1: init1, 20: vim, 30: bash}, map[int]*processEntry{1: init1}, []string{"exited vim", "exited bash"}},
+		{"changed", map[int]*processEntry{22: sshd}, map[int]*processEntry{22: sshdAsWWW}, []string{"changed sshd' from sshd"}},
+		{"pid reused by name", map[int]*processEntry{40: bash}, map[int]*processEntry{40: vim}, []string{"exited bash", "started vim"}},
+		{"pid reused by start time", map[int]*processEntry{80: nginx}, map[int]*processEntry{80: nginxRestarted}, []string{"exited nginx", "started nginx'"}},
+		{"same start time", map[int]*processEntry{80: nginx}, map[int]*processEntry{80: nginxSame}, nil},
+		{"mixed", map[int]*processEntry{1: init1, 20: vim, 40: bash}, map[int]*processEntry{1: init1, 20: bash, 50: vim}, []string{"exited vim", "exited bash", "started bash", "started vim"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, ev := range diffProcessSnapshots(tt.prev, tt.next) {
+			desc := ev.Type.String() + " " + labels[ev.Process]
+			if ev.Previous != nil {
+				desc += " from " + labels[ev.Previous]
+			}
+			got = append(got, desc)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: events %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
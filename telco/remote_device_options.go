@@ -4,7 +4,10 @@ package telco
 #include <stdlib.h>
 */
 import "C"
-import "unsafe"
+import (
+	"strings"
+	"unsafe"
+)
 
 // RemoteDeviceOptions type is used to configure the remote device.
 type RemoteDeviceOptions struct {
@@ -41,14 +44,15 @@ func (r *RemoteDeviceOptions) KeepAliveInterval() int {
 	return int(C.telco_remote_device_options_get_keepalive_interval(r.opts))
 }
 
-// SetCertificate sets the certificate for the remote device.
-func (r *RemoteDeviceOptions) SetCertificate(certPath string) error {
-	cert, err := gTLSCertificateFromFile(certPath)
+// SetCertificate sets the certificate for the remote device, cert being the path to the
+// PEM encoded certificate or the PEM itself.
+func (r *RemoteDeviceOptions) SetCertificate(cert string) error {
+	crt, err := certificateFromConfig(cert)
 	if err != nil {
 		return err
 	}
 
-	C.telco_remote_device_options_set_certificate(r.opts, cert.cert)
+	C.telco_remote_device_options_set_certificate(r.opts, crt.cert)
 	return nil
 }
 
@@ -73,6 +77,17 @@ func (r *RemoteDeviceOptions) SetKeepAlive(interval int) {
 	C.telco_remote_device_options_set_keepalive_interval(r.opts, C.gint(interval))
 }
 
+// Config returns the config describing the options, with the certificate PEM
+// encoded. The address is not part of the options and is left empty.
+func (r *RemoteDeviceOptions) Config() RemoteDeviceConfig {
+	return RemoteDeviceConfig{
+		Certificate: r.Certificate().PEM(),
+		Origin:      r.Origin(),
+		Token:       r.Token(),
+		KeepAlive:   r.KeepAliveInterval(),
+	}
+}
+
 // Clean will clean the resources held by the remote device options.
 func (r *RemoteDeviceOptions) Clean() {
 	clean(unsafe.Pointer(r.opts), unrefTelco)
@@ -91,6 +106,25 @@ func gTLSCertificateFromFile(pempath string) (*Certificate, error) {
 	return &Certificate{gTLSCert}, nil
 }
 
+// certificateFromConfig loads the certificate from the value found in the config,
+// which is either the PEM encoded certificate or the path to it.
+func certificateFromConfig(value string) (*Certificate, error) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return gTLSCertificateFromFile(value)
+	}
+
+	pem := C.CString(value)
+	defer C.free(unsafe.Pointer(pem))
+
+	var err *C.GError
+	gTLSCert := C.g_tls_certificate_new_from_pem(pem, -1, &err)
+	if err != nil {
+		return nil, &FError{err}
+	}
+
+	return &Certificate{gTLSCert}, nil
+}
+
 func gFileFromPath(assetPath string) *C.GFile {
 	pth := C.CString(assetPath)
 	defer C.free(unsafe.Pointer(pth))
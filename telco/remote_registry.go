@@ -0,0 +1,527 @@
+package telco
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const (
+	defaultRemoteMinBackoff   = time.Second
+	defaultRemoteMaxBackoff   = time.Minute
+	defaultRemoteReloadPeriod = 2 * time.Second
+)
+
+// RemoteDeviceConfig describes the remote device kept connected by RemoteRegistry
+// and is the serializable form of RemoteDeviceOptions.
+type RemoteDeviceConfig struct {
+	Address string `json:"address"`
+	// Certificate is the PEM encoded certificate or the path to it.
+	Certificate string `json:"certificate,omitempty"`
+	Origin      string `json:"origin,omitempty"`
+	Token       string `json:"token,omitempty"`
+	// KeepAlive is the keepalive interval in seconds.
+	KeepAlive int `json:"keepalive,omitempty"`
+}
+
+// RemoteRegistryConfig is the content of the RemoteRegistry config file.
+//
+//	{
+//		"devices": [
+//			{"address": "10.0.0.5:27042", "token": "secret", "keepalive": 10}
+//		]
+//	}
+type RemoteRegistryConfig struct {
+	Devices []RemoteDeviceConfig `json:"devices"`
+}
+
+// LoadRemoteRegistryConfig reads the config from the JSON file.
+func LoadRemoteRegistryConfig(path string) (*RemoteRegistryConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseRemoteRegistryConfig(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func parseRemoteRegistryConfig(content []byte) (*RemoteRegistryConfig, error) {
+	var cfg RemoteRegistryConfig
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *RemoteRegistryConfig) validate() error {
+	seen := make(map[string]bool)
+	for _, dev := range c.Devices {
+		if err := dev.Validate(); err != nil {
+			return err
+		}
+		if seen[dev.Address] {
+			return fmt.Errorf("duplicate device %s", dev.Address)
+		}
+		seen[dev.Address] = true
+	}
+	return nil
+}
+
+// Validate returns the error if the config is not valid.
+func (c RemoteDeviceConfig) Validate() error {
+	if c.Address == "" {
+		return errors.New("device without address")
+	}
+	if c.KeepAlive < -1 {
+		return fmt.Errorf("device %s: invalid keepalive %d", c.Address, c.KeepAlive)
+	}
+	if err := validateCertificate(c.Certificate); err != nil {
+		return fmt.Errorf("device %s: %w", c.Address, err)
+	}
+	return nil
+}
+
+// Options returns the RemoteDeviceOptions described by the config; Address is
+// passed to AddRemoteDevice on its own.
+func (c RemoteDeviceConfig) Options() (*RemoteDeviceOptions, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	opts := NewRemoteDeviceOptions()
+	if c.Certificate != "" {
+		if err := opts.SetCertificate(c.Certificate); err != nil {
+			opts.Clean()
+			return nil, err
+		}
+	}
+	if c.Origin != "" {
+		opts.SetOrigin(c.Origin)
+	}
+	if c.Token != "" {
+		opts.SetToken(c.Token)
+	}
+	if c.KeepAlive != 0 {
+		opts.SetKeepAlive(c.KeepAlive)
+	}
+	return opts, nil
+}
+
+// RemoteDeviceHealth describes the state of the remote device kept by RemoteRegistry.
+type RemoteDeviceHealth struct {
+	Address   string
+	Connected bool
+	// Device is the connected device, nil while disconnected.
+	Device *Device
+	// LastError is the error of the last connection attempt.
+	LastError error
+	// Retries is the number of failed attempts since the device was last connected.
+	Retries       int
+	LastConnected time.Time
+	// NextRetry is the time of the next attempt while disconnected.
+	NextRetry time.Time
+}
+
+// RemoteRegistry keeps the remote devices listed in the config file connected to
+// the manager. Lost devices are added again with exponential backoff and the
+// config file is watched, so devices are added and removed as it changes.
+type RemoteRegistry struct {
+	mgr  *DeviceManager
+	path string
+
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// ReloadInterval is how often the config file is checked for changes.
+	ReloadInterval time.Duration
+
+	// reloadMu serializes Reload and Close
+	reloadMu sync.Mutex
+
+	mu      sync.Mutex
+	content []byte
+	remotes map[string]*remoteDevice
+	onError func(address string, err error)
+	// ctx is the context of Start the workers of the devices derive from.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type remoteDevice struct {
+	cfg    RemoteDeviceConfig
+	health RemoteDeviceHealth
+	// ctx is cancelled to stop the worker, including the connection attempt
+	// in progress.
+	ctx  context.Context
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+// NewRemoteRegistry returns the registry adding the devices from the config file
+// at path to the manager.
+func NewRemoteRegistry(mgr *DeviceManager, path string) *RemoteRegistry {
+	return &RemoteRegistry{
+		mgr:            mgr,
+		path:           path,
+		MinBackoff:     defaultRemoteMinBackoff,
+		MaxBackoff:     defaultRemoteMaxBackoff,
+		ReloadInterval: defaultRemoteReloadPeriod,
+		remotes:        make(map[string]*remoteDevice),
+	}
+}
+
+// OnError sets the callback called when connecting to the device fails or the
+// config file can't be reloaded. The callback runs on the goroutines of the
+// registry, so it must not call Close or Reload, which wait for them.
+func (r *RemoteRegistry) OnError(fn func(address string, err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = fn
+}
+
+// Start loads the config file, starts connecting the devices and watching the file.
+// It returns the error if the config file can't be loaded. The registry keeps
+// watching and reconnecting until ctx is done or Close is called; the devices
+// connected by then stay added to the manager until Close.
+func (r *RemoteRegistry) Start(ctx context.Context) error {
+	if r.mgr == nil {
+		return errors.New("could not start registry for nil manager")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.ctx = ctx
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	if err := r.Reload(); err != nil {
+		cancel()
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.watchConfig(ctx)
+	}()
+	return nil
+}
+
+// Close stops reconnecting and removes the devices added by the registry from the manager.
+func (r *RemoteRegistry) Close() error {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	r.wg.Wait()
+
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	r.mu.Lock()
+	remotes := r.remotes
+	r.remotes = make(map[string]*remoteDevice)
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, remote := range remotes {
+		if err := r.stopRemote(remote); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Health returns the state of all the devices ordered by their address.
+func (r *RemoteRegistry) Health() []RemoteDeviceHealth {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	health := make([]RemoteDeviceHealth, 0, len(r.remotes))
+	for _, remote := range r.remotes {
+		health = append(health, remote.health)
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].Address < health[j].Address
+	})
+	return health
+}
+
+// Device returns the connected device with the address or nil. The device is
+// owned by the registry and released once it is lost or removed.
+func (r *RemoteRegistry) Device(address string) *Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if remote, ok := r.remotes[address]; ok {
+		return remote.health.Device
+	}
+	return nil
+}
+
+// Reload reads the config file again, adding the new devices, removing the ones
+// no longer listed and reconnecting the ones whose config changed.
+func (r *RemoteRegistry) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	content, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	cfg, err := parseRemoteRegistryConfig(content)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", r.path, err)
+	}
+
+	r.mu.Lock()
+	r.content = content
+	current := make(map[string]RemoteDeviceConfig, len(r.remotes))
+	for address, remote := range r.remotes {
+		current[address] = remote.cfg
+	}
+	stopped, started := diffRemotes(current, cfg.Devices)
+
+	stale := make([]*remoteDevice, 0, len(stopped))
+	for _, address := range stopped {
+		stale = append(stale, r.remotes[address])
+		delete(r.remotes, address)
+	}
+	r.mu.Unlock()
+
+	for _, remote := range stale {
+		r.stopRemote(remote)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	parent := r.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	for _, dev := range started {
+		ctx, stop := context.WithCancel(parent)
+		remote := &remoteDevice{
+			cfg:    dev,
+			health: RemoteDeviceHealth{Address: dev.Address},
+			ctx:    ctx,
+			stop:   stop,
+			done:   make(chan struct{}),
+		}
+		r.remotes[dev.Address] = remote
+		go r.keepConnected(remote)
+	}
+	return nil
+}
+
+// diffRemotes returns the addresses of the current devices to stop, as they are
+// no longer listed or their config changed, and the configs of the devices to
+// start, in the order of wanted.
+func diffRemotes(current map[string]RemoteDeviceConfig, wanted []RemoteDeviceConfig) ([]string, []RemoteDeviceConfig) {
+	listed := make(map[string]RemoteDeviceConfig, len(wanted))
+	for _, dev := range wanted {
+		listed[dev.Address] = dev
+	}
+
+	var stopped []string
+	for address, cfg := range current {
+		if dev, ok := listed[address]; !ok || dev != cfg {
+			stopped = append(stopped, address)
+		}
+	}
+	sort.Strings(stopped)
+
+	var started []RemoteDeviceConfig
+	for _, dev := range wanted {
+		if cfg, ok := current[dev.Address]; !ok || cfg != dev {
+			started = append(started, dev)
+		}
+	}
+	return stopped, started
+}
+
+func (r *RemoteRegistry) watchConfig(ctx context.Context) {
+	interval := r.ReloadInterval
+	if interval <= 0 {
+		interval = defaultRemoteReloadPeriod
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		content, err := os.ReadFile(r.path)
+		if err != nil {
+			continue
+		}
+		r.mu.Lock()
+		changed := !bytes.Equal(content, r.content)
+		r.mu.Unlock()
+		if !changed {
+			continue
+		}
+		if err := r.Reload(); err != nil {
+			// keep the devices of the last valid config
+			r.reportError("", err)
+			r.mu.Lock()
+			r.content = content
+			r.mu.Unlock()
+		}
+	}
+}
+
+// stopRemote stops the worker of the device, cancelling the connection attempt
+// in progress, and removes the device from the manager if it is connected.
+func (r *RemoteRegistry) stopRemote(remote *remoteDevice) error {
+	remote.stop()
+	<-remote.done
+
+	r.mu.Lock()
+	connected, dev := remote.health.Connected, remote.health.Device
+	remote.health.Connected = false
+	remote.health.Device = nil
+	r.mu.Unlock()
+	if !connected {
+		return nil
+	}
+	err := r.mgr.RemoveRemoteDevice(remote.cfg.Address)
+	dev.Clean()
+	return err
+}
+
+func (r *RemoteRegistry) reportError(address string, err error) {
+	r.mu.Lock()
+	onError := r.onError
+	r.mu.Unlock()
+	if onError != nil {
+		onError(address, err)
+	}
+}
+
+func (r *RemoteRegistry) update(remote *remoteDevice, fn func(h *RemoteDeviceHealth)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&remote.health)
+}
+
+// backoff returns the delay before the attempt after the retries failed ones.
+func (r *RemoteRegistry) backoff(retries int) time.Duration {
+	lo, hi := r.MinBackoff, r.MaxBackoff
+	if lo <= 0 {
+		lo = defaultRemoteMinBackoff
+	}
+	if hi < lo {
+		hi = lo
+	}
+	delay := lo
+	for i := 1; i < retries && delay < hi; i++ {
+		delay *= 2
+	}
+	if delay > hi {
+		delay = hi
+	}
+	// up to 20% of jitter so the devices do not reconnect in lockstep
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// keepConnected adds the device and adds it again every time it is lost until
+// the remote is stopped.
+func (r *RemoteRegistry) keepConnected(remote *remoteDevice) {
+	defer close(remote.done)
+
+	for {
+		dev, err := r.connect(remote.ctx, remote.cfg)
+		if remote.ctx.Err() != nil {
+			if err == nil {
+				r.mgr.RemoveRemoteDevice(remote.cfg.Address)
+				dev.Clean()
+			}
+			return
+		}
+		if err != nil {
+			var delay time.Duration
+			r.update(remote, func(h *RemoteDeviceHealth) {
+				h.LastError = err
+				h.Retries++
+				delay = r.backoff(h.Retries)
+				h.NextRetry = time.Now().Add(delay)
+			})
+			r.reportError(remote.cfg.Address, err)
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-remote.ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			continue
+		}
+
+		lost := make(chan struct{})
+		var once sync.Once
+		handler := connectSignal(unsafe.Pointer(dev.device), "lost", func() {
+			once.Do(func() { close(lost) })
+		})
+		r.update(remote, func(h *RemoteDeviceHealth) {
+			h.Connected = true
+			h.Device = dev
+			h.LastError = nil
+			h.Retries = 0
+			h.LastConnected = time.Now()
+			h.NextRetry = time.Time{}
+		})
+
+		// the device might have been lost before the handler was connected
+		if dev.IsLost() {
+			once.Do(func() { close(lost) })
+		}
+
+		select {
+		case <-remote.ctx.Done():
+			handler.disconnect()
+			return
+		case <-lost:
+		}
+		handler.disconnect()
+
+		r.update(remote, func(h *RemoteDeviceHealth) {
+			h.Connected = false
+			h.Device = nil
+			h.LastError = ErrDeviceLost
+		})
+		r.reportError(remote.cfg.Address, ErrDeviceLost)
+		// forget the lost device so it can be added again
+		r.mgr.RemoveRemoteDevice(remote.cfg.Address)
+		dev.Clean()
+	}
+}
+
+func (r *RemoteRegistry) connect(ctx context.Context, cfg RemoteDeviceConfig) (*Device, error) {
+	opts, err := cfg.Options()
+	if err != nil {
+		return nil, err
+	}
+	defer opts.Clean()
+	return r.mgr.AddRemoteDeviceContext(ctx, cfg.Address, opts)
+}
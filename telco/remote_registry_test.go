@@ -0,0 +1,41 @@
+package telco
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRemotes(t *testing.T) {
+	a := RemoteDeviceConfig{Address: "10.0.0.1:27042"}
+	b := RemoteDeviceConfig{Address: "10.0.0.2:27042", Token: "secret"}
+	bRotated := RemoteDeviceConfig{Address: "10.0.0.2:27042", Token: "rotated"}
+	c := RemoteDeviceConfig{Address: "10.0.0.3:27042", KeepAlive: 10}
+
+	tests := []struct {
+		name        string
+		current     []RemoteDeviceConfig
+		wanted      []RemoteDeviceConfig
+		wantStopped []string
+		wantStarted []RemoteDeviceConfig
+	}{
+		{"empty", nil, nil, nil, nil},
+		{"added", []RemoteDeviceConfig{a}, []RemoteDeviceConfig{a, c, b}, nil, []RemoteDeviceConfig{c, b}},
+		{"removed", []RemoteDeviceConfig{a, b, c}, []RemoteDeviceConfig{b}, []string{a.Address, c.Address}, nil},
+		{"updated", []RemoteDeviceConfig{a, b}, []RemoteDeviceConfig{a, bRotated}, []string{b.Address}, []RemoteDeviceConfig{bRotated}},
+		{"unchanged", []RemoteDeviceConfig{a, b}, []RemoteDeviceConfig{b, a}, nil, nil},
+		{"replaced", []RemoteDeviceConfig{a}, []RemoteDeviceConfig{c}, []string{a.Address}, []RemoteDeviceConfig{c}},
+	}
+	for _, tt := range tests {
+		current := make(map[string]RemoteDeviceConfig, len(tt.current))
+		for _, dev := range tt.current {
+			current[dev.Address] = dev
+		}
+		stopped, started := diffRemotes(current, tt.wanted)
+		if !reflect.DeepEqual(stopped, tt.wantStopped) {
+			t.Errorf("%s: stopped = %v, want %v", tt.name, stopped, tt.wantStopped)
+		}
+		if !reflect.DeepEqual(started, tt.wantStarted) {
+			t.Errorf("%s: started = %v, want %v", tt.name, started, tt.wantStarted)
+		}
+	}
+}
@@ -16,6 +16,12 @@ import (
 
 var rpcCalls = sync.Map{}
 
+// rpcCall is the exports call waiting for the response from the script.
+type rpcCall struct {
+	script *Script
+	ch     chan any
+}
+
 // Script represents loaded string in the memory.
 type Script struct {
 	hasHandler bool
@@ -34,6 +40,8 @@ func (s *Script) Load() error {
 	if !s.hasHandler {
 		s.On("message", func() {})
 	}
+	connectClosure(unsafe.Pointer(s.sc), "destroyed", s.failPendingCalls)
+
 	var err *C.GError
 	C.telco_script_load_sync(s.sc, nil, &err)
 	if err != nil {
@@ -42,16 +50,32 @@ func (s *Script) Load() error {
 	return nil
 }
 
-// Unload function unload previously loaded script
+// Unload function unload previously loaded script. Exports calls still waiting for
+// the response return ErrScriptDestroyed.
 func (s *Script) Unload() error {
 	var err *C.GError
 	C.telco_script_unload_sync(s.sc, nil, &err)
 	if err != nil {
 		return &FError{err}
 	}
+	s.failPendingCalls()
 	return nil
 }
 
+// failPendingCalls completes the exports calls still waiting for the response
+// with ErrScriptDestroyed.
+func (s *Script) failPendingCalls() {
+	rpcCalls.Range(func(id, value any) bool {
+		if value.(rpcCall).script != s {
+			return true
+		}
+		if call, ok := rpcCalls.LoadAndDelete(id); ok {
+			call.(rpcCall).ch <- ErrScriptDestroyed
+		}
+		return true
+	})
+}
+
 // Eternalize function will keep the script loaded even after deataching from the process
 func (s *Script) Eternalize() error {
 	var err *C.GError
@@ -115,10 +139,7 @@ func (s *Script) ExportsCallWithContext(ctx context.Context, fn string, args ...
 	for {
 		select {
 		case <-ctx.Done():
-			// because the context is done, we still need to read from the channel
-			go func() {
-				<-ch
-			}()
+			// the channel is buffered, so the late response is dropped
 			return ErrContextCancelled
 		case ret := <-ch:
 			return ret
@@ -178,13 +199,12 @@ func (s *Script) hijackFn(message string, data []byte) {
 		if err != nil {
 			panic(err)
 		}
-		callerCh, ok := rpcCalls.Load(rpcID)
+		call, ok := rpcCalls.LoadAndDelete(rpcID)
 		if !ok {
-			panic("rpc-id not found")
+			// the call was already failed by Unload
+			return
 		}
-		ch := callerCh.(chan any)
-		ch <- ret
-		close(ch)
+		call.(rpcCall).ch <- ret
 
 	} else {
 		var args []reflect.Value
@@ -229,8 +249,16 @@ func (s *Script) makeExportsCall(fn string, args ...any) chan any {
 		rpc[ct] = aIface
 	}
 
-	ch := make(chan any)
-	rpcCalls.Store(rpcData[1], ch)
+	// buffered so the response never blocks the signal handler
+	ch := make(chan any, 1)
+	rpcCalls.Store(rpcData[1], rpcCall{script: s, ch: ch})
+	// the script destroyed before the call was stored will never fail it
+	if s.IsDestroyed() {
+		if _, ok := rpcCalls.LoadAndDelete(rpcData[1]); ok {
+			ch <- ErrScriptDestroyed
+		}
+		return ch
+	}
 
 	bt, _ := json.Marshal(rpc)
 	s.Post(string(bt), nil)
@@ -2,10 +2,7 @@ package telco
 
 //#include <telco-core.h>
 import "C"
-import (
-	"runtime"
-	"unsafe"
-)
+import "unsafe"
 
 // ScriptOptions type represents options passed to the session to create script.
 type ScriptOptions struct {
@@ -37,11 +34,8 @@ func (s *ScriptOptions) SetName(name string) {
 // SetSnapshot sets the snapshot for the script.
 func (s *ScriptOptions) SetSnapshot(value []byte) {
 	bts := goBytesToGBytes(value)
-	runtime.SetFinalizer(bts, func(g *C.GBytes) {
-		clean(unsafe.Pointer(g), unrefGObject)
-	})
 	C.telco_script_options_set_snapshot(s.opts, bts)
-	runtime.KeepAlive(bts)
+	C.g_bytes_unref(bts)
 }
 
 // SetSnapshotTransport sets the transport for the snapshot
@@ -55,6 +49,11 @@ func (s *ScriptOptions) SetRuntime(rt ScriptRuntime) {
 	C.telco_script_options_set_runtime(s.opts, C.TelcoScriptRuntime(rt))
 }
 
+// Runtime returns the runtime for the script.
+func (s *ScriptOptions) Runtime() ScriptRuntime {
+	return ScriptRuntime(int(C.telco_script_options_get_runtime(s.opts)))
+}
+
 // Name returns the name for the script.
 func (s *ScriptOptions) Name() string {
 	return C.GoString(C.telco_script_options_get_name(s.opts))
@@ -63,9 +62,7 @@ func (s *ScriptOptions) Name() string {
 // Snapshot returns the snapshot for the script.
 func (s *ScriptOptions) Snapshot() []byte {
 	snap := C.telco_script_options_get_snapshot(s.opts)
-	bts := getGBytes(snap)
-	clean(unsafe.Pointer(snap), unrefGObject)
-	return bts
+	return getGBytes(snap)
 }
 
 // SnapshotTransport returns the transport for the script.
@@ -2,10 +2,7 @@ package telco
 
 //#include <telco-core.h>
 import "C"
-import (
-	"runtime"
-	"unsafe"
-)
+import "unsafe"
 
 // Session type represents the session with the device.
 type Session struct {
@@ -68,9 +65,7 @@ func (s *Session) CreateScript(script string) (*Script, error) {
 // CreateScriptBytes is a wrapper around CreateScript(script string)
 func (s *Session) CreateScriptBytes(script []byte, opts *ScriptOptions) (*Script, error) {
 	bts := goBytesToGBytes(script)
-	runtime.SetFinalizer(bts, func(g *C.GBytes) {
-		clean(unsafe.Pointer(g), unrefGObject)
-	})
+	defer C.g_bytes_unref(bts)
 
 	if opts == nil {
 		opts = NewScriptOptions("telco-go")
@@ -83,7 +78,6 @@ func (s *Session) CreateScriptBytes(script []byte, opts *ScriptOptions) (*Script
 		opts.opts,
 		nil,
 		&err)
-	runtime.KeepAlive(bts)
 	if err != nil {
 		return nil, &FError{err}
 	}
@@ -0,0 +1,281 @@
+package telco
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	socks5Version = 0x05
+
+	socks5NoAuth       = 0x00
+	socks5NoAcceptable = 0xff
+
+	socks5Connect = 0x01
+
+	socks5IPv4   = 0x01
+	socks5Domain = 0x03
+	socks5IPv6   = 0x04
+
+	socks5Succeeded          = 0x00
+	socks5GeneralFailure     = 0x01
+	socks5CommandUnsupported = 0x07
+	socks5AddressUnsupported = 0x08
+
+	socks5HandshakeTimeout = 30 * time.Second
+	socks5DialTimeout      = 30 * time.Second
+)
+
+// SOCKS5Proxy is the local SOCKS5 server connecting its clients to the services
+// on the device through DialContext. Only the CONNECT command without
+// authentication is supported.
+type SOCKS5Proxy struct {
+	listener net.Listener
+	dial     func(ctx context.Context, network, address string) (net.Conn, error)
+	ctx      context.Context
+	cancel   context.CancelFunc
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	onError func(error)
+
+	wg   sync.WaitGroup
+	done chan struct{}
+}
+
+// ListenSOCKS5 starts the SOCKS5 server on localAddr, which is the TCP address or
+// the Unix socket path prefixed with "unix:". The hosts requested by the clients
+// are mapped to the channels as described in ChannelAddress, so "localhost:8080"
+// reaches port 8080 on the device. The server is closed once ctx is done.
+func (d *Device) ListenSOCKS5(ctx context.Context, localAddr string) (*SOCKS5Proxy, error) {
+	if d.device == nil {
+		return nil, errors.New("could not listen for nil device")
+	}
+
+	listener, err := listenLocal(localAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyCtx, cancel := context.WithCancel(ctx)
+	p := &SOCKS5Proxy{
+		listener: listener,
+		dial:     d.DialContext,
+		ctx:      proxyCtx,
+		cancel:   cancel,
+		conns:    make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+	}
+
+	go p.serve()
+	go func() {
+		<-proxyCtx.Done()
+		p.Close()
+	}()
+
+	return p, nil
+}
+
+// Addr returns the local address the server listens on.
+func (p *SOCKS5Proxy) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// Done returns the channel closed once the server stops accepting connections.
+func (p *SOCKS5Proxy) Done() <-chan struct{} {
+	return p.done
+}
+
+// OnError sets the callback called when the client connection fails.
+func (p *SOCKS5Proxy) OnError(fn func(err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onError = fn
+}
+
+// Close stops the server and closes all the client connections.
+func (p *SOCKS5Proxy) Close() error {
+	p.cancel()
+	err := p.listener.Close()
+	<-p.done
+
+	p.mu.Lock()
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+func (p *SOCKS5Proxy) serve() {
+	defer close(p.done)
+
+	for {
+		client, err := p.listener.Accept()
+		if err != nil {
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return
+		}
+
+		p.mu.Lock()
+		p.conns[client] = struct{}{}
+		p.mu.Unlock()
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			if err := p.handle(client); err != nil {
+				p.reportError(fmt.Errorf("socks5 %s: %w", client.RemoteAddr(), err))
+			}
+			p.mu.Lock()
+			delete(p.conns, client)
+			p.mu.Unlock()
+		}()
+	}
+}
+
+func (p *SOCKS5Proxy) reportError(err error) {
+	p.mu.Lock()
+	onError := p.onError
+	p.mu.Unlock()
+	if onError != nil {
+		onError(err)
+	}
+}
+
+func (p *SOCKS5Proxy) handle(client net.Conn) error {
+	defer client.Close()
+
+	client.SetDeadline(time.Now().Add(socks5HandshakeTimeout))
+	r := bufio.NewReader(client)
+
+	if err := socks5Negotiate(r, client); err != nil {
+		return err
+	}
+
+	address, reply, err := socks5ReadRequest(r)
+	if err != nil {
+		if reply != socks5Succeeded {
+			socks5WriteReply(client, reply)
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(p.ctx, socks5DialTimeout)
+	conn, err := p.dial(ctx, "tcp", address)
+	cancel()
+	if err != nil {
+		socks5WriteReply(client, socks5GeneralFailure)
+		return err
+	}
+
+	if err := socks5WriteReply(client, socks5Succeeded); err != nil {
+		conn.Close()
+		return err
+	}
+	client.SetDeadline(time.Time{})
+
+	// data the client sent right after the request is already buffered
+	if n := r.Buffered(); n > 0 {
+		buffered, _ := r.Peek(n)
+		if _, err := conn.Write(buffered); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	return pipeConns(client, conn, nil, nil)
+}
+
+// socks5Negotiate reads the greeting of the client and selects no authentication.
+func socks5Negotiate(r io.Reader, w io.Writer) error {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return err
+	}
+	if hdr[0] != socks5Version {
+		return fmt.Errorf("unsupported socks version %d", hdr[0])
+	}
+
+	methods := make([]byte, hdr[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return err
+	}
+	for _, method := range methods {
+		if method == socks5NoAuth {
+			_, err := w.Write([]byte{socks5Version, socks5NoAuth})
+			return err
+		}
+	}
+
+	w.Write([]byte{socks5Version, socks5NoAcceptable})
+	return errors.New("client requires authentication")
+}
+
+// socks5ReadRequest reads the request and returns the requested address. On
+// failure it returns the reply to send to the client, if any.
+func socks5ReadRequest(r io.Reader) (string, byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return "", socks5Succeeded, err
+	}
+	if hdr[0] != socks5Version {
+		return "", socks5Succeeded, fmt.Errorf("unsupported socks version %d", hdr[0])
+	}
+	if hdr[1] != socks5Connect {
+		return "", socks5CommandUnsupported, fmt.Errorf("unsupported command %d", hdr[1])
+	}
+
+	var host string
+	switch hdr[3] {
+	case socks5IPv4, socks5IPv6:
+		ip := make(net.IP, net.IPv4len)
+		if hdr[3] == socks5IPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", socks5Succeeded, err
+		}
+		host = ip.String()
+	case socks5Domain:
+		var n [1]byte
+		if _, err := io.ReadFull(r, n[:]); err != nil {
+			return "", socks5Succeeded, err
+		}
+		domain := make([]byte, n[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return "", socks5Succeeded, err
+		}
+		host = string(domain)
+	default:
+		return "", socks5AddressUnsupported, fmt.Errorf("unsupported address type %d", hdr[3])
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return "", socks5Succeeded, err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), socks5Succeeded, nil
+}
+
+// socks5WriteReply writes the reply with the unspecified bound address.
+func socks5WriteReply(w io.Writer, reply byte) error {
+	_, err := w.Write([]byte{socks5Version, reply, 0x00, socks5IPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,114 @@
+package telco
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSOCKS5Negotiate(t *testing.T) {
+	tests := []struct {
+		greeting []byte
+		reply    []byte
+		err      string
+	}{
+		{[]byte{5, 1, 0}, []byte{5, 0}, ""},
+		{[]byte{5, 2, 2, 0}, []byte{5, 0}, ""},
+		{[]byte{5, 1, 2}, []byte{5, 0xff}, "client requires authentication"},
+		{[]byte{4, 1, 0}, nil, "unsupported socks version 4"},
+		{[]byte{5, 2, 0}, nil, "EOF"},
+	}
+
+	for _, tt := range tests {
+		var w bytes.Buffer
+		err := socks5Negotiate(bytes.NewReader(tt.greeting), &w)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("greeting %v: error %v, want %q", tt.greeting, err, tt.err)
+		}
+		if !bytes.Equal(w.Bytes(), tt.reply) {
+			t.Errorf("greeting %v: reply %v, want %v", tt.greeting, w.Bytes(), tt.reply)
+		}
+	}
+}
+
+func TestSOCKS5ReadRequest(t *testing.T) {
+	tests := []struct {
+		request []byte
+		address string
+		reply   byte
+		err     string
+	}{
+		{[]byte{5, 1, 0, 1, 127, 0, 0, 1, 0x1f, 0x90}, "127.0.0.1:8080", socks5Succeeded, ""},
+		{append([]byte{5, 1, 0, 3, 9}, "localhost\x00\x16"...), "localhost:22", socks5Succeeded, ""},
+		{append(append([]byte{5, 1, 0, 4}, net.IPv6loopback...), 0, 80), "[::1]:80", socks5Succeeded, ""},
+		{[]byte{5, 2, 0, 1, 127, 0, 0, 1, 0, 80}, "", socks5CommandUnsupported, "unsupported command 2"},
+		{[]byte{5, 1, 0, 9}, "", socks5AddressUnsupported, "unsupported address type 9"},
+		{[]byte{4, 1, 0, 1}, "", socks5Succeeded, "unsupported socks version 4"},
+		{[]byte{5, 1, 0, 3, 9, 'l'}, "", socks5Succeeded, "EOF"},
+	}
+
+	for _, tt := range tests {
+		address, reply, err := socks5ReadRequest(bytes.NewReader(tt.request))
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("request %v: error %v, want %q", tt.request, err, tt.err)
+		}
+		if address != tt.address || reply != tt.reply {
+			t.Errorf("request %v = %q, %d, want %q, %d", tt.request, address, reply, tt.address, tt.reply)
+		}
+	}
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	dialed := make(chan string, 1)
+	p := &SOCKS5Proxy{
+		listener: listener,
+		dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed <- address
+			local, remote := net.Pipe()
+			// the service echoes what it reads
+			go func() {
+				io.Copy(remote, remote)
+				remote.Close()
+			}()
+			return local, nil
+		},
+		conns: make(map[net.Conn]struct{}),
+		done:  make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	go p.serve()
+	defer p.Close()
+
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// the greeting, the request and the first data are sent at once
+	msg := []byte{5, 1, 0, 5, 1, 0, 3, 9}
+	msg = append(msg, "localhost\x1f\x90ping"...)
+	if _, err := client.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := make([]byte, 2+10+4)
+	if _, err := io.ReadFull(client, resp); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{5, 0, 5, socks5Succeeded, 0, socks5IPv4, 0, 0, 0, 0, 0, 0}, "ping"...)
+	if !bytes.Equal(resp, want) {
+		t.Errorf("response = %v, want %v", resp, want)
+	}
+	if address := <-dialed; address != "localhost:8080" {
+		t.Errorf("dialed %q, want localhost:8080", address)
+	}
+}
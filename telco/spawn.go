@@ -2,7 +2,12 @@ package telco
 
 //#include <telco-core.h>
 import "C"
-import "unsafe"
+import (
+	"context"
+	"errors"
+	"sync"
+	"unsafe"
+)
 
 // Spawn represents spawn of the device.
 type Spawn struct {
@@ -21,5 +26,71 @@ func (s *Spawn) Identifier() string {
 
 // Clean will clean the resources held by the spawn.
 func (s *Spawn) Clean() {
-	clean(unsafe.Pointer(s), unrefTelco)
+	clean(unsafe.Pointer(s.spawn), unrefTelco)
+}
+
+// WatchSpawns reports the spawns gated on the device on the returned channel; spawn
+// gating needs to be enabled with EnableSpawnGating. Every spawn needs to be
+// resumed with Resume once it is handled and released with Clean. Spawns are
+// queued, so slow receivers never block the device. The channel is closed once
+// ctx is done; the spawns not received by then are released but not resumed.
+func (d *Device) WatchSpawns(ctx context.Context) (<-chan *Spawn, error) {
+	if d.device == nil {
+		return nil, errors.New("could not watch spawns for nil device")
+	}
+
+	var mu sync.Mutex
+	var queue []*Spawn
+	notify := make(chan struct{}, 1)
+	handler := connectSignal(unsafe.Pointer(d.device), "spawn_added", func(spawn *Spawn) {
+		// the spawn outlives the signal emission inside the queue
+		C.g_object_ref(C.gpointer(spawn.spawn))
+		mu.Lock()
+		queue = append(queue, spawn)
+		mu.Unlock()
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	})
+
+	spawns := make(chan *Spawn)
+	go func() {
+		defer close(spawns)
+		defer func() {
+			handler.disconnect()
+			mu.Lock()
+			for _, spawn := range queue {
+				spawn.Clean()
+			}
+			queue = nil
+			mu.Unlock()
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-notify:
+			}
+
+			mu.Lock()
+			pending := queue
+			queue = nil
+			mu.Unlock()
+
+			for i, spawn := range pending {
+				select {
+				case spawns <- spawn:
+				case <-ctx.Done():
+					mu.Lock()
+					queue = append(pending[i:], queue...)
+					mu.Unlock()
+					return
+				}
+			}
+		}
+	}()
+
+	return spawns, nil
 }
@@ -3,6 +3,10 @@ package telco
 //#include <telco-core.h>
 import "C"
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
 	"unsafe"
 )
 
@@ -22,6 +26,7 @@ func NewSpawnOptions() *SpawnOptions {
 // SetArgv set spawns argv with the argv provided.
 func (s *SpawnOptions) SetArgv(argv []string) {
 	arr, sz := stringSliceToCharArr(argv)
+	defer freeCharArray(arr, sz)
 
 	C.telco_spawn_options_set_argv(s.opts, arr, sz)
 }
@@ -36,41 +41,49 @@ func (s *SpawnOptions) Argv() []string {
 	return argv
 }
 
-// SetEnvp set spawns envp with the envp provided.
+// SetEnvp set spawns envp with the envp provided, replacing the whole environment
+// of the spawned process. Variables are sorted by their name; use SetEnvpList to
+// keep the order.
 func (s *SpawnOptions) SetEnvp(envp map[string]string) {
-	i := 0
-	sl := make([]string, len(envp))
+	s.SetEnvpList(envMapToList(envp))
+}
 
-	for k, v := range envp {
-		sl[i] = k + "=" + v
-		i++
-	}
+// SetEnvpList set spawns envp with the "KEY=value" entries provided, in order.
+func (s *SpawnOptions) SetEnvpList(envp []string) {
+	arr, sz := stringSliceToCharArr(envp)
+	defer freeCharArray(arr, sz)
 
-	arr, sz := stringSliceToCharArr(sl)
 	C.telco_spawn_options_set_envp(s.opts, arr, sz)
 }
 
+// SetEnvpFromEnviron set spawns envp with the environment of the current process
+// and the "KEY=value" overrides applied to it, as done by MergeEnv.
+func (s *SpawnOptions) SetEnvpFromEnviron(overrides ...string) {
+	s.SetEnvpList(MergeEnv(os.Environ(), overrides...))
+}
+
 // Envp returns envp of the spawn.
 func (s *SpawnOptions) Envp() []string {
 	var count C.gint
-	envpC := C.telco_spawn_options_get_argv(s.opts, &count)
+	envpC := C.telco_spawn_options_get_envp(s.opts, &count)
 
 	envp := cArrayToStringSlice(envpC, C.int(count))
 
 	return envp
 }
 
-// SetEnv set spawns env with the env provided.
+// SetEnv set spawns env with the env provided. Unlike envp, env is added to the
+// environment the process is spawned with. Variables are sorted by their name;
+// use SetEnvList to keep the order.
 func (s *SpawnOptions) SetEnv(env map[string]string) {
-	i := 0
-	sl := make([]string, len(env))
+	s.SetEnvList(envMapToList(env))
+}
 
-	for k, v := range env {
-		sl[i] = k + "=" + v
-		i++
-	}
+// SetEnvList set spawns env with the "KEY=value" entries provided, in order.
+func (s *SpawnOptions) SetEnvList(env []string) {
+	arr, sz := stringSliceToCharArr(env)
+	defer freeCharArray(arr, sz)
 
-	arr, sz := stringSliceToCharArr(sl)
 	C.telco_spawn_options_set_env(s.opts, arr, sz)
 }
 
@@ -114,6 +127,100 @@ func (s *SpawnOptions) Aux() map[string]any {
 	return aux
 }
 
+// SetAux replaces aux of the spawn, the platform specific extras such as "url" or
+// "activity" on Android and "uid" or "aslr" on Linux and Darwin. Values are
+// converted to GVariant as their Go types, so int becomes "x" and int32 "i";
+// struct fields are named after the telco tag unless set with WithStructTag.
+// Aux is left untouched if any of the values cannot be converted.
+func (s *SpawnOptions) SetAux(aux map[string]any, opts ...ConvertOption) error {
+	variants := make(map[string]*C.GVariant, len(aux))
+	for key, value := range aux {
+		variant, err := goToGVariant(value, opts...)
+		if err != nil {
+			for _, v := range variants {
+				C.g_variant_unref(v)
+			}
+			return fmt.Errorf("aux %s: %w", key, err)
+		}
+		variants[key] = variant
+	}
+
+	ht := C.telco_spawn_options_get_aux(s.opts)
+	C.g_hash_table_remove_all(ht)
+	for key, variant := range variants {
+		insertAux(ht, key, variant)
+	}
+	return nil
+}
+
+// SetAuxValue sets the single aux value of the spawn, see SetAux.
+func (s *SpawnOptions) SetAuxValue(key string, value any, opts ...ConvertOption) error {
+	variant, err := goToGVariant(value, opts...)
+	if err != nil {
+		return fmt.Errorf("aux %s: %w", key, err)
+	}
+	insertAux(C.telco_spawn_options_get_aux(s.opts), key, variant)
+	return nil
+}
+
+// RemoveAux removes the aux value of the spawn with the key provided.
+func (s *SpawnOptions) RemoveAux(key string) {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+
+	C.g_hash_table_remove(C.telco_spawn_options_get_aux(s.opts), C.gconstpointer(keyC))
+}
+
+// insertAux passes the ownership of the variant to the aux table.
+func insertAux(ht *C.GHashTable, key string, variant *C.GVariant) {
+	keyC := C.CString(key)
+	defer C.free(unsafe.Pointer(keyC))
+
+	C.g_hash_table_insert(ht, C.gpointer(C.g_strdup((*C.gchar)(keyC))), C.gpointer(variant))
+}
+
+// MergeEnv returns base with the "KEY=value" overrides applied. Overridden
+// variables keep their position in base, new ones are appended in the order of
+// overrides and for the same key the last override wins. Entries without "="
+// are treated as variables set to the empty string.
+func MergeEnv(base []string, overrides ...string) []string {
+	merged := make([]string, 0, len(base)+len(overrides))
+	index := make(map[string]int, len(base)+len(overrides))
+
+	set := func(entry string) {
+		key, value, _ := strings.Cut(entry, "=")
+		entry = key + "=" + value
+		if i, ok := index[key]; ok {
+			merged[i] = entry
+			return
+		}
+		index[key] = len(merged)
+		merged = append(merged, entry)
+	}
+
+	for _, entry := range base {
+		set(entry)
+	}
+	for _, entry := range overrides {
+		set(entry)
+	}
+	return merged
+}
+
+func envMapToList(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	list := make([]string, len(keys))
+	for i, k := range keys {
+		list[i] = k + "=" + env[k]
+	}
+	return list
+}
+
 // Clean will clean the resources held by the spawn options.
 func (s *SpawnOptions) Clean() {
 	clean(unsafe.Pointer(s.opts), unrefTelco)
@@ -0,0 +1,76 @@
+package telco
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeEnv(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      []string
+		overrides []string
+		want      []string
+	}{
+		{"empty", nil, nil, []string{}},
+		{"no overrides", []string{"HOME=/root", "PATH=/bin"}, nil, []string{"HOME=/root", "PATH=/bin"}},
+		{"override keeps position", []string{"HOME=/root", "PATH=/bin", "TERM=xterm"}, []string{"PATH=/usr/bin"}, []string{"HOME=/root", "PATH=/usr/bin", "TERM=xterm"}},
+		{"new appended in order", []string{"HOME=/root"}, []string{"B=2", "A=1"}, []string{"HOME=/root", "B=2", "A=1"}},
+		{"last override wins", []string{"HOME=/root"}, []string{"A=1", "HOME=/tmp", "A=2"}, []string{"HOME=/tmp", "A=2"}},
+		{"duplicate base keys", []string{"A=1", "B=2", "A=3"}, nil, []string{"A=3", "B=2"}},
+		{"value with equals", []string{"OPTS=a=b"}, []string{"X=c=d"}, []string{"OPTS=a=b", "X=c=d"}},
+		{"without equals", []string{"A=1"}, []string{"A", "EMPTY"}, []string{"A=", "EMPTY="}},
+		{"empty value", []string{"A=1"}, []string{"A="}, []string{"A="}},
+	}
+
+	for _, tt := range tests {
+		if got := MergeEnv(tt.base, tt.overrides...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: MergeEnv(%q, %q) = %q, want %q", tt.name, tt.base, tt.overrides, got, tt.want)
+		}
+	}
+}
+
+func TestEnvMapToList(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want []string
+	}{
+		{nil, []string{}},
+		{map[string]string{"PATH": "/bin", "HOME": "/root", "A": ""}, []string{"A=", "HOME=/root", "PATH=/bin"}},
+	}
+	for _, tt := range tests {
+		if got := envMapToList(tt.env); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("envMapToList(%v) = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestSpawnOptionsArgvEnvp(t *testing.T) {
+	opts := NewSpawnOptions()
+	if opts.opts == nil {
+		t.Skip("spawn options not available")
+	}
+	defer opts.Clean()
+
+	// setting again frees the previous arrays, reading back leaves them owned
+	// by the options
+	for _, argv := range [][]string{{"/bin/ls", "-l"}, {"/bin/sh", "-c", "id"}, {}} {
+		opts.SetArgv(argv)
+		if got := opts.Argv(); !reflect.DeepEqual(got, argv) {
+			t.Errorf("Argv() after SetArgv(%q) = %q", argv, got)
+		}
+	}
+
+	opts.SetArgv([]string{"/bin/true"})
+	opts.SetEnvpList([]string{"B=2", "A=1"})
+	if got, want := opts.Envp(), []string{"B=2", "A=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Envp() = %q, want %q", got, want)
+	}
+	opts.SetEnvp(map[string]string{"B": "2", "A": "1"})
+	if got, want := opts.Envp(), []string{"A=1", "B=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Envp() after SetEnvp = %q, want %q", got, want)
+	}
+	if got, want := opts.Argv(), []string{"/bin/true"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Argv() after SetEnvp = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package telco
+
+import (
+	"github.com/telco/telco-go/symbolicate"
+)
+
+// Symbolicate resolves the frames of all the threads using the local files of the
+// symbolicator. Frames are matched to the binary images by their address when
+// the module offset is not part of the report. Functions inlined at the frame are
+// inserted before it as frames with Inlined set. It returns the number of frames
+// resolved.
+func (r *CrashReport) Symbolicate(s *symbolicate.Symbolicator) int {
+	resolved := 0
+	for t := range r.Threads {
+		thread := &r.Threads[t]
+		frames := make([]CrashFrame, 0, len(thread.Frames))
+		for _, frame := range thread.Frames {
+			expanded := r.symbolicateFrame(s, frame)
+			if len(expanded) > 1 || expanded[0].File != "" || expanded[0].Symbol != frame.Symbol {
+				resolved++
+			}
+			frames = append(frames, expanded...)
+		}
+		thread.Frames = frames
+	}
+	return resolved
+}
+
+func (r *CrashReport) symbolicateFrame(s *symbolicate.Symbolicator, frame CrashFrame) []CrashFrame {
+	if frame.Inlined || frame.File != "" {
+		return []CrashFrame{frame}
+	}
+
+	module, buildID, offset := frame.Module, frame.BuildID, frame.ModuleOffset
+	if img := r.Image(frame.Address); img != nil && offset == 0 {
+		offset = frame.Address - img.Start
+		if buildID == "" {
+			buildID = img.BuildID
+		}
+		if img.Path != "" {
+			module = img.Path
+		}
+	}
+	if module == "" && buildID == "" {
+		return []CrashFrame{frame}
+	}
+
+	resolved, err := s.Lookup(module, buildID, offset)
+	if err != nil {
+		return []CrashFrame{frame}
+	}
+
+	ret := make([]CrashFrame, len(resolved))
+	for i, sym := range resolved {
+		f := frame
+		f.Symbol = sym.Function
+		f.SymbolOffset = sym.Offset
+		f.File = sym.File
+		f.Line = sym.Line
+		f.Inlined = sym.Inlined
+		ret[i] = f
+	}
+	return ret
+}
+
+// SymbolicatedStack returns the stack of the error message with the native
+// "module!0xoffset" frames resolved using the symbolicator.
+func (m *Message) SymbolicatedStack(s *symbolicate.Symbolicator) string {
+	return s.Text(m.Stack)
+}
@@ -0,0 +1,311 @@
+package telco
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Target represents the process that Attach, InjectLibraryFile and InjectLibraryBlob
+// will operate on.
+// Use TargetPID, TargetName, TargetIdentifier, TargetProcess, TargetApplication or
+// TargetFunc to create one.
+type Target interface {
+	// String returns the human readable description of the target.
+	String() string
+	resolve(d *Device) ([]TargetCandidate, error)
+}
+
+// TargetCandidate is the process matched while resolving the target.
+type TargetCandidate struct {
+	PID  int
+	Name string
+}
+
+// String returns the candidate in format NAME(PID).
+func (c TargetCandidate) String() string {
+	if c.Name == "" {
+		return fmt.Sprintf("%d", c.PID)
+	}
+	return fmt.Sprintf("%s(%d)", c.Name, c.PID)
+}
+
+func candidatesFromProcesses(procs []*Process) []TargetCandidate {
+	candidates := make([]TargetCandidate, len(procs))
+	for i, proc := range procs {
+		candidates[i] = TargetCandidate{PID: proc.PID(), Name: proc.Name()}
+	}
+	return candidates
+}
+
+// AmbiguousTargetError is returned when more than one process matches the target.
+// It wraps ErrAmbiguousTarget so it can be checked with errors.Is.
+type AmbiguousTargetError struct {
+	Target     Target
+	Candidates []TargetCandidate
+}
+
+// Error returns string representation of AmbiguousTargetError listing the candidates.
+func (e *AmbiguousTargetError) Error() string {
+	candidates := make([]string, len(e.Candidates))
+	for i, candidate := range e.Candidates {
+		candidates[i] = candidate.String()
+	}
+	return fmt.Sprintf("%s: %s matches %s",
+		ErrAmbiguousTarget,
+		e.Target,
+		strings.Join(candidates, ", "))
+}
+
+// Unwrap returns ErrAmbiguousTarget.
+func (e *AmbiguousTargetError) Unwrap() error {
+	return ErrAmbiguousTarget
+}
+
+type pidTarget int
+
+// TargetPID returns the target matching the process with the pid provided.
+func TargetPID(pid int) Target {
+	return pidTarget(pid)
+}
+
+func (t pidTarget) String() string {
+	return fmt.Sprintf("pid %d", int(t))
+}
+
+func (t pidTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	if int(t) <= 0 {
+		return nil, fmt.Errorf("invalid pid %d", int(t))
+	}
+	return []TargetCandidate{{PID: int(t)}}, nil
+}
+
+type nameTarget struct {
+	name string
+	fold bool
+}
+
+// TargetName returns the target matching processes with the name provided.
+func TargetName(name string) Target {
+	return nameTarget{name: name}
+}
+
+// TargetNameFold is TargetName matching the name case-insensitively when no
+// process has exactly the name provided.
+func TargetNameFold(name string) Target {
+	return nameTarget{name: name, fold: true}
+}
+
+func (t nameTarget) String() string {
+	return fmt.Sprintf("name %q", t.name)
+}
+
+func (t nameTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	procs, err := d.EnumerateProcesses(ScopeMinimal)
+	if err != nil {
+		return nil, err
+	}
+
+	var exact, folded []*Process
+	for _, proc := range procs {
+		switch name := proc.Name(); {
+		case name == t.name:
+			exact = append(exact, proc)
+		case t.fold && strings.EqualFold(name, t.name):
+			folded = append(folded, proc)
+		}
+	}
+
+	if len(exact) > 0 {
+		return candidatesFromProcesses(exact), nil
+	}
+	return candidatesFromProcesses(folded), nil
+}
+
+// nameOrIdentifierTarget is the target created by NewTarget from the string.
+type nameOrIdentifierTarget string
+
+func (t nameOrIdentifierTarget) String() string {
+	return fmt.Sprintf("name or identifier %q", string(t))
+}
+
+func (t nameOrIdentifierTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	candidates, err := TargetName(string(t)).resolve(d)
+	if err != nil || len(candidates) > 0 {
+		return candidates, err
+	}
+	return TargetIdentifier(string(t)).resolve(d)
+}
+
+type identifierTarget string
+
+// TargetIdentifier returns the target matching the running application with the identifier provided.
+func TargetIdentifier(identifier string) Target {
+	return identifierTarget(identifier)
+}
+
+func (t identifierTarget) String() string {
+	return fmt.Sprintf("identifier %q", string(t))
+}
+
+func (t identifierTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	apps, err := d.EnumerateApplications(string(t), ScopeMinimal)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []TargetCandidate
+	for _, app := range apps {
+		if app.Identifier() == string(t) && app.PID() > 0 {
+			candidates = append(candidates, TargetCandidate{PID: app.PID(), Name: app.Name()})
+		}
+	}
+	return candidates, nil
+}
+
+type processTarget struct {
+	proc *Process
+}
+
+// TargetProcess returns the target matching the process provided.
+// The target created for nil fails to resolve; NewTarget rejects nil.
+func TargetProcess(proc *Process) Target {
+	return processTarget{proc}
+}
+
+func (t processTarget) String() string {
+	if t.proc == nil {
+		return "nil process"
+	}
+	return fmt.Sprintf("process %s(%d)", t.proc.Name(), t.proc.PID())
+}
+
+func (t processTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	if t.proc == nil || t.proc.PID() <= 0 {
+		return nil, errors.New("process is not valid")
+	}
+	return candidatesFromProcesses([]*Process{t.proc}), nil
+}
+
+type applicationTarget struct {
+	app *Application
+}
+
+// TargetApplication returns the target matching the application provided.
+// The application needs to be running. The target created for nil fails to
+// resolve; NewTarget rejects nil.
+func TargetApplication(app *Application) Target {
+	return applicationTarget{app}
+}
+
+func (t applicationTarget) String() string {
+	if t.app == nil {
+		return "nil application"
+	}
+	return fmt.Sprintf("application %q", t.app.Identifier())
+}
+
+func (t applicationTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	if t.app == nil {
+		return nil, errors.New("application is not valid")
+	}
+	if t.app.PID() <= 0 {
+		return nil, fmt.Errorf("application %q is not running", t.app.Identifier())
+	}
+	return []TargetCandidate{{PID: t.app.PID(), Name: t.app.Name()}}, nil
+}
+
+type funcTarget func(proc *Process) bool
+
+// TargetFunc returns the target matching processes for which fn returns true.
+// Processes are enumerated with ScopeMetadata so fn can inspect Params().
+func TargetFunc(fn func(proc *Process) bool) Target {
+	return funcTarget(fn)
+}
+
+func (t funcTarget) String() string {
+	return "predicate"
+}
+
+func (t funcTarget) resolve(d *Device) ([]TargetCandidate, error) {
+	procs, err := d.EnumerateProcesses(ScopeMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []*Process
+	for _, proc := range procs {
+		if t(proc) {
+			matched = append(matched, proc)
+		}
+	}
+	return candidatesFromProcesses(matched), nil
+}
+
+// NewTarget converts val into the Target.
+// Accepted values are Target, pid (any integer type), *Process, *Application
+// and string, matched as the process name or, when no process has the name, as
+// the identifier of the running application. Nil processes and applications are
+// rejected.
+func NewTarget(val any) (Target, error) {
+	switch v := val.(type) {
+	case Target:
+		return v, nil
+	case *Process:
+		if v == nil {
+			return nil, errors.New("nil process")
+		}
+		return TargetProcess(v), nil
+	case *Application:
+		if v == nil {
+			return nil, errors.New("nil application")
+		}
+		return TargetApplication(v), nil
+	case func(*Process) bool:
+		return TargetFunc(v), nil
+	}
+
+	switch v := reflect.ValueOf(val); v.Kind() {
+	case reflect.String:
+		return nameOrIdentifierTarget(v.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return TargetPID(int(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return TargetPID(int(v.Uint())), nil
+	default:
+		return nil, errors.New("expected name of app/process, PID, *Process, *Application or Target")
+	}
+}
+
+// ResolveTarget returns the pid of the single process matched by val.
+// val can be anything accepted by NewTarget.
+// If no process matches, ErrTargetNotFound is returned; if more than one
+// process matches, *AmbiguousTargetError is returned.
+func (d *Device) ResolveTarget(val any) (int, error) {
+	if d.device == nil {
+		return -1, errors.New("could not resolve target for nil device")
+	}
+
+	target, err := NewTarget(val)
+	if err != nil {
+		return -1, err
+	}
+
+	candidates, err := target.resolve(d)
+	if err != nil {
+		return -1, err
+	}
+
+	switch len(candidates) {
+	case 0:
+		return -1, fmt.Errorf("%w: %s", ErrTargetNotFound, target)
+	case 1:
+		return candidates[0].PID, nil
+	default:
+		return -1, &AmbiguousTargetError{
+			Target:     target,
+			Candidates: candidates,
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package telco
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNilTargets(t *testing.T) {
+	tests := []struct {
+		target Target
+		str    string
+		err    string
+	}{
+		{TargetProcess(nil), "nil process", "process is not valid"},
+		{TargetApplication(nil), "nil application", "application is not valid"},
+	}
+	for _, tt := range tests {
+		if s := tt.target.String(); s != tt.str {
+			t.Errorf("String() = %q, want %q", s, tt.str)
+		}
+		if _, err := tt.target.resolve(nil); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: resolve() error %v, want %q", tt.str, err, tt.err)
+		}
+	}
+
+	for _, val := range []any{(*Process)(nil), (*Application)(nil)} {
+		if target, err := NewTarget(val); err == nil || !strings.HasPrefix(err.Error(), "nil ") {
+			t.Errorf("NewTarget(%T) = %v, %v, want error", val, target, err)
+		}
+	}
+}
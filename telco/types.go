@@ -17,10 +17,14 @@ const (
 	DeviceTypeUsb
 )
 
+var deviceTypeNames = [...]string{
+	"local",
+	"remote",
+	"usb",
+}
+
 func (d DeviceType) String() string {
-	return [...]string{"local",
-		"remote",
-		"usb"}[d]
+	return deviceTypeNames[d]
 }
 
 type Realm int
@@ -30,9 +34,13 @@ const (
 	RealmEmulated
 )
 
+var realmNames = [...]string{
+	"native",
+	"emulated",
+}
+
 func (r Realm) String() string {
-	return [...]string{"native",
-		"emulated"}[r]
+	return realmNames[r]
 }
 
 type ScriptRuntime int
@@ -43,10 +51,14 @@ const (
 	ScriptRuntimeV8
 )
 
+var scriptRuntimeNames = [...]string{
+	"default",
+	"qjs",
+	"v8",
+}
+
 func (s ScriptRuntime) String() string {
-	return [...]string{"default",
-		"qjs",
-		"v8"}[s]
+	return scriptRuntimeNames[s]
 }
 
 type Scope int
@@ -70,9 +82,13 @@ const (
 	StdioPipe
 )
 
+var stdioNames = [...]string{
+	"inherit",
+	"pipe",
+}
+
 func (s Stdio) String() string {
-	return [...]string{"inherit",
-		"pipe"}[s]
+	return stdioNames[s]
 }
 
 type Runtime int
@@ -111,10 +127,14 @@ const (
 	RelayKindTurnTLS
 )
 
+var relayKindNames = [...]string{
+	"turn-udp",
+	"turn-tcp",
+	"turn-tls",
+}
+
 func (kind RelayKind) String() string {
-	return [...]string{"turn-udp",
-		"turn-tcp",
-		"turn-tls"}[kind]
+	return relayKindNames[kind]
 }
 
 type SessionDetachReason int
@@ -143,6 +163,47 @@ const (
 	SnapshotTransportSharedMemory
 )
 
+var snapshotTransportNames = [...]string{
+	"inline",
+	"shared-memory",
+}
+
+func (t SnapshotTransport) String() string {
+	return snapshotTransportNames[t]
+}
+
+type SourceMaps int
+
+const (
+	SourceMapsIncluded SourceMaps = iota
+	SourceMapsOmitted
+)
+
+var sourceMapsNames = [...]string{
+	"included",
+	"omitted",
+}
+
+func (s SourceMaps) String() string {
+	return sourceMapsNames[s]
+}
+
+type JSCompression int
+
+const (
+	JSCompressionNone JSCompression = iota
+	JSCompressionTerser
+)
+
+var jsCompressionNames = [...]string{
+	"none",
+	"terser",
+}
+
+func (c JSCompression) String() string {
+	return jsCompressionNames[c]
+}
+
 // Address represents structure returned by some specific signals.
 type Address struct {
 	Addr string
@@ -153,3 +214,116 @@ type Address struct {
 func (a *Address) String() string {
 	return fmt.Sprintf("%s:%d", a.Addr, a.Port)
 }
+
+// marshalEnum returns the name of v from names, as written in the config files.
+func marshalEnum[T ~int](v T, names []string) ([]byte, error) {
+	if v < 0 || int(v) >= len(names) {
+		return nil, fmt.Errorf("invalid %T %d", v, int(v))
+	}
+	return []byte(names[v]), nil
+}
+
+// unmarshalEnum sets v to the value named text in names.
+func unmarshalEnum[T ~int](v *T, text []byte, names []string) error {
+	for i, name := range names {
+		if name == string(text) {
+			*v = T(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %T %q", *v, text)
+}
+
+// MarshalText returns the name of the source maps mode.
+func (s SourceMaps) MarshalText() ([]byte, error) { return marshalEnum(s, sourceMapsNames[:]) }
+
+// UnmarshalText sets the source maps mode from its name.
+func (s *SourceMaps) UnmarshalText(text []byte) error {
+	return unmarshalEnum(s, text, sourceMapsNames[:])
+}
+
+// MarshalText returns the name of the compression.
+func (c JSCompression) MarshalText() ([]byte, error) { return marshalEnum(c, jsCompressionNames[:]) }
+
+// UnmarshalText sets the compression from its name.
+func (c *JSCompression) UnmarshalText(text []byte) error {
+	return unmarshalEnum(c, text, jsCompressionNames[:])
+}
+
+// The name types below are the enums used by the config structs, such as
+// SessionConfig, encoded by their names, e.g. "emulated" for RealmEmulated.
+// The enums themselves keep encoding as numbers.
+
+// DeviceTypeName is the DeviceType encoded by its name.
+type DeviceTypeName DeviceType
+
+// MarshalText returns the name of the device type.
+func (d DeviceTypeName) MarshalText() ([]byte, error) {
+	return marshalEnum(DeviceType(d), deviceTypeNames[:])
+}
+
+// UnmarshalText sets the device type from its name.
+func (d *DeviceTypeName) UnmarshalText(text []byte) error {
+	return unmarshalEnum((*DeviceType)(d), text, deviceTypeNames[:])
+}
+
+// RealmName is the Realm encoded by its name.
+type RealmName Realm
+
+// MarshalText returns the name of the realm.
+func (r RealmName) MarshalText() ([]byte, error) { return marshalEnum(Realm(r), realmNames[:]) }
+
+// UnmarshalText sets the realm from its name.
+func (r *RealmName) UnmarshalText(text []byte) error {
+	return unmarshalEnum((*Realm)(r), text, realmNames[:])
+}
+
+// ScriptRuntimeName is the ScriptRuntime encoded by its name.
+type ScriptRuntimeName ScriptRuntime
+
+// MarshalText returns the name of the runtime.
+func (s ScriptRuntimeName) MarshalText() ([]byte, error) {
+	return marshalEnum(ScriptRuntime(s), scriptRuntimeNames[:])
+}
+
+// UnmarshalText sets the runtime from its name.
+func (s *ScriptRuntimeName) UnmarshalText(text []byte) error {
+	return unmarshalEnum((*ScriptRuntime)(s), text, scriptRuntimeNames[:])
+}
+
+// StdioName is the Stdio encoded by its name.
+type StdioName Stdio
+
+// MarshalText returns the name of the stdio.
+func (s StdioName) MarshalText() ([]byte, error) { return marshalEnum(Stdio(s), stdioNames[:]) }
+
+// UnmarshalText sets the stdio from its name.
+func (s *StdioName) UnmarshalText(text []byte) error {
+	return unmarshalEnum((*Stdio)(s), text, stdioNames[:])
+}
+
+// RelayKindName is the RelayKind encoded by its name.
+type RelayKindName RelayKind
+
+// MarshalText returns the name of the relay kind.
+func (kind RelayKindName) MarshalText() ([]byte, error) {
+	return marshalEnum(RelayKind(kind), relayKindNames[:])
+}
+
+// UnmarshalText sets the relay kind from its name.
+func (kind *RelayKindName) UnmarshalText(text []byte) error {
+	return unmarshalEnum((*RelayKind)(kind), text, relayKindNames[:])
+}
+
+// SnapshotTransportName is the SnapshotTransport encoded by its name.
+type SnapshotTransportName SnapshotTransport
+
+// MarshalText returns the name of the snapshot transport.
+func (t SnapshotTransportName) MarshalText() ([]byte, error) {
+	return marshalEnum(SnapshotTransport(t), snapshotTransportNames[:])
+}
+
+// UnmarshalText sets the snapshot transport from its name.
+func (t *SnapshotTransportName) UnmarshalText(text []byte) error {
+	return unmarshalEnum((*SnapshotTransport)(t), text, snapshotTransportNames[:])
+}
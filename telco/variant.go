@@ -0,0 +1,590 @@
+package telco
+
+//#include <telco-core.h>
+import "C"
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+)
+
+// Tuple is the Go representation of the GVariant tuple, e.g. "(sib)". Tuples
+// are converted from and to Tuple so they are not confused with arrays.
+type Tuple []any
+
+// ConvertOption configures the conversion between Go values and GVariant.
+type ConvertOption func(*convertOptions)
+
+type convertOptions struct {
+	tag string
+}
+
+// WithStructTag sets the struct tag the field names are taken from, "telco" by
+// default. For example WithStructTag("json") reuses the json names of the fields.
+func WithStructTag(tag string) ConvertOption {
+	return func(o *convertOptions) {
+		if tag != "" {
+			o.tag = tag
+		}
+	}
+}
+
+func newConvertOptions(opts []ConvertOption) convertOptions {
+	o := convertOptions{tag: "telco"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+var tupleType = reflect.TypeOf(Tuple(nil))
+
+// ParseVariant parses the GVariant text format, as printed by FormatVariant or
+// gdbus, e.g. "{'aslr': <'disable'>, 'uid': <uint32 1000>}", and converts it to Go.
+func ParseVariant(text string) (any, error) {
+	textC := C.CString(text)
+	defer C.free(unsafe.Pointer(textC))
+
+	var gerr *C.GError
+	variant := C.g_variant_parse(nil, (*C.gchar)(textC), nil, nil, &gerr)
+	if gerr != nil {
+		return nil, &FError{gerr}
+	}
+	defer C.g_variant_unref(variant)
+
+	return gVariantToGo(variant), nil
+}
+
+// FormatVariant converts v to GVariant and returns it in the GVariant text format
+// with the types annotated, so it can be parsed back by ParseVariant.
+func FormatVariant(v any, opts ...ConvertOption) (string, error) {
+	variant, err := goToGVariant(v, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer C.g_variant_unref(variant)
+
+	text := C.g_variant_print(variant, C.gboolean(1))
+	defer C.g_free(C.gpointer(text))
+	return C.GoString((*C.char)(text)), nil
+}
+
+// gVariantToGo converts the GVariant to the Go value:
+//
+//	b                      bool
+//	y, n, q, i, u, x, t    uint8, int16, uint16, int32, uint32, int64, uint64
+//	h                      int32
+//	d                      float64
+//	s, o, g                string
+//	v                      the value inside
+//	m                      nil or the value inside
+//	ay                     []byte
+//	a{s*}, a{o*}, a{g*}    map[string]any
+//	a{**}                  map[any]any
+//	aa{sv}                 []map[string]any
+//	a*                     []any
+//	(*)                    Tuple
+func gVariantToGo(variant *C.GVariant) any {
+	if variant == nil {
+		return nil
+	}
+
+	switch byte(C.g_variant_classify(variant)) {
+	case 'b':
+		return boolFromVariant(variant)
+	case 'y':
+		return uint8(C.g_variant_get_byte(variant))
+	case 'n':
+		return int16(C.g_variant_get_int16(variant))
+	case 'q':
+		return uint16(C.g_variant_get_uint16(variant))
+	case 'i':
+		return int32(C.g_variant_get_int32(variant))
+	case 'u':
+		return uint32(C.g_variant_get_uint32(variant))
+	case 'x':
+		return int64FromVariant(variant)
+	case 't':
+		return uint64(C.g_variant_get_uint64(variant))
+	case 'h':
+		return int32(C.g_variant_get_handle(variant))
+	case 'd':
+		return float64(C.g_variant_get_double(variant))
+	case 's', 'o', 'g':
+		return stringFromVariant(variant)
+	case 'v':
+		inner := C.g_variant_get_variant(variant)
+		defer C.g_variant_unref(inner)
+		return gVariantToGo(inner)
+	case 'm':
+		inner := C.g_variant_get_maybe(variant)
+		if inner == nil {
+			return nil
+		}
+		defer C.g_variant_unref(inner)
+		return gVariantToGo(inner)
+	case 'a':
+		return arrayFromVariant(variant)
+	case '(', '{':
+		return Tuple(childrenFromVariant(variant))
+	}
+	return nil
+}
+
+func gPointerToGo(ptr C.gpointer) any {
+	return gVariantToGo((*C.GVariant)(ptr))
+}
+
+func getVariantStringFormat(variant *C.GVariant) string {
+	variantString := ""
+	if variant != nil {
+		variantType := C.g_variant_get_type_string(variant)
+		variantString = C.GoString((*C.char)(variantType))
+	}
+	return variantString
+}
+
+func bytesFromVariant(variant *C.GVariant) []byte {
+	var res *C.char
+	sz := C.g_variant_get_size(variant)
+	res = (*C.char)((C.g_variant_get_data(variant)))
+	bts := C.GoBytes(unsafe.Pointer(res), C.int(sz))
+	return bts
+}
+
+// stringFromVariant extracts string ("s") from GVariant
+func stringFromVariant(variant *C.GVariant) string {
+	var sz C.gsize
+	return C.GoString((*C.char)(C.g_variant_get_string(variant, &sz)))
+}
+
+// boolFromVariant extracts bool ("b") from GVariant
+func boolFromVariant(variant *C.GVariant) bool {
+	val := C.g_variant_get_boolean(variant)
+	return int(val) != 0
+}
+
+func int64FromVariant(variant *C.GVariant) int64 {
+	val := C.g_variant_get_int64(variant)
+	return int64(val)
+}
+
+// childFromVariant converts the child at index i of the container.
+func childFromVariant(variant *C.GVariant, i int) any {
+	child := C.g_variant_get_child_value(variant, C.gsize(i))
+	defer C.g_variant_unref(child)
+	return gVariantToGo(child)
+}
+
+func childrenFromVariant(variant *C.GVariant) []any {
+	count := int(C.g_variant_n_children(variant))
+	children := make([]any, count)
+	for i := range children {
+		children[i] = childFromVariant(variant, i)
+	}
+	return children
+}
+
+func arrayFromVariant(variant *C.GVariant) any {
+	variantType := getVariantStringFormat(variant)
+
+	switch {
+	case variantType == "ay":
+		return bytesFromVariant(variant)
+	case strings.HasPrefix(variantType, "a{"):
+		return dictFromVariant(variant, variantType[2])
+	case variantType == "aa{sv}":
+		count := int(C.g_variant_n_children(variant))
+		maps := make([]map[string]any, count)
+		for i := range maps {
+			maps[i] = childFromVariant(variant, i).(map[string]any)
+		}
+		return maps
+	}
+	return childrenFromVariant(variant)
+}
+
+func dictFromVariant(variant *C.GVariant, keyType byte) any {
+	count := int(C.g_variant_n_children(variant))
+
+	if keyType != 's' && keyType != 'o' && keyType != 'g' {
+		mp := make(map[any]any, count)
+		for i := 0; i < count; i++ {
+			entry := childFromVariant(variant, i).(Tuple)
+			mp[entry[0]] = entry[1]
+		}
+		return mp
+	}
+
+	mp := make(map[string]any, count)
+	for i := 0; i < count; i++ {
+		entry := C.g_variant_get_child_value(variant, C.gsize(i))
+		key := childFromVariant(entry, 0).(string)
+		mp[key] = childFromVariant(entry, 1)
+		C.g_variant_unref(entry)
+	}
+	return mp
+}
+
+// goToGVariant converts v to GVariant; the caller owns the reference returned.
+//
+//	bool                   b
+//	uint8                  y
+//	int8, int16, uint16    n, n, q
+//	int32, uint32          i, u
+//	int, int64             x
+//	uint, uint64, uintptr  t
+//	float32, float64       d
+//	string, time.Time      s, time.Time as RFC 3339
+//	[]byte                 ay
+//	[]T, [N]T              aT, []any being av
+//	map[K]V                a{KV}, map[string]any being a{sv}
+//	struct                 a{sv}, named by the struct tag
+//	*T                     mT, nil being nothing
+//	Tuple                  (...)
+//
+// Struct fields are named by the struct tag, "telco" unless set by WithStructTag,
+// or by the field name. Fields tagged "-" are skipped and fields tagged with the
+// omitempty option are skipped when they are the zero value. Nil interfaces
+// cannot be converted.
+func goToGVariant(v any, opts ...ConvertOption) (*C.GVariant, error) {
+	o := newConvertOptions(opts)
+	variant, err := o.encode(reflect.ValueOf(v), "value")
+	if err != nil {
+		return nil, err
+	}
+	return C.g_variant_ref_sink(variant), nil
+}
+
+// encode returns the floating GVariant for rv.
+func (o convertOptions) encode(rv reflect.Value, path string) (*C.GVariant, error) {
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("cannot convert %s: nil", path)
+	}
+
+	switch rv.Type() {
+	case timeType:
+		if !rv.CanInterface() {
+			return nil, fmt.Errorf("cannot convert %s: unexported time.Time", path)
+		}
+		return newStringVariant(rv.Interface().(time.Time).Format(time.RFC3339Nano), path)
+	case tupleType:
+		return o.encodeTuple(rv, path)
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return C.g_variant_new_boolean(C.gboolean(1)), nil
+		}
+		return C.g_variant_new_boolean(C.gboolean(0)), nil
+	case reflect.Uint8:
+		return C.g_variant_new_byte(C.guchar(rv.Uint())), nil
+	case reflect.Int8, reflect.Int16:
+		return C.g_variant_new_int16(C.gint16(rv.Int())), nil
+	case reflect.Uint16:
+		return C.g_variant_new_uint16(C.guint16(rv.Uint())), nil
+	case reflect.Int32:
+		return C.g_variant_new_int32(C.gint32(rv.Int())), nil
+	case reflect.Uint32:
+		return C.g_variant_new_uint32(C.guint32(rv.Uint())), nil
+	case reflect.Int, reflect.Int64:
+		return C.g_variant_new_int64(C.gint64(rv.Int())), nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return C.g_variant_new_uint64(C.guint64(rv.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return C.g_variant_new_double(C.gdouble(rv.Float())), nil
+	case reflect.String:
+		return newStringVariant(rv.String(), path)
+	case reflect.Interface:
+		if rv.IsNil() {
+			return nil, fmt.Errorf("cannot convert %s: nil", path)
+		}
+		inner, err := o.encode(rv.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		return C.g_variant_new_variant(inner), nil
+	case reflect.Pointer:
+		if rv.IsNil() {
+			sig, err := o.signature(rv.Type().Elem())
+			if err != nil {
+				return nil, fmt.Errorf("cannot convert %s: %w", path, err)
+			}
+			typ := newVariantType(sig)
+			defer C.g_variant_type_free(typ)
+			return C.g_variant_new_maybe(typ, nil), nil
+		}
+		inner, err := o.encode(rv.Elem(), path)
+		if err != nil {
+			return nil, err
+		}
+		return C.g_variant_new_maybe(nil, inner), nil
+	case reflect.Slice, reflect.Array:
+		return o.encodeArray(rv, path)
+	case reflect.Map:
+		return o.encodeMap(rv, path)
+	case reflect.Struct:
+		return o.encodeStruct(rv, path)
+	}
+
+	return nil, fmt.Errorf("cannot convert %s: unsupported type %s", path, rv.Type())
+}
+
+func (o convertOptions) encodeTuple(rv reflect.Value, path string) (*C.GVariant, error) {
+	children := make([]*C.GVariant, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		// tuple items are not wrapped into variants like the items of []any
+		item := rv.Index(i).Elem()
+		child, err := o.encode(item, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			freeVariants(children)
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		return C.g_variant_new_tuple(nil, 0), nil
+	}
+	return C.g_variant_new_tuple(&children[0], C.gsize(len(children))), nil
+}
+
+func (o convertOptions) encodeArray(rv reflect.Value, path string) (*C.GVariant, error) {
+	if rv.Len() == 0 {
+		sig, err := o.signature(rv.Type().Elem())
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s: %w", path, err)
+		}
+		return newEmptyArray(sig), nil
+	}
+
+	if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+		bts := rv.Bytes()
+		typ := newVariantType("y")
+		defer C.g_variant_type_free(typ)
+		return C.g_variant_new_fixed_array(typ, C.gconstpointer(unsafe.Pointer(&bts[0])), C.gsize(len(bts)), 1), nil
+	}
+
+	children := make([]*C.GVariant, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		child, err := o.encode(rv.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			freeVariants(children)
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return newArray(children, path)
+}
+
+func (o convertOptions) encodeMap(rv reflect.Value, path string) (*C.GVariant, error) {
+	typ := rv.Type()
+	keySig, err := o.signature(typ.Key())
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %s: %w", path, err)
+	}
+	if len(keySig) != 1 || !strings.Contains("bynqiuxtdsog", keySig) {
+		return nil, fmt.Errorf("cannot convert %s: unsupported key type %s", path, typ.Key())
+	}
+
+	if rv.Len() == 0 {
+		valueSig, err := o.signature(typ.Elem())
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s: %w", path, err)
+		}
+		return newEmptyArray("{" + keySig + valueSig + "}"), nil
+	}
+
+	keys := rv.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+
+	entries := make([]*C.GVariant, 0, len(keys))
+	for _, key := range keys {
+		entryPath := fmt.Sprintf("%s[%v]", path, key)
+		k, err := o.encode(key, entryPath)
+		if err != nil {
+			freeVariants(entries)
+			return nil, err
+		}
+		v, err := o.encode(rv.MapIndex(key), entryPath)
+		if err != nil {
+			freeVariants([]*C.GVariant{k})
+			freeVariants(entries)
+			return nil, err
+		}
+		entries = append(entries, C.g_variant_new_dict_entry(k, v))
+	}
+	return newArray(entries, path)
+}
+
+func (o convertOptions) encodeStruct(rv reflect.Value, path string) (*C.GVariant, error) {
+	var entries []*C.GVariant
+	if err := o.encodeFields(rv, path, &entries); err != nil {
+		freeVariants(entries)
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return newEmptyArray("{sv}"), nil
+	}
+	return newArray(entries, path)
+}
+
+// encodeFields appends the a{sv} entries for the fields of the struct, flattening
+// the embedded structs without the tag.
+func (o convertOptions) encodeFields(rv reflect.Value, path string, entries *[]*C.GVariant) error {
+	typ := rv.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		fv := rv.Field(i)
+
+		tag, hasTag := field.Tag.Lookup(o.tag)
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "-" && options == "" {
+			continue
+		}
+
+		if field.Anonymous && !hasTag {
+			if fv.Kind() == reflect.Pointer {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := o.encodeFields(fv, path, entries); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		if strings.Contains(","+options+",", ",omitempty,") && fv.IsZero() {
+			continue
+		}
+		// a{sv} holds no nil values
+		if fv.Kind() == reflect.Interface {
+			if fv.IsNil() {
+				continue
+			}
+			fv = fv.Elem()
+		}
+
+		fieldPath := path + "." + name
+		v, err := o.encode(fv, fieldPath)
+		if err != nil {
+			return err
+		}
+		k, err := newStringVariant(name, fieldPath)
+		if err != nil {
+			freeVariants([]*C.GVariant{v})
+			return err
+		}
+		*entries = append(*entries, C.g_variant_new_dict_entry(k, C.g_variant_new_variant(v)))
+	}
+	return nil
+}
+
+// signature returns the GVariant type string for the Go type, used for empty
+// containers and nothing maybes.
+func (o convertOptions) signature(typ reflect.Type) (string, error) {
+	switch typ {
+	case timeType:
+		return "s", nil
+	case tupleType:
+		return "", errors.New("tuple types are only known from their items")
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "b", nil
+	case reflect.Uint8:
+		return "y", nil
+	case reflect.Int8, reflect.Int16:
+		return "n", nil
+	case reflect.Uint16:
+		return "q", nil
+	case reflect.Int32:
+		return "i", nil
+	case reflect.Uint32:
+		return "u", nil
+	case reflect.Int, reflect.Int64:
+		return "x", nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return "t", nil
+	case reflect.Float32, reflect.Float64:
+		return "d", nil
+	case reflect.String:
+		return "s", nil
+	case reflect.Interface:
+		return "v", nil
+	case reflect.Pointer:
+		sig, err := o.signature(typ.Elem())
+		return "m" + sig, err
+	case reflect.Slice, reflect.Array:
+		sig, err := o.signature(typ.Elem())
+		return "a" + sig, err
+	case reflect.Map:
+		keySig, err := o.signature(typ.Key())
+		if err != nil {
+			return "", err
+		}
+		valueSig, err := o.signature(typ.Elem())
+		return "a{" + keySig + valueSig + "}", err
+	case reflect.Struct:
+		return "a{sv}", nil
+	}
+	return "", fmt.Errorf("unsupported type %s", typ)
+}
+
+func newStringVariant(s, path string) (*C.GVariant, error) {
+	if !utf8.ValidString(s) || strings.IndexByte(s, 0) != -1 {
+		return nil, fmt.Errorf("cannot convert %s: string is not valid UTF-8 without NUL bytes", path)
+	}
+	sC := C.CString(s)
+	defer C.free(unsafe.Pointer(sC))
+	return C.g_variant_new_string((*C.gchar)(sC)), nil
+}
+
+func newVariantType(sig string) *C.GVariantType {
+	sigC := C.CString(sig)
+	defer C.free(unsafe.Pointer(sigC))
+	return C.g_variant_type_new((*C.gchar)(sigC))
+}
+
+func newEmptyArray(elemSig string) *C.GVariant {
+	typ := newVariantType(elemSig)
+	defer C.g_variant_type_free(typ)
+	return C.g_variant_new_array(typ, nil, 0)
+}
+
+// newArray returns the array of the children, which need to be of the same type.
+func newArray(children []*C.GVariant, path string) (*C.GVariant, error) {
+	elemType := getVariantStringFormat(children[0])
+	for _, child := range children[1:] {
+		if typ := getVariantStringFormat(child); typ != elemType {
+			freeVariants(children)
+			return nil, fmt.Errorf("cannot convert %s: mixed item types %s and %s", path, elemType, typ)
+		}
+	}
+	return C.g_variant_new_array(nil, &children[0], C.gsize(len(children))), nil
+}
+
+// freeVariants releases the floating variants not passed to a container.
+func freeVariants(variants []*C.GVariant) {
+	for _, variant := range variants {
+		C.g_variant_unref(C.g_variant_ref_sink(variant))
+	}
+}
@@ -0,0 +1,84 @@
+package telco
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVariantRoundTrip(t *testing.T) {
+	type proc struct {
+		Name string `json:"name"`
+		PID  uint32 `json:"pid"`
+		Args []any  `json:"args,omitempty"`
+		Skip string `json:"-"`
+	}
+	five := int32(5)
+
+	tests := []struct {
+		value any
+		text  string
+		want  any
+	}{
+		{Tuple{"a", int32(1), true}, "('a', 1, true)", Tuple{"a", int32(1), true}},
+		{Tuple{}, "()", Tuple{}},
+		{(*int32)(nil), "@mi nothing", nil},
+		{&five, "@mi 5", int32(5)},
+		{[]string{}, "@as []", []any{}},
+		{[]any{}, "@av []", []any{}},
+		{map[string]int32{}, "@a{si} {}", map[string]any{}},
+		{struct{}{}, "@a{sv} {}", map[string]any{}},
+		{[]any{"x", int32(1)}, "[<'x'>, <1>]", []any{"x", int32(1)}},
+		{[]byte("hi"), "[byte 0x68, 0x69]", []byte("hi")},
+		{map[string]any{"a": uint64(1)}, "{'a': <uint64 1>}", map[string]any{"a": uint64(1)}},
+		{map[uint32]string{1: "a"}, "{uint32 1: 'a'}", map[any]any{uint32(1): "a"}},
+		{
+			proc{Name: "sh", PID: 42, Skip: "x"},
+			"{'name': <'sh'>, 'pid': <uint32 42>}",
+			map[string]any{"name": "sh", "pid": uint32(42)},
+		},
+	}
+
+	for _, tt := range tests {
+		text, err := FormatVariant(tt.value, WithStructTag("json"))
+		if err != nil {
+			t.Errorf("FormatVariant(%#v): %v", tt.value, err)
+			continue
+		}
+		if text != tt.text {
+			t.Errorf("FormatVariant(%#v) = %s, want %s", tt.value, text, tt.text)
+		}
+
+		got, err := ParseVariant(text)
+		if err != nil {
+			t.Errorf("ParseVariant(%q): %v", text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseVariant(%q) = %#v, want %#v", text, got, tt.want)
+		}
+	}
+}
+
+func TestVariantErrors(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{[]Tuple{{"a"}, {int32(1)}}, "cannot convert value: mixed item types (s) and (i)"},
+		{[]any{nil}, "cannot convert value[0]: nil"},
+		{map[string]any{"a": "\xff"}, "cannot convert value[a]: string is not valid UTF-8"},
+		{make(chan int), "unsupported type chan int"},
+	}
+
+	for _, tt := range tests {
+		_, err := FormatVariant(tt.value)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("FormatVariant(%#v) error = %v, want %q", tt.value, err, tt.want)
+		}
+	}
+
+	if _, err := ParseVariant("[1, 'a']"); err == nil {
+		t.Error("ParseVariant of the mixed array succeeded")
+	}
+}
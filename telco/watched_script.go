@@ -0,0 +1,308 @@
+package telco
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// WatchedScriptOptions configures Session.LoadWatched.
+type WatchedScriptOptions struct {
+	Build  BuildOptions
+	Script ScriptConfig
+	// Handlers are connected to every version of the script before it is loaded,
+	// keyed by the signal name; see WatchedScript.On.
+	Handlers map[string]any
+	// OnDiagnostics is called with the diagnostics of every build.
+	OnDiagnostics func(diags []Diagnostic)
+	// OnReload is called once the new version of the script is loaded.
+	OnReload func()
+	// OnError is called when the new version fails to load; the previous version
+	// is kept running.
+	OnError func(err error)
+}
+
+// WatchedScript is the script loaded by Session.LoadWatched, reloaded whenever
+// the entrypoint or the files it imports change.
+type WatchedScript struct {
+	session *Session
+	opts    WatchedScriptOptions
+
+	// mu guards the current version. It is never held while loading or
+	// unloading a version, as the handlers run meanwhile.
+	mu     sync.RWMutex
+	script *Script
+	code   string
+
+	// hmu guards handlers, which are called on the GLib thread.
+	hmu      sync.RWMutex
+	handlers map[string]reflect.Value
+
+	done chan struct{}
+}
+
+// scriptSignalParams is the number of parameters passed to the handlers of the
+// signals forwarded by WatchedScript.
+var scriptSignalParams = map[string]int{
+	"message":   2,
+	"destroyed": 0,
+}
+
+// LoadWatched builds the script from the entrypoint, loads it and keeps rebuilding
+// it until ctx is done. On every successful build the new version is loaded with
+// the same handlers and the previous one unloaded afterwards, so both run for a
+// moment; exports calls waiting for the previous version return ErrScriptDestroyed. Builds reporting errors in their
+// diagnostics are skipped, keeping the previous version running. opts can be nil.
+// The script is unloaded once ctx is done.
+func (s *Session) LoadWatched(ctx context.Context, entrypoint string, opts *WatchedScriptOptions) (*WatchedScript, error) {
+	w := &WatchedScript{
+		session:  s,
+		handlers: make(map[string]reflect.Value),
+		done:     make(chan struct{}),
+	}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if err := w.opts.Build.Validate(); err != nil {
+		return nil, err
+	}
+	if err := w.opts.Script.Validate(); err != nil {
+		return nil, err
+	}
+	for sigName, fn := range w.opts.Handlers {
+		if err := w.setHandler(sigName, fn); err != nil {
+			return nil, err
+		}
+	}
+
+	comp := NewCompiler()
+
+	// Watch reports the diagnostics of the builds after the initial one
+	diags := connectSignal(unsafe.Pointer(comp.cc), "diagnostics", func(v any) {
+		w.diagnostics(diagnosticsFromValue(v))
+	})
+	bundle, err := comp.Build(entrypoint, &w.opts.Build)
+	diags.disconnect()
+	if err != nil {
+		comp.Clean()
+		return nil, err
+	}
+
+	if err := w.reload(bundle.Code); err != nil {
+		comp.Clean()
+		return nil, err
+	}
+
+	events, err := comp.Watch(ctx, entrypoint, &w.opts.Build)
+	if err != nil {
+		w.unload()
+		comp.Clean()
+		return nil, err
+	}
+
+	go w.watch(events, comp)
+	return w, nil
+}
+
+// On sets the handler of the script signal, replacing the previous one. The handler
+// is kept across the reloads. Handlers must not block, as the reload waits for
+// them; they can call back into the WatchedScript.
+//
+// Signals available are:
+//   - "destroyed" with callback as func() {}, called for every version unloaded
+//   - "message" with callback as func(message string, data []byte) {}
+func (w *WatchedScript) On(sigName string, fn any) {
+	if err := w.setHandler(sigName, fn); err != nil {
+		panic(err)
+	}
+}
+
+// Script returns the version of the script currently loaded, nil if none is.
+func (w *WatchedScript) Script() *Script {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.script
+}
+
+// Post sends post to the version of the script currently loaded.
+func (w *WatchedScript) Post(jsonString string, data []byte) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.script != nil {
+		w.script.Post(jsonString, data)
+	}
+}
+
+// ExportsCall calls fn from the rpc.exports of the version currently loaded.
+func (w *WatchedScript) ExportsCall(fn string, args ...any) any {
+	return w.ExportsCallWithContext(context.Background(), fn, args...)
+}
+
+// ExportsCallWithContext calls fn from the rpc.exports of the version currently
+// loaded using context provided. Calls made while the new version is loaded go to
+// the previous one; calls still waiting when it is unloaded return ErrScriptDestroyed.
+func (w *WatchedScript) ExportsCallWithContext(ctx context.Context, fn string, args ...any) any {
+	// the call is registered before a reload can unload the version, so the
+	// unload fails it instead of leaving it waiting
+	w.mu.RLock()
+	if w.script == nil {
+		w.mu.RUnlock()
+		return ErrScriptDestroyed
+	}
+	ch := w.script.makeExportsCall(fn, args...)
+	w.mu.RUnlock()
+
+	select {
+	case <-ctx.Done():
+		return ErrContextCancelled
+	case ret := <-ch:
+		return ret
+	}
+}
+
+// Done returns the channel closed once the script is unloaded after ctx is done.
+func (w *WatchedScript) Done() <-chan struct{} {
+	return w.done
+}
+
+func (w *WatchedScript) watch(events <-chan BuildEvent, comp *Compiler) {
+	defer close(w.done)
+	defer comp.Clean()
+	defer w.unload()
+
+	var failed bool
+	for ev := range events {
+		switch ev.Type {
+		case BuildStarting:
+			failed = false
+		case BuildDiagnostics:
+			for _, diag := range ev.Diagnostics {
+				if diag.IsError() {
+					failed = true
+				}
+			}
+			w.diagnostics(ev.Diagnostics)
+		case BuildOutput:
+			if failed {
+				continue
+			}
+			if err := w.reload(ev.Bundle); err != nil && w.opts.OnError != nil {
+				w.opts.OnError(err)
+			}
+		}
+	}
+}
+
+func (w *WatchedScript) diagnostics(diags []Diagnostic) {
+	if w.opts.OnDiagnostics != nil && len(diags) > 0 {
+		w.opts.OnDiagnostics(diags)
+	}
+}
+
+// reload replaces the current version with the one built from code. If the new
+// version fails to load the previous one keeps running.
+func (w *WatchedScript) reload(code string) error {
+	reloaded, err := w.swap(code)
+	if reloaded && w.opts.OnReload != nil {
+		w.opts.OnReload()
+	}
+	return err
+}
+
+// swap loads the version built from code and unloads the previous one once the
+// new one is current. Only the watch goroutine swaps, so the versions are not
+// replaced concurrently.
+func (w *WatchedScript) swap(code string) (bool, error) {
+	w.mu.RLock()
+	unchanged := w.script != nil && code == w.code
+	w.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	// creating the script fails on syntax errors, keep the previous version then
+	next, err := w.create(code)
+	if err != nil {
+		return false, err
+	}
+	if err := next.Load(); err != nil {
+		next.Clean()
+		return false, fmt.Errorf("load: %w", err)
+	}
+
+	w.mu.Lock()
+	prev := w.script
+	w.script, w.code = next, code
+	w.mu.Unlock()
+
+	if prev != nil {
+		prev.Unload()
+		prev.Clean()
+	}
+	return true, nil
+}
+
+// create creates the script with the handlers connected, but does not load it.
+func (w *WatchedScript) create(code string) (*Script, error) {
+	scriptOpts, err := w.opts.Script.Options()
+	if err != nil {
+		return nil, err
+	}
+
+	script, err := w.session.CreateScriptWithOptions(code, scriptOpts)
+	if err != nil {
+		return nil, err
+	}
+	script.On("message", func(message string, data []byte) {
+		w.emit("message", message, data)
+	})
+	script.On("destroyed", func() {
+		w.emit("destroyed")
+	})
+	return script, nil
+}
+
+func (w *WatchedScript) unload() {
+	w.mu.Lock()
+	script := w.script
+	w.script = nil
+	w.mu.Unlock()
+
+	if script != nil {
+		script.Unload()
+		script.Clean()
+	}
+}
+
+func (w *WatchedScript) setHandler(sigName string, fn any) error {
+	params, ok := scriptSignalParams[sigName]
+	if !ok {
+		return fmt.Errorf("unknown script signal %q", sigName)
+	}
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.Type().NumIn() > params {
+		return fmt.Errorf("invalid %q handler %T", sigName, fn)
+	}
+
+	w.hmu.Lock()
+	w.handlers[sigName] = fv
+	w.hmu.Unlock()
+	return nil
+}
+
+func (w *WatchedScript) emit(sigName string, params ...any) {
+	w.hmu.RLock()
+	fn, ok := w.handlers[sigName]
+	w.hmu.RUnlock()
+	if !ok {
+		return
+	}
+
+	args := make([]reflect.Value, fn.Type().NumIn())
+	for i := range args {
+		args[i] = reflect.ValueOf(params[i]).Convert(fn.Type().In(i))
+	}
+	fn.Call(args)
+}